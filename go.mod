@@ -4,5 +4,6 @@ go 1.15
 
 require (
 	github.com/aead/cmac v0.0.0-20160719120800-7af84192f0b1
+	github.com/miekg/pkcs11 v1.1.1
 	github.com/stretchr/testify v1.6.1
 )
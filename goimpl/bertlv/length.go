@@ -39,7 +39,10 @@ func LengthFromReader(data io.Reader) (bytesRead int, length uint64, err error)
 	} else if read < int(lengthLength) {
 		err = fmt.Errorf("%d length bytes were required, only %d could be read", lengthLength, read)
 	} else {
-		length = binary.BigEndian.Uint64(lengthBytes)
+		// Left-pad to the 8 bytes binary expects
+		padded := make([]byte, 8)
+		copy(padded[8-len(lengthBytes):], lengthBytes)
+		length = binary.BigEndian.Uint64(padded)
 	}
 	return
 }
@@ -1,6 +1,7 @@
 package bertlv
 
 import (
+	"bytes"
 	"io"
 	"testing"
 
@@ -18,7 +19,32 @@ func TestLengthFromReader(t *testing.T) {
 		wantLength    uint64
 		assertion     assert.ErrorAssertionFunc
 	}{
-		// TODO: Add test cases.
+		{
+			name:          "short",
+			args:          args{data: bytes.NewReader([]byte{0x7F})},
+			wantBytesRead: 1,
+			wantLength:    0x7F,
+			assertion:     assert.NoError,
+		},
+		{
+			name:          "two byte long form",
+			args:          args{data: bytes.NewReader([]byte{0x82, 0x01, 0x02})},
+			wantBytesRead: 3,
+			wantLength:    0x0102,
+			assertion:     assert.NoError,
+		},
+		{
+			name:          "truncated long form",
+			args:          args{data: bytes.NewReader([]byte{0x82, 0x01})},
+			wantBytesRead: 2,
+			assertion:     assert.Error,
+		},
+		{
+			name:          "indefinite",
+			args:          args{data: bytes.NewReader([]byte{0x80})},
+			wantBytesRead: 1,
+			assertion:     assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
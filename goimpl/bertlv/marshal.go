@@ -1,7 +1,6 @@
 package bertlv
 
 import (
-	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -14,71 +13,139 @@ const structTagName = "bertlv"
 // Raw is unparsed BER-TLV data
 type Raw []byte
 
-// Marshal converts a struct with bertlv tags to a BER-TLV encoded byte slice
-func Marshal(v interface{}) (encoded []byte, err error) {
-	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("%v", err)
-		}
-	}()
-	vType := reflect.TypeOf(v)
+// Marshal converts a struct with bertlv tags to a BER-TLV encoded byte slice, the encodings of its tagged exported fields in field order.
+// A field tag is the hex tag, optionally followed by omitempty to skip the field when its value is empty, e.g. `bertlv:"5F20,omitempty"`.
+// Byte slices (including Raw) and strings are encoded as they are, unsigned integers as minimal big-endian values and structs as constructed objects holding their own tagged fields.
+// Nil pointers and nil slices are always skipped.
+func Marshal(v interface{}) ([]byte, error) {
 	vVal := reflect.ValueOf(v)
-	for vType.Kind() == reflect.Ptr {
-		vType = vType.Elem()
+	for vVal.Kind() == reflect.Ptr {
+		if vVal.IsNil() {
+			return nil, fmt.Errorf("cannot marshal a nil pointer")
+		}
 		vVal = vVal.Elem()
 	}
-	if vType.Kind() != reflect.Struct {
-		return nil, fmt.Errorf("non-structs not supported, got %s", vType.Kind().String())
+	if vVal.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("non-structs not supported, got %s", vVal.Kind().String())
 	}
-	buf := bytes.NewBuffer(nil)
-	numFields := vType.NumField()
-	for i := 0; i < numFields; i++ {
+	return marshalFields(vVal)
+}
+
+func marshalFields(vVal reflect.Value) ([]byte, error) {
+	vType := vVal.Type()
+	var encoded []byte
+	for i := 0; i < vType.NumField(); i++ {
 		field := vType.Field(i)
-		fieldVal := vVal.FieldByName(field.Name)
 		// Only check tags on exported fields
-		if string([]byte{field.Name[0]}) == strings.ToUpper(string([]byte{field.Name[0]})) {
-			tag, exists := field.Tag.Lookup(structTagName)
-			if exists {
-				parsedTag, omitEmpty := parseStructTag(tag, field.Name)
-				obj := Object{
-					Tag: parsedTag,
-				}
-				isNil := false
-				if field.Type.Kind() == reflect.Ptr {
+		if field.PkgPath != "" {
+			continue
+		}
+		structTag, exists := field.Tag.Lookup(structTagName)
+		if !exists {
+			continue
+		}
+		tag, omitEmpty, err := parseStructTag(structTag, field.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, present, err := marshalValue(vVal.Field(i), field.Name)
+		if err != nil {
+			return nil, err
+		}
+		if !present || (omitEmpty && isEmptyValue(vVal.Field(i))) {
+			continue
+		}
+		if fieldKind(field.Type) == reflect.Struct {
+			tag.ConstructedEncoding = true
+		}
+		objBytes, err := Object{Tag: tag, Value: value}.ToBytes()
+		if err != nil {
+			return nil, fmt.Errorf("encoding field %s: %w", field.Name, err)
+		}
+		encoded = append(encoded, objBytes...)
+	}
+	return encoded, nil
+}
 
-				}
-				switch field.Type.Kind() {
-				case reflect.Ptr:
-				case reflect.Struct:
-				case reflect.Bool:
-				case reflect.Int, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint8:
-				case reflect.Slice:
-				}
+// isEmptyValue reports whether omitempty skips the value: zero length byte slices and strings, zero integers and nil pointers
+func isEmptyValue(val reflect.Value) bool {
+	switch val.Kind() {
+	case reflect.Slice, reflect.String:
+		return val.Len() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return val.Uint() == 0
+	case reflect.Ptr:
+		return val.IsNil()
+	default:
+		return false
+	}
+}
 
-			}
+// fieldKind is the kind of a field type after following pointers
+func fieldKind(t reflect.Type) reflect.Kind {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind()
+}
+
+// marshalValue encodes the value of a field, present is false for nil pointers and slices
+func marshalValue(val reflect.Value, name string) (value []byte, present bool, err error) {
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return nil, false, nil
+		}
+		val = val.Elem()
+	}
+	switch val.Kind() {
+	case reflect.Slice:
+		if val.Type().Elem().Kind() != reflect.Uint8 {
+			return nil, false, fmt.Errorf("field %s: only byte slices are supported, got a slice of %s", name, val.Type().Elem().Kind())
+		}
+		if val.IsNil() {
+			return nil, false, nil
 		}
+		return val.Bytes(), true, nil
+	case reflect.String:
+		return []byte(val.String()), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		raw := make([]byte, 8)
+		binary.BigEndian.PutUint64(raw, val.Uint())
+		start := 0
+		for start < 7 && raw[start] == 0 {
+			start++
+		}
+		return raw[start:], true, nil
+	case reflect.Struct:
+		value, err = marshalFields(val)
+		if err != nil {
+			return nil, false, fmt.Errorf("field %s: %w", name, err)
+		}
+		return value, true, nil
+	default:
+		return nil, false, fmt.Errorf("field %s: unsupported type %s", name, val.Kind())
 	}
-	return nil, fmt.Errorf("not implemented")
 }
 
-func parseStructTag(in, name string) (tag Tag, omitEmpty bool) {
+func parseStructTag(in, name string) (tag Tag, omitEmpty bool, err error) {
 	elems := strings.Split(in, ",")
 	switch len(elems) {
 	case 2:
 		if elems[1] != "omitempty" {
-			panic(fmt.Sprintf("second part of tag must be omitempty if it is present, found %s", elems[1]))
+			return Tag{}, false, fmt.Errorf("second part of tag must be omitempty if it is present, found %s", elems[1])
 		}
 		omitEmpty = true
 		fallthrough
 	case 1:
 		tagBytes, err := hex.DecodeString(elems[0])
 		if err != nil {
-			panic(fmt.Sprintf("invalid tag hex in field %s: %v", name, err))
+			return Tag{}, false, fmt.Errorf("invalid tag hex in field %s: %w", name, err)
+		}
+		if tag, err = TagFromBytes(tagBytes); err != nil {
+			return Tag{}, false, fmt.Errorf("invalid tag in field %s: %w", name, err)
 		}
-		tagUint := binary.BigEndian.Uint64(tagBytes)
-		tag = TagFromUintForced(tagUint)
-		return
+		return tag, omitEmpty, nil
 	default:
-		panic(fmt.Sprintf("invalid struct tag on field %s: must have 1 or 2 comma-separated elements, found %d", name, len(elems)))
+		return Tag{}, false, fmt.Errorf("invalid struct tag on field %s: must have 1 or 2 comma-separated elements, found %d", name, len(elems))
 	}
 }
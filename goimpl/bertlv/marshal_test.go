@@ -0,0 +1,92 @@
+package bertlv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type marshalInner struct {
+	ID      []byte `bertlv:"4F"`
+	Version uint16 `bertlv:"C1,omitempty"`
+}
+
+type marshalOuter struct {
+	Inner    *marshalInner `bertlv:"A5"`
+	Label    string        `bertlv:"5F20,omitempty"`
+	Data     Raw           `bertlv:"C4"`
+	Optional []byte        `bertlv:"C5,omitempty"`
+	Untagged []byte
+	private  []byte `bertlv:"C6"`
+}
+
+func TestMarshal(t *testing.T) {
+	tests := []struct {
+		name      string
+		v         interface{}
+		want      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "nested",
+			v: &marshalOuter{
+				Inner:    &marshalInner{ID: []byte{0xA0, 0x00}, Version: 0x0102},
+				Label:    "GP",
+				Data:     Raw{0x01},
+				Optional: []byte{},
+				Untagged: []byte{0xFF},
+				private:  []byte{0xFF},
+			},
+			want: []byte{
+				0xA5, 0x08, 0x4F, 0x02, 0xA0, 0x00, 0xC1, 0x02, 0x01, 0x02,
+				0x5F, 0x20, 0x02, 'G', 'P',
+				0xC4, 0x01, 0x01,
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "nil and empty fields",
+			v:         marshalOuter{Inner: &marshalInner{ID: []byte{}}},
+			want:      []byte{0xA5, 0x02, 0x4F, 0x00},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "not a struct",
+			v:         []byte{1},
+			assertion: assert.Error,
+		},
+		{
+			name:      "nil pointer",
+			v:         (*marshalOuter)(nil),
+			assertion: assert.Error,
+		},
+		{
+			name: "invalid tag",
+			v: struct {
+				A []byte `bertlv:"XY"`
+			}{A: []byte{1}},
+			assertion: assert.Error,
+		},
+		{
+			name: "invalid option",
+			v: struct {
+				A []byte `bertlv:"C1,required"`
+			}{A: []byte{1}},
+			assertion: assert.Error,
+		},
+		{
+			name: "unsupported type",
+			v: struct {
+				A bool `bertlv:"C1"`
+			}{A: true},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.v)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
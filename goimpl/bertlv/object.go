@@ -8,3 +8,13 @@ type Object struct {
 	Length uint64
 	Value  []byte
 }
+
+// ToBytes encodes the object as BER-TLV, the Length field is ignored in favour of the real length of Value
+func (o Object) ToBytes() ([]byte, error) {
+	tagBytes, err := o.Tag.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	out := append(tagBytes, LengthToBytes(uint64(len(o.Value)))...)
+	return append(out, o.Value...), nil
+}
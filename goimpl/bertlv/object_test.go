@@ -0,0 +1,50 @@
+package bertlv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestObject_ToBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		o         Object
+		want      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "two byte tag",
+			o: Object{
+				Tag:    TagFromUintForced(0x5F20),
+				Length: 100,
+				Value:  []byte{1, 2},
+			},
+			want:      []byte{0x5F, 0x20, 0x02, 0x01, 0x02},
+			assertion: assert.NoError,
+		},
+		{
+			name: "long length",
+			o: Object{
+				Tag:   TagFromUintForced(0xC4),
+				Value: make([]byte, 200),
+			},
+			want:      append([]byte{0xC4, 0x81, 0xC8}, make([]byte, 200)...),
+			assertion: assert.NoError,
+		},
+		{
+			name: "invalid class",
+			o: Object{
+				Tag: Tag{Class: 7},
+			},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.o.ToBytes()
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
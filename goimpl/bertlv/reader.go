@@ -43,7 +43,7 @@ func (r Reader) Read() (bytesRead int, object Object, err error) {
 	}
 	object.Length = length
 	object.Value = make([]byte, length)
-	valueBytes, valueErr := r.data.Read(object.Value)
+	valueBytes, valueErr := io.ReadFull(r.data, object.Value)
 	bytesRead += valueBytes
 	if valueErr != nil {
 		err = fmt.Errorf("error reading value: %w", valueErr)
@@ -61,10 +61,24 @@ func (r Reader) ReadWithoutTag() (bytesRead int, object Object, err error) {
 	}
 	object.Length = length
 	object.Value = make([]byte, length)
-	valueBytes, valueErr := r.data.Read(object.Value)
+	valueBytes, valueErr := io.ReadFull(r.data, object.Value)
 	bytesRead += valueBytes
 	if valueErr != nil {
 		err = fmt.Errorf("error reading value: %w", valueErr)
 	}
 	return
 }
+
+// ReadAll reads every object from a finite byte slice, failing if the data does not consist entirely of whole objects
+func ReadAll(data []byte) (objects []Object, err error) {
+	r := NewBytesReader(data)
+	for offset := 0; offset < len(data); {
+		n, object, readErr := r.Read()
+		if readErr != nil {
+			return nil, fmt.Errorf("reading object at offset %d: %w", offset, readErr)
+		}
+		objects = append(objects, object)
+		offset += n
+	}
+	return
+}
@@ -69,3 +69,34 @@ func TestReader_Read(t *testing.T) {
 		})
 	}
 }
+
+func TestReadAll(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        []byte
+		wantObjects []Object
+		assertion   assert.ErrorAssertionFunc
+	}{
+		{
+			name: "two objects",
+			data: []byte{0x4F, 0x02, 0xA0, 0x00, 0x9F, 0x70, 0x01, 0x07},
+			wantObjects: []Object{
+				{Tag: Tag{Class: TagClassApplication, Number: 15}, Length: 2, Value: []byte{0xA0, 0x00}},
+				{Tag: Tag{Class: TagClassContextSpecific, ConstructedEncoding: false, Number: 0x70}, Length: 1, Value: []byte{0x07}},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "truncated value",
+			data:      []byte{0x4F, 0x05, 0xA0, 0x00},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotObjects, err := ReadAll(tt.data)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.wantObjects, gotObjects)
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package capfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// ComponentTag identifies a CAP file component
+type ComponentTag byte
+
+const (
+	// ComponentHeader is the Header component
+	ComponentHeader ComponentTag = 1
+	// ComponentDirectory is the Directory component
+	ComponentDirectory ComponentTag = 2
+	// ComponentApplet is the Applet component
+	ComponentApplet ComponentTag = 3
+	// ComponentImport is the Import component
+	ComponentImport ComponentTag = 4
+	// ComponentConstantPool is the ConstantPool component
+	ComponentConstantPool ComponentTag = 5
+	// ComponentClass is the Class component
+	ComponentClass ComponentTag = 6
+	// ComponentMethod is the Method component
+	ComponentMethod ComponentTag = 7
+	// ComponentStaticField is the StaticField component
+	ComponentStaticField ComponentTag = 8
+	// ComponentReferenceLocation is the RefLocation component
+	ComponentReferenceLocation ComponentTag = 9
+	// ComponentExport is the Export component
+	ComponentExport ComponentTag = 10
+	// ComponentDescriptor is the Descriptor component
+	ComponentDescriptor ComponentTag = 11
+	// ComponentDebug is the Debug component
+	ComponentDebug ComponentTag = 12
+)
+
+// componentNames maps the file names of components within the javacard directory to their tags
+var componentNames = map[string]ComponentTag{
+	"Header.cap":       ComponentHeader,
+	"Directory.cap":    ComponentDirectory,
+	"Applet.cap":       ComponentApplet,
+	"Import.cap":       ComponentImport,
+	"ConstantPool.cap": ComponentConstantPool,
+	"Class.cap":        ComponentClass,
+	"Method.cap":       ComponentMethod,
+	"StaticField.cap":  ComponentStaticField,
+	"RefLocation.cap":  ComponentReferenceLocation,
+	"Export.cap":       ComponentExport,
+	"Descriptor.cap":   ComponentDescriptor,
+	"Debug.cap":        ComponentDebug,
+}
+
+// loadOrder is the order components are sent to the card in, the Descriptor and Debug components are optional
+var loadOrder = []ComponentTag{
+	ComponentHeader,
+	ComponentDirectory,
+	ComponentImport,
+	ComponentApplet,
+	ComponentClass,
+	ComponentMethod,
+	ComponentStaticField,
+	ComponentExport,
+	ComponentConstantPool,
+	ComponentReferenceLocation,
+	ComponentDescriptor,
+	ComponentDebug,
+}
+
+// headerMagic starts every Header component
+var headerMagic = []byte{0xDE, 0xCA, 0xFF, 0xED}
+
+// PackageInfo identifies a package and its version
+type PackageInfo struct {
+	AID          []byte
+	MajorVersion byte
+	MinorVersion byte
+}
+
+// Applet is an applet defined in the package
+type Applet struct {
+	AID                 []byte
+	InstallMethodOffset uint16
+}
+
+// CAP is a parsed CAP file
+type CAP struct {
+	Package PackageInfo
+	// Name is the fully qualified package name, only present from CAP format 2.2
+	Name       string
+	Applets    []Applet
+	Imports    []PackageInfo
+	components map[ComponentTag][]byte
+}
+
+// Open reads a CAP file from disk
+func Open(name string) (*CAP, error) {
+	r, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, fmt.Errorf("opening CAP file: %w", err)
+	}
+	defer r.Close()
+	return fromZip(&r.Reader)
+}
+
+// Read reads a CAP file from a reader
+func Read(r io.ReaderAt, size int64) (*CAP, error) {
+	z, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("reading CAP file: %w", err)
+	}
+	return fromZip(z)
+}
+
+func fromZip(z *zip.Reader) (*CAP, error) {
+	c := &CAP{components: map[ComponentTag][]byte{}}
+	for _, f := range z.File {
+		dir, name := path.Split(f.Name)
+		tag, ok := componentNames[name]
+		if !ok || !strings.HasSuffix(dir, "javacard/") {
+			continue
+		}
+		if _, exists := c.components[tag]; exists {
+			return nil, fmt.Errorf("more than one %s component, only single package CAP files are supported", name)
+		}
+		data, err := readFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", f.Name, err)
+		}
+		if len(data) < 3 || ComponentTag(data[0]) != tag {
+			return nil, fmt.Errorf("%s does not start with component tag %d", f.Name, tag)
+		}
+		if size := int(data[1])<<8 | int(data[2]); size != len(data)-3 {
+			return nil, fmt.Errorf("%s is %d bytes but declares %d", f.Name, len(data)-3, size)
+		}
+		c.components[tag] = data
+	}
+	for _, required := range []ComponentTag{ComponentHeader, ComponentDirectory, ComponentImport, ComponentClass, ComponentMethod, ComponentStaticField, ComponentConstantPool, ComponentReferenceLocation} {
+		if _, ok := c.components[required]; !ok {
+			return nil, fmt.Errorf("missing required component %d", required)
+		}
+	}
+	if err := c.parseHeader(); err != nil {
+		return nil, fmt.Errorf("parsing Header component: %w", err)
+	}
+	if err := c.parseApplets(); err != nil {
+		return nil, fmt.Errorf("parsing Applet component: %w", err)
+	}
+	if err := c.parseImports(); err != nil {
+		return nil, fmt.Errorf("parsing Import component: %w", err)
+	}
+	return c, nil
+}
+
+func readFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// componentReader reads the info of a component, after its tag and size
+type componentReader struct {
+	data []byte
+	err  error
+}
+
+func (r *componentReader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n > len(r.data) {
+		r.err = fmt.Errorf("component truncated")
+		return nil
+	}
+	out := append([]byte{}, r.data[:n]...)
+	r.data = r.data[n:]
+	return out
+}
+
+func (r *componentReader) u1() byte {
+	b := r.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (r *componentReader) u2() uint16 {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return uint16(b[0])<<8 | uint16(b[1])
+}
+
+// packageInfo reads a minor version, major version, AID length and AID
+func (r *componentReader) packageInfo() PackageInfo {
+	minor := r.u1()
+	major := r.u1()
+	aid := r.bytes(int(r.u1()))
+	return PackageInfo{AID: aid, MajorVersion: major, MinorVersion: minor}
+}
+
+func (c *CAP) reader(tag ComponentTag) *componentReader {
+	return &componentReader{data: c.components[tag][3:]}
+}
+
+func (c *CAP) parseHeader() error {
+	r := c.reader(ComponentHeader)
+	if magic := r.bytes(4); r.err == nil && !bytes.Equal(magic, headerMagic) {
+		return fmt.Errorf("bad magic %X", magic)
+	}
+	minor := r.u1()
+	major := r.u1()
+	r.u1() // Flags
+	c.Package = r.packageInfo()
+	// The package name was added in format 2.2
+	if r.err == nil && (major > 2 || (major == 2 && minor >= 2)) && len(r.data) > 0 {
+		c.Name = string(r.bytes(int(r.u1())))
+	}
+	return r.err
+}
+
+func (c *CAP) parseApplets() error {
+	if _, ok := c.components[ComponentApplet]; !ok {
+		// Library packages have no applets
+		return nil
+	}
+	r := c.reader(ComponentApplet)
+	count := int(r.u1())
+	for i := 0; i < count && r.err == nil; i++ {
+		aid := r.bytes(int(r.u1()))
+		c.Applets = append(c.Applets, Applet{AID: aid, InstallMethodOffset: r.u2()})
+	}
+	return r.err
+}
+
+func (c *CAP) parseImports() error {
+	r := c.reader(ComponentImport)
+	count := int(r.u1())
+	for i := 0; i < count && r.err == nil; i++ {
+		c.Imports = append(c.Imports, r.packageInfo())
+	}
+	return r.err
+}
+
+// Component returns the raw component including its tag and size, or nil if the CAP file does not contain it
+func (c *CAP) Component(tag ComponentTag) []byte {
+	return c.components[tag]
+}
+
+// LoadOptions selects the optional components of the load file data block
+type LoadOptions struct {
+	IncludeDescriptor bool
+	IncludeDebug      bool
+}
+
+// LoadFileDataBlock concatenates the components in the order the card expects them
+func (c *CAP) LoadFileDataBlock(opts LoadOptions) []byte {
+	var out []byte
+	for _, tag := range loadOrder {
+		if (tag == ComponentDescriptor && !opts.IncludeDescriptor) || (tag == ComponentDebug && !opts.IncludeDebug) {
+			continue
+		}
+		out = append(out, c.components[tag]...)
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package capfile
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testPackageAID = []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01}
+	testAppletAID  = []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x01}
+	javacardAID    = []byte{0xA0, 0x00, 0x00, 0x00, 0x62, 0x01, 0x01}
+)
+
+// component builds a component with its tag and size
+func component(tag ComponentTag, info ...byte) []byte {
+	return append([]byte{byte(tag), byte(len(info) >> 8), byte(len(info))}, info...)
+}
+
+// testComponents are the components of a minimal format 2.2 CAP file with one applet
+func testComponents() map[string][]byte {
+	header := append([]byte{0xDE, 0xCA, 0xFF, 0xED, 0x02, 0x02, 0x04, 0x00, 0x01, byte(len(testPackageAID))}, testPackageAID...)
+	header = append(header, 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e')
+	return map[string][]byte{
+		"Header.cap":       component(ComponentHeader, header...),
+		"Directory.cap":    component(ComponentDirectory, 0x02),
+		"Import.cap":       component(ComponentImport, append([]byte{0x01, 0x03, 0x01, byte(len(javacardAID))}, javacardAID...)...),
+		"Applet.cap":       component(ComponentApplet, append(append([]byte{0x01, byte(len(testAppletAID))}, testAppletAID...), 0x00, 0x10)...),
+		"Class.cap":        component(ComponentClass, 0x06),
+		"Method.cap":       component(ComponentMethod, 0x07),
+		"StaticField.cap":  component(ComponentStaticField, 0x08),
+		"ConstantPool.cap": component(ComponentConstantPool, 0x05),
+		"RefLocation.cap":  component(ComponentReferenceLocation, 0x09),
+		"Descriptor.cap":   component(ComponentDescriptor, 0x0B),
+		"Debug.cap":        component(ComponentDebug, 0x0C),
+	}
+}
+
+func buildZip(t *testing.T, components map[string][]byte) []byte {
+	buf := bytes.NewBuffer(nil)
+	w := zip.NewWriter(buf)
+	f, _ := w.Create("META-INF/MANIFEST.MF")
+	f.Write([]byte("Manifest-Version: 1.0\n"))
+	for name, data := range components {
+		f, err := w.Create("com/example/javacard/" + name)
+		assert.NoError(t, err)
+		f.Write(data)
+	}
+	assert.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	valid := testComponents()
+	missing := testComponents()
+	delete(missing, "Method.cap")
+	badMagic := testComponents()
+	badMagic["Header.cap"] = component(ComponentHeader, 0xCA, 0xFE, 0xBA, 0xBE, 0x02, 0x01, 0x00, 0x00, 0x01, 0x00)
+	badSize := testComponents()
+	badSize["Class.cap"] = []byte{0x06, 0x00, 0x05, 0x00}
+	truncated := testComponents()
+	truncated["Applet.cap"] = component(ComponentApplet, 0x01, 0x07, 0xA0)
+	tests := []struct {
+		name      string
+		files     map[string][]byte
+		want      *CAP
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "valid",
+			files: valid,
+			want: &CAP{
+				Package:    PackageInfo{AID: testPackageAID, MajorVersion: 1, MinorVersion: 0},
+				Name:       "example",
+				Applets:    []Applet{{AID: testAppletAID, InstallMethodOffset: 0x10}},
+				Imports:    []PackageInfo{{AID: javacardAID, MajorVersion: 1, MinorVersion: 3}},
+				components: map[ComponentTag][]byte{},
+			},
+			assertion: assert.NoError,
+		},
+		{name: "missing component", files: missing, assertion: assert.Error},
+		{name: "bad magic", files: badMagic, assertion: assert.Error},
+		{name: "bad size", files: badSize, assertion: assert.Error},
+		{name: "truncated applet", files: truncated, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildZip(t, tt.files)
+			got, err := Read(bytes.NewReader(data), int64(len(data)))
+			tt.assertion(t, err)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			// Components are checked through LoadFileDataBlock
+			got.components = map[ComponentTag][]byte{}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCAP_LoadFileDataBlock(t *testing.T) {
+	files := testComponents()
+	data := buildZip(t, files)
+	c, err := Read(bytes.NewReader(data), int64(len(data)))
+	if !assert.NoError(t, err) {
+		return
+	}
+	var want []byte
+	for _, name := range []string{"Header.cap", "Directory.cap", "Import.cap", "Applet.cap", "Class.cap", "Method.cap", "StaticField.cap", "ConstantPool.cap", "RefLocation.cap"} {
+		want = append(want, files[name]...)
+	}
+	assert.Equal(t, want, c.LoadFileDataBlock(LoadOptions{}))
+	withDescriptor := append(append([]byte{}, want...), files["Descriptor.cap"]...)
+	assert.Equal(t, withDescriptor, c.LoadFileDataBlock(LoadOptions{IncludeDescriptor: true}))
+	assert.Equal(t, append(withDescriptor, files["Debug.cap"]...), c.LoadFileDataBlock(LoadOptions{IncludeDescriptor: true, IncludeDebug: true}))
+	assert.Equal(t, files["Applet.cap"], c.Component(ComponentApplet))
+	assert.Nil(t, c.Component(ComponentExport))
+}
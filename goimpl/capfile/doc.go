@@ -0,0 +1,2 @@
+// Package capfile reads Java Card CAP files and assembles the load file data block sent with LOAD
+package capfile
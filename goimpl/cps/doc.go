@@ -0,0 +1,9 @@
+// Package cps reads personalization files in the style of the EMV Card Personalization Specification and personalizes applications with them using STORE DATA.
+//
+// A file is a sequence of application sections, each laid out as
+//
+//	AID length (1 byte) | AID | DGI data length (2 bytes) | DGIs | encrypted DGI count (1 byte) | encrypted DGI identifiers (2 bytes each)
+//
+// where the DGIs listed as encrypted are enciphered with the secure channel DEK before they are sent.
+// Data preparation pads the values of DGIs to be encrypted to the cipher block length, as the specification requires.
+package cps
@@ -0,0 +1,146 @@
+package cps
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/llkennedy/globalplatform/goimpl/dgi"
+)
+
+// Group is one DGI of an application's personalization data
+type Group struct {
+	dgi.Object
+	// Encrypt marks the DGI to be enciphered with the secure channel DEK
+	Encrypt bool
+}
+
+// Application is the personalization data of one application, in the order it is sent to the card
+type Application struct {
+	AID    []byte
+	Groups []Group
+}
+
+// File is a personalization file for one card
+type File struct {
+	Applications []Application
+}
+
+// Read reads a whole personalization file
+func Read(r io.Reader) (File, error) {
+	if r == nil {
+		return File{}, fmt.Errorf("invalid data, must not be nil")
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return File{}, fmt.Errorf("reading personalization file: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse parses a personalization file
+func Parse(data []byte) (File, error) {
+	r := bytes.NewReader(data)
+	var file File
+	for r.Len() > 0 {
+		app, err := readApplication(r)
+		if err != nil {
+			return File{}, fmt.Errorf("application %d: %w", len(file.Applications), err)
+		}
+		file.Applications = append(file.Applications, app)
+	}
+	if len(file.Applications) == 0 {
+		return File{}, fmt.Errorf("personalization file has no applications")
+	}
+	return file, nil
+}
+
+func readApplication(r *bytes.Reader) (Application, error) {
+	aidLength, err := r.ReadByte()
+	if err != nil {
+		return Application{}, fmt.Errorf("reading AID length: %w", err)
+	}
+	if aidLength < 5 || aidLength > 16 {
+		return Application{}, fmt.Errorf("AID must be between 5 and 16 bytes, got %d", aidLength)
+	}
+	app := Application{AID: make([]byte, aidLength)}
+	if _, err = io.ReadFull(r, app.AID); err != nil {
+		return Application{}, fmt.Errorf("reading AID: %w", err)
+	}
+	var length [2]byte
+	if _, err = io.ReadFull(r, length[:]); err != nil {
+		return Application{}, fmt.Errorf("reading DGI data length: %w", err)
+	}
+	groupData := make([]byte, int(length[0])<<8|int(length[1]))
+	if _, err = io.ReadFull(r, groupData); err != nil {
+		return Application{}, fmt.Errorf("reading DGI data: %w", err)
+	}
+	objects, err := dgi.ReadAll(groupData)
+	if err != nil {
+		return Application{}, err
+	}
+	if len(objects) == 0 {
+		return Application{}, fmt.Errorf("application %X has no DGIs", app.AID)
+	}
+	for _, obj := range objects {
+		app.Groups = append(app.Groups, Group{Object: obj})
+	}
+	count, err := r.ReadByte()
+	if err != nil {
+		return Application{}, fmt.Errorf("reading encrypted DGI count: %w", err)
+	}
+	for i := 0; i < int(count); i++ {
+		if _, err = io.ReadFull(r, length[:]); err != nil {
+			return Application{}, fmt.Errorf("reading encrypted DGI identifier: %w", err)
+		}
+		id := dgi.Identifier(length[0])<<8 | dgi.Identifier(length[1])
+		found := false
+		for j := range app.Groups {
+			if app.Groups[j].Identifier == id {
+				app.Groups[j].Encrypt = true
+				found = true
+			}
+		}
+		if !found {
+			return Application{}, fmt.Errorf("DGI %04X is marked for encryption but not present", uint16(id))
+		}
+	}
+	return app, nil
+}
+
+// ToBytes encodes the file
+func (f File) ToBytes() ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	for _, app := range f.Applications {
+		if len(app.AID) < 5 || len(app.AID) > 16 {
+			return nil, fmt.Errorf("AID must be between 5 and 16 bytes, got %d", len(app.AID))
+		}
+		groups := bytes.NewBuffer(nil)
+		w, _ := dgi.NewWriter(groups)
+		var encrypted []dgi.Identifier
+		for _, group := range app.Groups {
+			if _, err := w.Write(group.Object); err != nil {
+				return nil, err
+			}
+			if group.Encrypt {
+				encrypted = append(encrypted, group.Identifier)
+			}
+		}
+		if groups.Len() > 0xFFFF {
+			return nil, fmt.Errorf("application %X has %d bytes of DGI data, at most %d are allowed", app.AID, groups.Len(), 0xFFFF)
+		}
+		if len(encrypted) > 0xFF {
+			return nil, fmt.Errorf("application %X has %d encrypted DGIs, at most 255 are allowed", app.AID, len(encrypted))
+		}
+		buf.WriteByte(byte(len(app.AID)))
+		buf.Write(app.AID)
+		buf.Write([]byte{byte(groups.Len() >> 8), byte(groups.Len())})
+		buf.Write(groups.Bytes())
+		buf.WriteByte(byte(len(encrypted)))
+		for _, id := range encrypted {
+			buf.Write([]byte{byte(id >> 8), byte(id)})
+		}
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+package cps
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/dgi"
+	"github.com/stretchr/testify/assert"
+)
+
+var testAID = []byte{0xA0, 0x00, 0x00, 0x00, 0x04, 0x10, 0x10}
+
+var testFileBytes = []byte{
+	0x07, 0xA0, 0x00, 0x00, 0x00, 0x04, 0x10, 0x10,
+	0x00, 0x0C,
+	0x01, 0x01, 0x03, 0x70, 0x01, 0x00,
+	0x80, 0x00, 0x03, 0x11, 0x22, 0x33,
+	0x01, 0x80, 0x00,
+}
+
+var testFile = File{Applications: []Application{{
+	AID: testAID,
+	Groups: []Group{
+		{Object: dgi.Object{Identifier: 0x0101, Length: 3, Value: []byte{0x70, 0x01, 0x00}}},
+		{Object: dgi.Object{Identifier: 0x8000, Length: 3, Value: []byte{0x11, 0x22, 0x33}}, Encrypt: true},
+	},
+}}}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		want      File
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "empty",
+			assertion: assert.Error,
+		},
+		{
+			name:      "one application",
+			data:      testFileBytes,
+			want:      testFile,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "short AID",
+			data:      []byte{0x04, 0xA0, 0x00, 0x00, 0x00},
+			assertion: assert.Error,
+		},
+		{
+			name:      "truncated DGI data",
+			data:      testFileBytes[:15],
+			assertion: assert.Error,
+		},
+		{
+			name:      "no DGIs",
+			data:      []byte{0x05, 0xA0, 0x00, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00},
+			assertion: assert.Error,
+		},
+		{
+			name:      "missing encrypted DGI count",
+			data:      testFileBytes[:22],
+			assertion: assert.Error,
+		},
+		{
+			name:      "encrypted DGI not present",
+			data:      append(append([]byte{}, testFileBytes[:22]...), 0x01, 0x90, 0x00),
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.data)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	_, err := Read(nil)
+	assert.Error(t, err)
+	got, err := Read(bytes.NewReader(testFileBytes))
+	assert.NoError(t, err)
+	assert.Equal(t, testFile, got)
+}
+
+func TestFile_ToBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      File
+		want      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "one application",
+			file:      testFile,
+			want:      testFileBytes,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "bad AID",
+			file:      File{Applications: []Application{{AID: []byte{0xA0}}}},
+			assertion: assert.Error,
+		},
+		{
+			name:      "DGI too long",
+			file:      File{Applications: []Application{{AID: testAID, Groups: []Group{{Object: dgi.Object{Identifier: 0x0101, Value: make([]byte, dgi.MaxLength+1)}}}}}},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.file.ToBytes()
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package cps
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+)
+
+// Options configures personalization
+type Options struct {
+	// OpenSession opens a secure channel with the selected application's security domain on the transport
+	OpenSession gpapdu.OpenSecureChannel
+	// BlockSize is passed on to every STORE DATA sequence
+	BlockSize int
+}
+
+// Commands maps the DGIs to STORE DATA sequences, one per run of DGIs with the same encryption.
+// Block numbering continues across the sequences and only the last block of the last sequence is flagged as the last.
+// The DGIs marked for encryption are enciphered with the DEK, which may be nil if none are.
+func (a Application) Commands(dek gpapdu.SensitiveDataEncryptor, blockSize int) ([]gpapdu.StoreDataCommand, error) {
+	var commands []gpapdu.StoreDataCommand
+	for i := 0; i < len(a.Groups); {
+		encrypt := a.Groups[i].Encrypt
+		cmd := gpapdu.StoreDataCommand{Format: gpapdu.StoreDataDGIFormat, BlockSize: blockSize, MoreFollows: true}
+		if encrypt {
+			cmd.Encryption = gpapdu.StoreDataEncrypted
+		}
+		for ; i < len(a.Groups) && a.Groups[i].Encrypt == encrypt; i++ {
+			group := a.Groups[i].Object
+			if encrypt {
+				if dek == nil {
+					return nil, fmt.Errorf("DGI %04X must be encrypted but there is no DEK", uint16(group.Identifier))
+				}
+				enciphered, err := dek.EncryptSensitiveData(group.Value)
+				if err != nil {
+					return nil, fmt.Errorf("enciphering DGI %04X: %w", uint16(group.Identifier), err)
+				}
+				group.Value = enciphered
+			}
+			record, err := group.ToBytes()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Records = append(cmd.Records, record)
+		}
+		commands = append(commands, cmd)
+	}
+	if len(commands) > 0 {
+		commands[len(commands)-1].MoreFollows = false
+	}
+	return commands, nil
+}
+
+// Personalize selects the application, opens a secure channel with it and sends its DGIs with STORE DATA
+func Personalize(transport apdu.Transport, app Application, opts Options) error {
+	session, err := gpapdu.SelectAndOpen(transport, app.AID, opts.OpenSession)
+	if err != nil {
+		return err
+	}
+	commands, err := app.Commands(session, opts.BlockSize)
+	if err != nil {
+		return err
+	}
+	client := gpapdu.NewClient(session)
+	var next byte
+	for _, cmd := range commands {
+		cmd.FirstBlockNumber = next
+		probe, err := cmd.Commands()
+		if err != nil {
+			return fmt.Errorf("personalizing %X: %w", app.AID, err)
+		}
+		if _, err = client.StoreData(cmd); err != nil {
+			return fmt.Errorf("personalizing %X: %w", app.AID, err)
+		}
+		next += byte(len(probe))
+	}
+	return nil
+}
+
+// PersonalizeFile personalizes every application in the file in order
+func PersonalizeFile(transport apdu.Transport, file File, opts Options) error {
+	for _, app := range file.Applications {
+		if err := Personalize(transport, app, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
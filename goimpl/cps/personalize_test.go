@@ -0,0 +1,153 @@
+package cps
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/dgi"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCard records commands, answering 9000 to all of them
+type testCard struct {
+	commands []apdu.Command
+}
+
+func (c *testCard) Send(cmd apdu.Command) (apdu.Response, error) {
+	c.commands = append(c.commands, cmd)
+	return apdu.Response{Status: apdu.RawStatus{SW1: 0x90}.Identify()}, nil
+}
+
+// testSession passes commands to the card unwrapped and "enciphers" by inverting every byte
+type testSession struct {
+	apdu.Transport
+}
+
+func (s testSession) EncryptSensitiveData(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = ^data[i]
+	}
+	return out, nil
+}
+
+func openTestSession(transport apdu.Transport, aid []byte) (gpapdu.SecureChannel, error) {
+	return testSession{transport}, nil
+}
+
+func TestApplication_Commands(t *testing.T) {
+	clear := Group{Object: dgi.Object{Identifier: 0x0101, Value: []byte{0x70, 0x01, 0x00}}}
+	secret := Group{Object: dgi.Object{Identifier: 0x8000, Value: []byte{0x11, 0x22}}, Encrypt: true}
+	tests := []struct {
+		name      string
+		app       Application
+		dek       gpapdu.SensitiveDataEncryptor
+		want      []gpapdu.StoreDataCommand
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "no DGIs",
+			assertion: assert.NoError,
+		},
+		{
+			name: "plain only",
+			app:  Application{AID: testAID, Groups: []Group{clear, clear}},
+			want: []gpapdu.StoreDataCommand{
+				{Records: [][]byte{{0x01, 0x01, 0x03, 0x70, 0x01, 0x00}, {0x01, 0x01, 0x03, 0x70, 0x01, 0x00}}, Format: gpapdu.StoreDataDGIFormat, BlockSize: 100},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "runs",
+			app:  Application{AID: testAID, Groups: []Group{clear, secret, clear}},
+			dek:  testSession{},
+			want: []gpapdu.StoreDataCommand{
+				{Records: [][]byte{{0x01, 0x01, 0x03, 0x70, 0x01, 0x00}}, Format: gpapdu.StoreDataDGIFormat, BlockSize: 100, MoreFollows: true},
+				{Records: [][]byte{{0x80, 0x00, 0x02, 0xEE, 0xDD}}, Format: gpapdu.StoreDataDGIFormat, Encryption: gpapdu.StoreDataEncrypted, BlockSize: 100, MoreFollows: true},
+				{Records: [][]byte{{0x01, 0x01, 0x03, 0x70, 0x01, 0x00}}, Format: gpapdu.StoreDataDGIFormat, BlockSize: 100},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "encryption without DEK",
+			app:       Application{AID: testAID, Groups: []Group{secret}},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.app.Commands(tt.dek, 100)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPersonalize(t *testing.T) {
+	app := Application{AID: testAID, Groups: []Group{
+		{Object: dgi.Object{Identifier: 0x0101, Value: []byte{0x70, 0x01, 0x00}}},
+		{Object: dgi.Object{Identifier: 0x0102, Value: []byte{0x70, 0x01, 0x01}}},
+		{Object: dgi.Object{Identifier: 0x8000, Value: []byte{0x11, 0x22}}, Encrypt: true},
+	}}
+	tests := []struct {
+		name         string
+		transport    apdu.Transport
+		app          Application
+		opts         Options
+		wantCommands []apdu.Command
+		assertion    assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "nil transport",
+			assertion: assert.Error,
+		},
+		{
+			name:      "no session",
+			transport: &testCard{},
+			app:       app,
+			assertion: assert.Error,
+		},
+		{
+			name:      "session fails",
+			transport: &testCard{},
+			app:       app,
+			opts: Options{OpenSession: func(apdu.Transport, []byte) (gpapdu.SecureChannel, error) {
+				return nil, fmt.Errorf("authentication failed")
+			}},
+			wantCommands: []apdu.Command{
+				{Class: gpapdu.Class{}, Instruction: apdu.InstructionSelect, P1: 0x04, Data: testAID, ExpectedResponseLength: 256},
+			},
+			assertion: assert.Error,
+		},
+		{
+			name:      "blocks numbered across runs",
+			transport: &testCard{},
+			app:       app,
+			opts:      Options{OpenSession: openTestSession, BlockSize: 6},
+			wantCommands: []apdu.Command{
+				{Class: gpapdu.Class{}, Instruction: apdu.InstructionSelect, P1: 0x04, Data: testAID, ExpectedResponseLength: 256},
+				{Class: gpapdu.Class{IsGPCommand: true}, Instruction: gpapdu.InstructionStoreData, P1: 0x08, P2: 0x00, Data: []byte{0x01, 0x01, 0x03, 0x70, 0x01, 0x00}},
+				{Class: gpapdu.Class{IsGPCommand: true}, Instruction: gpapdu.InstructionStoreData, P1: 0x08, P2: 0x01, Data: []byte{0x01, 0x02, 0x03, 0x70, 0x01, 0x01}},
+				{Class: gpapdu.Class{IsGPCommand: true}, Instruction: gpapdu.InstructionStoreData, P1: 0xE8, P2: 0x02, Data: []byte{0x80, 0x00, 0x02, 0xEE, 0xDD}},
+			},
+			assertion: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.assertion(t, Personalize(tt.transport, tt.app, tt.opts))
+			if card, ok := tt.transport.(*testCard); ok {
+				assert.Equal(t, tt.wantCommands, card.commands)
+			}
+		})
+	}
+}
+
+func TestPersonalizeFile(t *testing.T) {
+	card := &testCard{}
+	assert.NoError(t, PersonalizeFile(card, testFile, Options{OpenSession: openTestSession}))
+	assert.Len(t, card.commands, 3)
+	assert.Error(t, PersonalizeFile(card, testFile, Options{}))
+}
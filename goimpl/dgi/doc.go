@@ -0,0 +1,2 @@
+// Package dgi handles reading and writing data grouped by Data Grouping Identifiers (DGIs), the format of most personalization data sent with STORE DATA
+package dgi
@@ -0,0 +1,43 @@
+package dgi
+
+import (
+	"fmt"
+	"io"
+)
+
+// MaxLength is the longest value a DGI can hold
+const MaxLength = 0xFFFF
+
+// LengthFromReader reads a length value from the reader, which is 1 byte below 255 and otherwise FF followed by 2 bytes
+func LengthFromReader(data io.Reader) (bytesRead int, length uint16, err error) {
+	dst := make([]byte, 1)
+	bytesRead, err = io.ReadFull(data, dst)
+	if err != nil {
+		return
+	}
+	if dst[0] != 0xFF {
+		length = uint16(dst[0])
+		return
+	}
+	long := make([]byte, 2)
+	read, readErr := io.ReadFull(data, long)
+	bytesRead += read
+	if readErr != nil {
+		err = fmt.Errorf("could not read length data: %w", readErr)
+		return
+	}
+	length = uint16(long[0])<<8 | uint16(long[1])
+	return
+}
+
+// LengthToBytes converts a length to properly encoded length bytes
+func LengthToBytes(length int) ([]byte, error) {
+	switch {
+	case length < 0 || length > MaxLength:
+		return nil, fmt.Errorf("DGI length must be between 0 and %d, got %d", MaxLength, length)
+	case length < 0xFF:
+		return []byte{byte(length)}, nil
+	default:
+		return []byte{0xFF, byte(length >> 8), byte(length)}, nil
+	}
+}
@@ -0,0 +1,55 @@
+package dgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLengthFromReader(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		wantBytesRead int
+		wantLength    uint16
+		assertion     assert.ErrorAssertionFunc
+	}{
+		{name: "short", data: []byte{0x10}, wantBytesRead: 1, wantLength: 0x10, assertion: assert.NoError},
+		{name: "longest short", data: []byte{0xFE}, wantBytesRead: 1, wantLength: 0xFE, assertion: assert.NoError},
+		{name: "long", data: []byte{0xFF, 0x01, 0x00}, wantBytesRead: 3, wantLength: 0x100, assertion: assert.NoError},
+		{name: "truncated long", data: []byte{0xFF, 0x01}, wantBytesRead: 2, assertion: assert.Error},
+		{name: "empty", data: []byte{}, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBytesRead, gotLength, err := LengthFromReader(bytes.NewReader(tt.data))
+			tt.assertion(t, err)
+			assert.Equal(t, tt.wantBytesRead, gotBytesRead)
+			assert.Equal(t, tt.wantLength, gotLength)
+		})
+	}
+}
+
+func TestLengthToBytes(t *testing.T) {
+	tests := []struct {
+		name      string
+		length    int
+		want      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "zero", length: 0, want: []byte{0x00}, assertion: assert.NoError},
+		{name: "longest short", length: 0xFE, want: []byte{0xFE}, assertion: assert.NoError},
+		{name: "shortest long", length: 0xFF, want: []byte{0xFF, 0x00, 0xFF}, assertion: assert.NoError},
+		{name: "longest", length: MaxLength, want: []byte{0xFF, 0xFF, 0xFF}, assertion: assert.NoError},
+		{name: "too long", length: MaxLength + 1, assertion: assert.Error},
+		{name: "negative", length: -1, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LengthToBytes(tt.length)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package dgi
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+)
+
+// Identifier is a 2 byte Data Grouping Identifier
+type Identifier uint16
+
+// Object is a full DGI object
+type Object struct {
+	Identifier Identifier
+	// Length should be purely decorative and merely represent the length of the Value slice, but in the case of a malformed object Length represents the length which was encoded, regardless of value's real length
+	// During encoding, Length is always ignored.
+	Length uint16
+	Value  []byte
+}
+
+// ToBytes encodes the object, the Length field is ignored in favour of the real length of Value
+func (o Object) ToBytes() ([]byte, error) {
+	length, err := LengthToBytes(len(o.Value))
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte{byte(o.Identifier >> 8), byte(o.Identifier)}, length...)
+	return append(out, o.Value...), nil
+}
+
+// FromBERTLV creates a DGI object whose value is the BER-TLV objects
+func FromBERTLV(identifier Identifier, objects []bertlv.Object) (Object, error) {
+	var value []byte
+	for i, obj := range objects {
+		encoded, err := obj.ToBytes()
+		if err != nil {
+			return Object{}, fmt.Errorf("encoding object %d: %w", i, err)
+		}
+		value = append(value, encoded...)
+	}
+	if len(value) > MaxLength {
+		return Object{}, fmt.Errorf("DGI %04X would be %d bytes, at most %d are allowed", uint16(identifier), len(value), MaxLength)
+	}
+	return Object{Identifier: identifier, Length: uint16(len(value)), Value: value}, nil
+}
+
+// BERTLV reads the value of the object as BER-TLV objects, failing if it does not consist entirely of whole objects
+func (o Object) BERTLV() ([]bertlv.Object, error) {
+	objects, err := bertlv.ReadAll(o.Value)
+	if err != nil {
+		return nil, fmt.Errorf("DGI %04X does not contain BER-TLV: %w", uint16(o.Identifier), err)
+	}
+	return objects, nil
+}
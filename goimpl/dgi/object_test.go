@@ -0,0 +1,28 @@
+package dgi
+
+import (
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFromBERTLV(t *testing.T) {
+	objects := []bertlv.Object{
+		{Tag: bertlv.TagFromUintForced(0x70), Length: 3, Value: []byte{0x5A, 0x01, 0x12}},
+		{Tag: bertlv.TagFromUintForced(0x9F46), Length: 1, Value: []byte{0xAA}},
+	}
+	got, err := FromBERTLV(0x0101, objects)
+	assert.NoError(t, err)
+	assert.Equal(t, Object{Identifier: 0x0101, Length: 9, Value: []byte{0x70, 0x03, 0x5A, 0x01, 0x12, 0x9F, 0x46, 0x01, 0xAA}}, got)
+	back, err := got.BERTLV()
+	assert.NoError(t, err)
+	assert.Equal(t, objects, back)
+	encoded, err := got.ToBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte{0x01, 0x01, 0x09}, got.Value...), encoded)
+	_, err = Object{Identifier: 0x8000, Value: []byte{0x01, 0x05}}.BERTLV()
+	assert.Error(t, err)
+	_, err = FromBERTLV(0x0101, []bertlv.Object{{Tag: bertlv.TagFromUintForced(0x70), Value: make([]byte, MaxLength)}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,66 @@
+package dgi
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Reader reads DGI data one object at a time from a data stream
+type Reader struct {
+	data io.Reader
+}
+
+// NewReader creates a new Reader
+func NewReader(data io.Reader) (r Reader, err error) {
+	if data == nil {
+		err = fmt.Errorf("invalid data, must not be nil")
+	} else {
+		r = Reader{data}
+	}
+	return
+}
+
+// NewBytesReader creates a new Reader from raw bytes
+func NewBytesReader(data []byte) (r Reader) {
+	return Reader{bytes.NewReader(data)}
+}
+
+// Read reads the next object from data
+func (r Reader) Read() (bytesRead int, object Object, err error) {
+	identifier := make([]byte, 2)
+	bytesRead, err = io.ReadFull(r.data, identifier)
+	if err != nil {
+		err = fmt.Errorf("error reading identifier: %w", err)
+		return
+	}
+	object.Identifier = Identifier(identifier[0])<<8 | Identifier(identifier[1])
+	lengthBytes, length, lengthErr := LengthFromReader(r.data)
+	bytesRead += lengthBytes
+	if lengthErr != nil {
+		err = fmt.Errorf("error reading length: %w", lengthErr)
+		return
+	}
+	object.Length = length
+	object.Value = make([]byte, length)
+	valueBytes, valueErr := io.ReadFull(r.data, object.Value)
+	bytesRead += valueBytes
+	if valueErr != nil {
+		err = fmt.Errorf("error reading value: %w", valueErr)
+	}
+	return
+}
+
+// ReadAll reads every object from a finite byte slice, failing if the data does not consist entirely of whole objects
+func ReadAll(data []byte) (objects []Object, err error) {
+	r := NewBytesReader(data)
+	for offset := 0; offset < len(data); {
+		n, object, readErr := r.Read()
+		if readErr != nil {
+			return nil, fmt.Errorf("reading DGI at offset %d: %w", offset, readErr)
+		}
+		objects = append(objects, object)
+		offset += n
+	}
+	return
+}
@@ -0,0 +1,49 @@
+package dgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReader(t *testing.T) {
+	_, err := NewReader(nil)
+	assert.Error(t, err)
+	r, err := NewReader(bytes.NewReader([]byte{0x01, 0x01, 0x00}))
+	assert.NoError(t, err)
+	n, obj, err := r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, Object{Identifier: 0x0101, Value: []byte{}}, obj)
+}
+
+func TestReadAll(t *testing.T) {
+	long := bytes.Repeat([]byte{0xAB}, 300)
+	tests := []struct {
+		name        string
+		data        []byte
+		wantObjects []Object
+		assertion   assert.ErrorAssertionFunc
+	}{
+		{
+			name: "short and long",
+			data: append([]byte{0x01, 0x01, 0x03, 0x70, 0x01, 0x00, 0x80, 0x00, 0xFF, 0x01, 0x2C}, long...),
+			wantObjects: []Object{
+				{Identifier: 0x0101, Length: 3, Value: []byte{0x70, 0x01, 0x00}},
+				{Identifier: 0x8000, Length: 300, Value: long},
+			},
+			assertion: assert.NoError,
+		},
+		{name: "truncated identifier", data: []byte{0x01}, assertion: assert.Error},
+		{name: "truncated value", data: []byte{0x01, 0x01, 0x03, 0x70}, assertion: assert.Error},
+		{name: "empty", data: []byte{}, assertion: assert.NoError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotObjects, err := ReadAll(tt.data)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.wantObjects, gotObjects)
+		})
+	}
+}
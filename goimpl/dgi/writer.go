@@ -0,0 +1,35 @@
+package dgi
+
+import (
+	"fmt"
+	"io"
+)
+
+// Writer writes DGI data one object at a time to a data stream
+type Writer struct {
+	output io.Writer
+}
+
+// NewWriter creates a new Writer
+func NewWriter(output io.Writer) (w Writer, err error) {
+	if output == nil {
+		err = fmt.Errorf("invalid output, must not be nil")
+	} else {
+		w = Writer{output}
+	}
+	return
+}
+
+// Write writes one object, the Length field is ignored in favour of the real length of Value
+func (w Writer) Write(obj Object) (bytesWritten int, err error) {
+	encoded, encodeErr := obj.ToBytes()
+	if encodeErr != nil {
+		err = fmt.Errorf("encoding DGI %04X: %w", uint16(obj.Identifier), encodeErr)
+		return
+	}
+	bytesWritten, err = w.output.Write(encoded)
+	if err != nil {
+		err = fmt.Errorf("writing DGI %04X: %w", uint16(obj.Identifier), err)
+	}
+	return
+}
@@ -0,0 +1,22 @@
+package dgi
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter_Write(t *testing.T) {
+	_, err := NewWriter(nil)
+	assert.Error(t, err)
+	buf := bytes.NewBuffer(nil)
+	w, err := NewWriter(buf)
+	assert.NoError(t, err)
+	n, err := w.Write(Object{Identifier: 0x9102, Length: 99, Value: []byte{0x01, 0x02}})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, []byte{0x91, 0x02, 0x02, 0x01, 0x02}, buf.Bytes())
+	_, err = w.Write(Object{Identifier: 0x9102, Value: make([]byte, MaxLength+1)})
+	assert.Error(t, err)
+}
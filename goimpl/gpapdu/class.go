@@ -33,3 +33,22 @@ func (c Class) ToClassByte() byte {
 	}
 	return out
 }
+
+// ClassFromAPDUClass converts any apdu.Class to a Class, via its class byte
+func ClassFromAPDUClass(in apdu.Class) (class Class, err error) {
+	if in == nil {
+		err = fmt.Errorf("cannot convert nil class")
+		return
+	}
+	return ClassFromByte(in.ToClassByte())
+}
+
+// WithSecureMessaging returns a copy of the class with the GP secure messaging indicator set, which is b3 on logical channels 0-3 and b6 on logical channels 4-19
+func (c Class) WithSecureMessaging() Class {
+	if c.LogicalChannelNumber < 4 {
+		c.SecureMessaging = apdu.CLASMProprietary
+	} else {
+		c.SecureMessaging = apdu.CLASMISONoHeaderProcessing
+	}
+	return c
+}
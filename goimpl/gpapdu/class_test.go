@@ -50,3 +50,32 @@ func TestClass_ToClassByte(t *testing.T) {
 		})
 	}
 }
+
+func TestClass_WithSecureMessaging(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Class
+		want byte
+	}{
+		{
+			name: "basic channel",
+			c:    Class{IsGPCommand: true},
+			want: 0x84,
+		},
+		{
+			name: "channel 3",
+			c:    Class{IsGPCommand: true, InterindustryClass: apdu.InterindustryClass{LogicalChannelNumber: 3}},
+			want: 0x87,
+		},
+		{
+			name: "channel 5",
+			c:    Class{IsGPCommand: true, InterindustryClass: apdu.InterindustryClass{LogicalChannelNumber: 5}},
+			want: 0xE1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.c.WithSecureMessaging().ToClassByte())
+		})
+	}
+}
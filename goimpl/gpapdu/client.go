@@ -16,37 +16,67 @@ func NewClient(transport apdu.Transport) *Client {
 	return &Client{transport}
 }
 
-// Delete deletes keys or card contents
-func (c *Client) Delete(deleteRelatedObjects bool, cmd DeleteCommand) (confirmation []byte, err error) {
+// Delete deletes keys or card contents on the logical channel, returning the delegated management confirmation if the card sent one
+func (c *Client) Delete(deleteRelatedObjects bool, cmd DeleteCommand, logicalChannel uint8) (confirmation *ResponseConfirmation, err error) {
 	if cmd == nil {
 		return nil, fmt.Errorf("must supply a non-nil command")
 	}
-	fullData := cmd.unimplementableDeleteCommandToBytes()
-	command := Command{
-		Class: Class{
-			IsGPCommand: true,
-		},
-		Instruction:        apdu.InstructionDeleteFile,
-		ExpectResponseData: true,
+	if c == nil || c.transport == nil {
+		return nil, fmt.Errorf("cannot send command with nil transport")
 	}
-	if deleteRelatedObjects {
-		command.P2 = b8
+	fullData, err := cmd.unimplementableDeleteCommandToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("encoding command: %w", err)
 	}
-	if len(fullData) > 255 {
-		command.P1 = b8
-		command.Data = fullData[:255]
-	} else {
-		command.Data = fullData
+	var res apdu.Response
+	for _, command := range deleteCommands(deleteRelatedObjects, fullData, logicalChannel) {
+		res, err = SendOnTransport(c.transport, command)
+		if err != nil {
+			return nil, fmt.Errorf("sending command: %w", err)
+		}
+		if err = res.GetStatus().Error(); err != nil {
+			return nil, err
+		}
 	}
-	// res, sendErr := SendOnTransport(c.transport, command)
-	// if sendErr != nil {
-	// 	return nil, fmt.Errorf("sending command: %w", sendErr)
-	// }
-	// FIXME: multiple sends, multiple responds to map
-	// r := bertlv.NewBytesReader(res.Data)
-	// _, obj, readErr := r.ReadWithoutTag()
-	// if readErr != nil {
-	// 	return nil, fmt.Errorf("reading response: %w", readErr)
-	// }
-	return //obj.Value, nil
+	return optionalConfirmation(res)
+}
+
+// optionalConfirmation parses the confirmation from a response to a card content management command, if the card sent one
+func optionalConfirmation(res apdu.Response) (*ResponseConfirmation, error) {
+	// Without delegated management the card returns a single 00 byte, or nothing at all
+	if len(res.Data) == 0 || (len(res.Data) == 1 && res.Data[0] == 0) {
+		return nil, nil
+	}
+	parsed, err := ConfirmationFromResponse(res)
+	if err != nil {
+		return nil, fmt.Errorf("reading confirmation: %w", err)
+	}
+	return &parsed, nil
+}
+
+// deleteCommands splits the data of a DELETE into commands of at most ChainBlockSize bytes, with P1 b8 set on all but the last
+func deleteCommands(deleteRelatedObjects bool, fullData []byte, logicalChannel uint8) []Command {
+	var commands []Command
+	for start := 0; start < len(fullData); start += ChainBlockSize {
+		end := start + ChainBlockSize
+		command := Command{
+			Class: Class{
+				InterindustryClass: apdu.InterindustryClass{
+					LogicalChannelNumber: logicalChannel,
+				},
+				IsGPCommand: true,
+			},
+			Instruction:        apdu.InstructionDeleteFile,
+			P2:                 deleteP2(deleteRelatedObjects),
+			ExpectResponseData: true,
+		}
+		if end < len(fullData) {
+			command.P1 = b8
+		} else {
+			end = len(fullData)
+		}
+		command.Data = fullData[start:end]
+		commands = append(commands, command)
+	}
+	return commands
 }
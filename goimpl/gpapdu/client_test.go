@@ -1,6 +1,7 @@
 package gpapdu
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/llkennedy/globalplatform/goimpl/apdu"
@@ -16,7 +17,15 @@ func TestNewClient(t *testing.T) {
 		args args
 		want *Client
 	}{
-		// TODO: Add test cases.
+		{
+			name: "nil transport",
+			want: &Client{},
+		},
+		{
+			name: "with transport",
+			args: args{transport: &fixedCard{}},
+			want: &Client{transport: &fixedCard{}},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -32,24 +41,159 @@ func TestClient_Delete(t *testing.T) {
 	type args struct {
 		deleteRelatedObjects bool
 		cmd                  DeleteCommand
+		logicalChannel       uint8
 	}
 	tests := []struct {
 		name             string
 		fields           fields
 		args             args
-		wantConfirmation []byte
+		wantCommands     []apdu.Command
+		wantConfirmation *ResponseConfirmation
 		assertion        assert.ErrorAssertionFunc
 	}{
-		// TODO: Add test cases.
+		{
+			name:   "delete key without confirmation",
+			fields: fields{transport: &fixedCard{data: []byte{0x00}}},
+			args:   args{cmd: DeleteKey{IncludeKeyVersionNumber: true, KeyVersionNumber: 0x30}},
+			wantCommands: []apdu.Command{
+				{Class: Class{IsGPCommand: true}, Instruction: apdu.InstructionDeleteFile, Data: []byte{0xD2, 0x01, 0x30}, ExpectedResponseLength: 256},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:   "delete related objects",
+			fields: fields{transport: &fixedCard{}},
+			args:   args{deleteRelatedObjects: true, cmd: DeleteCardContent{ELFileOrAppID: []byte{0xA0, 0x00, 0x00, 0x01, 0x51}}},
+			wantCommands: []apdu.Command{
+				{Class: Class{IsGPCommand: true}, Instruction: apdu.InstructionDeleteFile, P2: 0x80, Data: []byte{0x4F, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51}, ExpectedResponseLength: 256},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:   "logical channel",
+			fields: fields{transport: &fixedCard{}},
+			args:   args{cmd: DeleteKey{IncludeKeyVersionNumber: true, KeyVersionNumber: 0x30}, logicalChannel: 5},
+			wantCommands: []apdu.Command{
+				{Class: Class{InterindustryClass: apdu.InterindustryClass{LogicalChannelNumber: 5}, IsGPCommand: true}, Instruction: apdu.InstructionDeleteFile, Data: []byte{0xD2, 0x01, 0x30}, ExpectedResponseLength: 256},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:   "more than one command",
+			fields: fields{transport: &fixedCard{}},
+			args:   args{cmd: DeleteCardContent{ELFileOrAppID: []byte{0xA0, 0x00, 0x00, 0x01, 0x51}, CRTFDS: &ControlReferenceTemplateForDigitalSignature{TokenID: make([]byte, 220)}}},
+			wantCommands: []apdu.Command{
+				{Class: Class{IsGPCommand: true}, Instruction: apdu.InstructionDeleteFile, P1: 0x80, ExpectedResponseLength: 256},
+				{Class: Class{IsGPCommand: true}, Instruction: apdu.InstructionDeleteFile, ExpectedResponseLength: 256},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:   "card error",
+			fields: fields{transport: &fixedCard{status: apdu.RawStatus{SW1: 0x6A, SW2: 0x88}}},
+			args:   args{cmd: DeleteKey{IncludeKeyIdentifer: true, KeyIdentifier: 0x01}},
+			wantCommands: []apdu.Command{
+				{Class: Class{IsGPCommand: true}, Instruction: apdu.InstructionDeleteFile, Data: []byte{0xD0, 0x01, 0x01}, ExpectedResponseLength: 256},
+			},
+			assertion: assert.Error,
+		},
+		{
+			name:      "nil command",
+			fields:    fields{transport: &fixedCard{}},
+			assertion: assert.Error,
+		},
+		{
+			name:      "nil transport",
+			args:      args{cmd: DeleteKey{IncludeKeyIdentifer: true}},
+			assertion: assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			c := &Client{
 				transport: tt.fields.transport,
 			}
-			gotConfirmation, err := c.Delete(tt.args.deleteRelatedObjects, tt.args.cmd)
+			gotConfirmation, err := c.Delete(tt.args.deleteRelatedObjects, tt.args.cmd, tt.args.logicalChannel)
 			tt.assertion(t, err)
 			assert.Equal(t, tt.wantConfirmation, gotConfirmation)
+			if card, ok := tt.fields.transport.(*fixedCard); ok {
+				if len(tt.wantCommands) > 0 && tt.wantCommands[0].Data == nil {
+					// Only the headers of chained commands are compared
+					for i := range card.commands {
+						card.commands[i].Data = nil
+					}
+				}
+				assert.Equal(t, tt.wantCommands, card.commands)
+			}
 		})
 	}
 }
+
+// fixedCard records commands and answers each one with fixed data and status, 9000 by default
+type fixedCard struct {
+	data     []byte
+	status   apdu.RawStatus
+	commands []apdu.Command
+}
+
+func (c *fixedCard) Send(cmd apdu.Command) (apdu.Response, error) {
+	c.commands = append(c.commands, cmd)
+	status := c.status
+	if status.SW1 == 0 {
+		status.SW1 = 0x90
+	}
+	return apdu.Response{Data: c.data, Status: status.Identify()}, nil
+}
+
+func TestClient_Select(t *testing.T) {
+	card := &fixedCard{data: []byte{0x6F, 0x00}}
+	fci, err := NewClient(card).Select([]byte{0xA0, 0x00, 0x00, 0x00, 0x04, 0x10, 0x10})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x6F, 0x00}, fci)
+	if assert.Len(t, card.commands, 1) {
+		assert.Equal(t, byte(0x00), card.commands[0].Class.ToClassByte())
+		assert.Equal(t, apdu.InstructionSelect, card.commands[0].Instruction)
+		assert.Equal(t, byte(0x04), card.commands[0].P1)
+	}
+	_, err = NewClient(card).Select(make([]byte, 17))
+	assert.Error(t, err)
+}
+
+func TestSelectAndOpen(t *testing.T) {
+	aid := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00}
+	type channel struct {
+		apdu.Transport
+		SensitiveDataEncryptor
+	}
+	card := &fixedCard{}
+	var openedWith []byte
+	session, err := SelectAndOpen(card, aid, func(transport apdu.Transport, aid []byte) (SecureChannel, error) {
+		openedWith = aid
+		return channel{transport, newTestDEK()}, nil
+	})
+	assert.NoError(t, err)
+	assert.NotNil(t, session)
+	assert.Equal(t, aid, openedWith)
+	if assert.Len(t, card.commands, 1) {
+		assert.Equal(t, apdu.InstructionSelect, card.commands[0].Instruction)
+		assert.Equal(t, aid, card.commands[0].Data)
+	}
+	t.Run("select fails", func(t *testing.T) {
+		card := &fixedCard{status: apdu.RawStatus{SW1: 0x6A, SW2: 0x82}}
+		_, err := SelectAndOpen(card, aid, func(apdu.Transport, []byte) (SecureChannel, error) {
+			t.Fatal("secure channel opened after SELECT failed")
+			return nil, nil
+		})
+		assert.Error(t, err)
+	})
+	t.Run("open fails", func(t *testing.T) {
+		_, err := SelectAndOpen(&fixedCard{}, aid, func(apdu.Transport, []byte) (SecureChannel, error) {
+			return nil, fmt.Errorf("authentication failed")
+		})
+		assert.Error(t, err)
+	})
+	t.Run("no way to open", func(t *testing.T) {
+		_, err := SelectAndOpen(&fixedCard{}, aid, nil)
+		assert.Error(t, err)
+	})
+}
@@ -34,3 +34,28 @@ func (c Command) ToAPDU() (apdu.Command, error) {
 	}
 	return cmd, nil
 }
+
+// ChainBlockSize is the maximum amount of data in each command of a chain, leaving room for a 16 byte C-MAC and C-DECRYPTION padding within 255 bytes
+const ChainBlockSize = 223
+
+// Chain splits a command into a chain of commands with at most ChainBlockSize bytes of data each, using ISO-IEC 7816-4 command chaining (CLA b5 set on all but the last).
+// Commands with ChainBlockSize bytes of data or fewer are returned unchanged as a chain of one.
+func (c Command) Chain() []Command {
+	if len(c.Data) <= ChainBlockSize {
+		return []Command{c}
+	}
+	var out []Command
+	for start := 0; start < len(c.Data); start += ChainBlockSize {
+		end := start + ChainBlockSize
+		next := c
+		if end >= len(c.Data) {
+			end = len(c.Data)
+		} else {
+			next.Class.NotLastCommandOfChain = true
+			next.ExpectResponseData = false
+		}
+		next.Data = c.Data[start:end]
+		out = append(out, next)
+	}
+	return out
+}
@@ -40,3 +40,35 @@ func TestCommand_ToAPDU(t *testing.T) {
 		})
 	}
 }
+
+func TestCommand_Chain(t *testing.T) {
+	long := make([]byte, 600)
+	for i := range long {
+		long[i] = byte(i)
+	}
+	tests := []struct {
+		name string
+		c    Command
+		want []Command
+	}{
+		{
+			name: "short",
+			c:    Command{Instruction: 0x2A, Data: []byte{1, 2, 3}, ExpectResponseData: true},
+			want: []Command{{Instruction: 0x2A, Data: []byte{1, 2, 3}, ExpectResponseData: true}},
+		},
+		{
+			name: "long",
+			c:    Command{Instruction: 0x2A, Data: long, ExpectResponseData: true},
+			want: []Command{
+				{Class: Class{InterindustryClass: apdu.InterindustryClass{NotLastCommandOfChain: true}}, Instruction: 0x2A, Data: long[:223]},
+				{Class: Class{InterindustryClass: apdu.InterindustryClass{NotLastCommandOfChain: true}}, Instruction: 0x2A, Data: long[223:446]},
+				{Instruction: 0x2A, Data: long[446:], ExpectResponseData: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.c.Chain())
+		})
+	}
+}
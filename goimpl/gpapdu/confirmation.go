@@ -1,9 +1,13 @@
 package gpapdu
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/binary"
 	"fmt"
 
 	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
 )
 
 // ResponseConfirmation is a Confirmation from a response message
@@ -11,7 +15,7 @@ type ResponseConfirmation struct {
 	Receipt             []byte
 	ConfirmationCounter uint16
 	SDUniqueData        []byte
-	TokenIdentifier     []byte
+	TokenIdentifier     []byte // nil when the card did not send the token identifier and token data digest
 	TokenDataDigest     []byte
 }
 
@@ -22,25 +26,155 @@ func ConfirmationFromResponse(in apdu.Response) (res ResponseConfirmation, err e
 		err = fmt.Errorf("cannot extract confirmation from too short response data, must be at least 6 bytes")
 		return
 	}
-	receiptLen := int(in.Data[0])
-	receiptStart := 1
+	receipt, next, err := readConfirmationField(in.Data, 0)
+	if err != nil {
+		err = fmt.Errorf("reading receipt: %w", err)
+		return
+	}
+	if len(receipt) > 0 {
+		res.Receipt = receipt
+	}
+	counter, next, err := readConfirmationField(in.Data, next)
+	if err != nil {
+		err = fmt.Errorf("reading confirmation counter: %w", err)
+		return
+	}
+	if len(counter) != 2 {
+		err = fmt.Errorf("confirmation counter must be 2 bytes, got %d", len(counter))
+		return
+	}
+	res.ConfirmationCounter = binary.BigEndian.Uint16(counter)
+	if res.SDUniqueData, next, err = readConfirmationField(in.Data, next); err != nil {
+		err = fmt.Errorf("reading SD unique data: %w", err)
+		return
+	}
+	// The token identifier and token data digest are only present for operations authorised by a token
+	if next == dataLen {
+		return
+	}
+	if res.TokenIdentifier, next, err = readConfirmationField(in.Data, next); err != nil {
+		err = fmt.Errorf("reading token identifier: %w", err)
+		return
+	}
+	if res.TokenDataDigest, next, err = readConfirmationField(in.Data, next); err != nil {
+		err = fmt.Errorf("reading token data digest: %w", err)
+		return
+	}
+	if next != dataLen {
+		err = fmt.Errorf("%d unexpected bytes after confirmation", dataLen-next)
+	}
+	return
+}
+
+// readConfirmationField reads one length-prefixed field starting at the offset, returning a copy of the value and the offset of the next field
+func readConfirmationField(data []byte, offset int) (value []byte, next int, err error) {
+	if offset >= len(data) {
+		return nil, offset, fmt.Errorf("missing length")
+	}
+	length := int(data[offset])
+	start := offset + 1
 	// What's the point of this? We still only encode 0-255, but we arbitrarily encode 128-255 on a different length than 0-127
-	if receiptLen&b8 > 0 {
-		receiptLen = int(in.Data[1])
-		receiptStart = 2
-	}
-	if receiptLen > 0 {
-		if dataLen < (receiptStart + receiptLen + 5) {
-			err = fmt.Errorf("cannot extract confirmation receipt of length %d from response data of length %d", receiptLen, dataLen)
-			return
+	if length&b8 > 0 {
+		if length != 0x81 || start >= len(data) {
+			return nil, offset, fmt.Errorf("invalid length encoding")
 		}
-		res.Receipt = make([]byte, receiptLen)
-		// Copy receipt data
-		copy(res.Receipt, in.Data[receiptStart:receiptStart+receiptLen])
-	}
-	confirmationStart := receiptStart + receiptLen
-	confirmationCounterLen := int(in.Data[confirmationStart])
-	_ = confirmationCounterLen // FIXME: finish implementing this
-	err = fmt.Errorf("not implemented")
-	return
+		length = int(data[start])
+		start++
+	}
+	if start+length > len(data) {
+		return nil, offset, fmt.Errorf("field of length %d does not fit in %d remaining bytes", length, len(data)-start)
+	}
+	return append([]byte{}, data[start:start+length]...), start + length, nil
+}
+
+// writeConfirmationField writes one length-prefixed field
+func writeConfirmationField(buf *bytes.Buffer, value []byte) {
+	if len(value) > 0x7F {
+		buf.WriteByte(0x81)
+	}
+	buf.WriteByte(byte(len(value)))
+	buf.Write(value)
+}
+
+// ReceiptOperation is the operation-specific part of the receipt data, the AIDs named by the command the card confirmed
+type ReceiptOperation [][]byte
+
+// LoadReceipt is the receipt operation data of a LOAD or INSTALL [for load]
+func LoadReceipt(loadFileAID, securityDomainAID []byte) ReceiptOperation {
+	return ReceiptOperation{loadFileAID, securityDomainAID}
+}
+
+// InstallReceipt is the receipt operation data of an INSTALL [for install] or [for make selectable]
+func InstallReceipt(loadFileAID, applicationAID []byte) ReceiptOperation {
+	return ReceiptOperation{loadFileAID, applicationAID}
+}
+
+// ExtraditionReceipt is the receipt operation data of an INSTALL [for extradition]
+func ExtraditionReceipt(securityDomainAID, aid []byte) ReceiptOperation {
+	return ReceiptOperation{securityDomainAID, aid}
+}
+
+// DeleteReceipt is the receipt operation data of a DELETE of the executable load file or application
+func DeleteReceipt(aid []byte) ReceiptOperation {
+	return ReceiptOperation{aid}
+}
+
+// ReceiptData returns the data the receipt is computed over: the confirmation counter, SD unique data, the operation data and then any token identifier and token data digest
+func (r ResponseConfirmation) ReceiptData(operation ReceiptOperation) []byte {
+	buf := bytes.NewBuffer(nil)
+	writeConfirmationField(buf, []byte{byte(r.ConfirmationCounter >> 8), byte(r.ConfirmationCounter)})
+	writeConfirmationField(buf, r.SDUniqueData)
+	for _, aid := range operation {
+		writeConfirmationField(buf, aid)
+	}
+	if r.TokenIdentifier != nil || r.TokenDataDigest != nil {
+		writeConfirmationField(buf, r.TokenIdentifier)
+		writeConfirmationField(buf, r.TokenDataDigest)
+	}
+	return buf.Bytes()
+}
+
+// VerifyReceipt checks the receipt for the operation with the receipt key.
+// AES receipts are the full AES-CMAC of the receipt data, triple DES receipts are the full triple DES MAC (ISO 9797-1 MAC algorithm 1 with 80 padding and a zero ICV).
+func (r ResponseConfirmation) VerifyReceipt(key keystore.Key, operation ReceiptOperation) error {
+	if key == nil {
+		return fmt.Errorf("must supply a receipt key")
+	}
+	if len(r.Receipt) == 0 {
+		return fmt.Errorf("confirmation has no receipt")
+	}
+	if len(operation) == 0 {
+		return fmt.Errorf("must supply the operation data")
+	}
+	data := r.ReceiptData(operation)
+	var expected []byte
+	var err error
+	switch key.Algorithm() {
+	case keystore.AlgorithmAES:
+		expected, err = key.CMAC(data)
+	case keystore.AlgorithmTripleDES:
+		var encrypted []byte
+		encrypted, err = key.Encrypt(keystore.ModeCBC, nil, pad80(data, 8))
+		if err == nil {
+			expected = encrypted[len(encrypted)-8:]
+		}
+	default:
+		return fmt.Errorf("receipts cannot be computed with %s keys", key.Algorithm())
+	}
+	if err != nil {
+		return fmt.Errorf("computing receipt: %w", err)
+	}
+	if subtle.ConstantTimeCompare(expected, r.Receipt) != 1 {
+		return fmt.Errorf("receipt does not match")
+	}
+	return nil
+}
+
+// pad80 appends 80 and then as many 00 bytes as are needed to fill the last block
+func pad80(data []byte, blockSize int) []byte {
+	padded := append(append([]byte{}, data...), 0x80)
+	for len(padded)%blockSize != 0 {
+		padded = append(padded, 0x00)
+	}
+	return padded
 }
@@ -1,13 +1,21 @@
 package gpapdu
 
 import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
 	"testing"
 
+	"github.com/aead/cmac"
 	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestConfirmationFromResponse(t *testing.T) {
+	receipt := bytes.Repeat([]byte{0xEE}, 16)
+	longUnique := bytes.Repeat([]byte{0x55}, 130)
 	type args struct {
 		in apdu.Response
 	}
@@ -17,13 +25,167 @@ func TestConfirmationFromResponse(t *testing.T) {
 		wantRes   ResponseConfirmation
 		assertion assert.ErrorAssertionFunc
 	}{
-		// TODO: Add test cases.
+		{
+			name:      "no receipt",
+			args:      args{in: apdu.Response{Data: []byte{0x00, 0x02, 0x00, 0x07, 0x01, 0xAA}}},
+			wantRes:   ResponseConfirmation{ConfirmationCounter: 7, SDUniqueData: []byte{0xAA}},
+			assertion: assert.NoError,
+		},
+		{
+			name: "receipt and token",
+			args: args{in: apdu.Response{Data: append(append([]byte{0x10}, receipt...),
+				0x02, 0x01, 0x02, 0x01, 0xAA, 0x01, 0x09, 0x02, 0xD1, 0xD2)}},
+			wantRes: ResponseConfirmation{
+				Receipt:             receipt,
+				ConfirmationCounter: 0x0102,
+				SDUniqueData:        []byte{0xAA},
+				TokenIdentifier:     []byte{0x09},
+				TokenDataDigest:     []byte{0xD1, 0xD2},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "long SD unique data",
+			args:      args{in: apdu.Response{Data: append([]byte{0x00, 0x02, 0x00, 0x01, 0x81, 130}, longUnique...)}},
+			wantRes:   ResponseConfirmation{ConfirmationCounter: 1, SDUniqueData: longUnique},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "too short",
+			args:      args{in: apdu.Response{Data: []byte{0x00, 0x02, 0x00}}},
+			assertion: assert.Error,
+		},
+		{
+			name:      "truncated receipt",
+			args:      args{in: apdu.Response{Data: []byte{0x10, 0x01, 0x02, 0x03, 0x04, 0x05}}},
+			assertion: assert.Error,
+		},
+		{
+			name:      "wrong counter length",
+			args:      args{in: apdu.Response{Data: []byte{0x00, 0x01, 0x07, 0x01, 0xAA, 0x00}}},
+			assertion: assert.Error,
+		},
+		{
+			name:      "token identifier without digest",
+			args:      args{in: apdu.Response{Data: []byte{0x00, 0x02, 0x00, 0x07, 0x01, 0xAA, 0x01, 0x09}}},
+			assertion: assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			gotRes, err := ConfirmationFromResponse(tt.args.in)
 			tt.assertion(t, err)
-			assert.Equal(t, tt.wantRes, gotRes)
+			if err == nil {
+				assert.Equal(t, tt.wantRes, gotRes)
+			}
+		})
+	}
+}
+
+func TestResponseConfirmation_ReceiptData(t *testing.T) {
+	loadFile := []byte{0xA0, 0x00, 0x00, 0x01, 0x51}
+	sd := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00}
+	app := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01}
+	confirmation := ResponseConfirmation{ConfirmationCounter: 3, SDUniqueData: []byte{0x01, 0x02}}
+	withToken := ResponseConfirmation{ConfirmationCounter: 3, SDUniqueData: []byte{0x01, 0x02}, TokenIdentifier: []byte{}, TokenDataDigest: []byte{0xDD}}
+	tests := []struct {
+		name         string
+		confirmation ResponseConfirmation
+		operation    ReceiptOperation
+		want         []byte
+	}{
+		{
+			name:         "load",
+			confirmation: confirmation,
+			operation:    LoadReceipt(loadFile, sd),
+			want:         []byte{0x02, 0x00, 0x03, 0x02, 0x01, 0x02, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x07, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00},
+		},
+		{
+			name:         "install",
+			confirmation: confirmation,
+			operation:    InstallReceipt(loadFile, app),
+			want:         []byte{0x02, 0x00, 0x03, 0x02, 0x01, 0x02, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x06, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x01},
+		},
+		{
+			name:         "extradition",
+			confirmation: confirmation,
+			operation:    ExtraditionReceipt(sd, app),
+			want:         []byte{0x02, 0x00, 0x03, 0x02, 0x01, 0x02, 0x07, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00, 0x06, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x01},
+		},
+		{
+			name:         "delete",
+			confirmation: confirmation,
+			operation:    DeleteReceipt(app),
+			want:         []byte{0x02, 0x00, 0x03, 0x02, 0x01, 0x02, 0x06, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x01},
+		},
+		{
+			name:         "delete authorised by a token",
+			confirmation: withToken,
+			operation:    DeleteReceipt(app),
+			want:         []byte{0x02, 0x00, 0x03, 0x02, 0x01, 0x02, 0x06, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x00, 0x01, 0xDD},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.confirmation.ReceiptData(tt.operation))
+		})
+	}
+}
+
+func TestResponseConfirmation_VerifyReceipt(t *testing.T) {
+	aesRaw := bytes.Repeat([]byte{0x40}, 16)
+	desRaw := defaultKey
+	aesKey, _ := keystore.NewSoftwareKey(keystore.AlgorithmAES, aesRaw)
+	desKey, _ := keystore.NewSoftwareKey(keystore.AlgorithmTripleDES, desRaw)
+	confirmation := ResponseConfirmation{ConfirmationCounter: 3, SDUniqueData: []byte{0x01, 0x02}, TokenIdentifier: []byte{}, TokenDataDigest: []byte{0xDD}}
+	loadFile := []byte{0xA0, 0x00, 0x00, 0x01, 0x51}
+	sd := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00}
+	app := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01}
+	receipts := func(operation ReceiptOperation) (aesReceipt, desReceipt []byte) {
+		data := confirmation.ReceiptData(operation)
+		aesBlock, _ := aes.NewCipher(aesRaw)
+		aesReceipt, _ = cmac.Sum(data, aesBlock, aes.BlockSize)
+		desBlock, _ := des.NewTripleDESCipher(append(append([]byte{}, desRaw...), desRaw[:8]...))
+		padded := append(append([]byte{}, data...), 0x80)
+		for len(padded)%8 != 0 {
+			padded = append(padded, 0x00)
+		}
+		desOut := make([]byte, len(padded))
+		cipher.NewCBCEncrypter(desBlock, make([]byte, 8)).CryptBlocks(desOut, padded)
+		return aesReceipt, desOut[len(desOut)-8:]
+	}
+	loadAES, loadDES := receipts(LoadReceipt(loadFile, sd))
+	installAES, installDES := receipts(InstallReceipt(loadFile, app))
+	extraditionAES, extraditionDES := receipts(ExtraditionReceipt(sd, app))
+	deleteAES, deleteDES := receipts(DeleteReceipt(app))
+	tests := []struct {
+		name      string
+		receipt   []byte
+		key       keystore.Key
+		operation ReceiptOperation
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "load AES", receipt: loadAES, key: aesKey, operation: LoadReceipt(loadFile, sd), assertion: assert.NoError},
+		{name: "load triple DES", receipt: loadDES, key: desKey, operation: LoadReceipt(loadFile, sd), assertion: assert.NoError},
+		{name: "load with wrong security domain", receipt: loadAES, key: aesKey, operation: LoadReceipt(loadFile, app), assertion: assert.Error},
+		{name: "install AES", receipt: installAES, key: aesKey, operation: InstallReceipt(loadFile, app), assertion: assert.NoError},
+		{name: "install triple DES", receipt: installDES, key: desKey, operation: InstallReceipt(loadFile, app), assertion: assert.NoError},
+		{name: "install with wrong application", receipt: installAES, key: aesKey, operation: InstallReceipt(loadFile, sd), assertion: assert.Error},
+		{name: "extradition AES", receipt: extraditionAES, key: aesKey, operation: ExtraditionReceipt(sd, app), assertion: assert.NoError},
+		{name: "extradition triple DES", receipt: extraditionDES, key: desKey, operation: ExtraditionReceipt(sd, app), assertion: assert.NoError},
+		{name: "delete AES", receipt: deleteAES, key: aesKey, operation: DeleteReceipt(app), assertion: assert.NoError},
+		{name: "delete triple DES", receipt: deleteDES, key: desKey, operation: DeleteReceipt(app), assertion: assert.NoError},
+		{name: "delete of another AID", receipt: deleteAES, key: aesKey, operation: DeleteReceipt(loadFile), assertion: assert.Error},
+		{name: "wrong key", receipt: deleteAES, key: desKey, operation: DeleteReceipt(app), assertion: assert.Error},
+		{name: "no operation data", receipt: deleteAES, key: aesKey, assertion: assert.Error},
+		{name: "no receipt", key: aesKey, operation: DeleteReceipt(app), assertion: assert.Error},
+		{name: "no key", receipt: deleteAES, operation: DeleteReceipt(app), assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := confirmation
+			c.Receipt = tt.receipt
+			tt.assertion(t, c.VerifyReceipt(tt.key, tt.operation))
 		})
 	}
 }
@@ -0,0 +1,67 @@
+package gpapdu
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+)
+
+// LoadFileHash computes the load file data block hash sent in INSTALL [for load] and signed by DAP blocks.
+// GP 2.1.1 cards use SHA-1, later versions allow SHA-256, SHA-384 and SHA-512.
+func LoadFileHash(hash crypto.Hash, loadFileDataBlock []byte) ([]byte, error) {
+	switch hash {
+	case crypto.SHA1, crypto.SHA256, crypto.SHA384, crypto.SHA512:
+	default:
+		return nil, fmt.Errorf("hash function %d cannot be used for load file data block hashes", hash)
+	}
+	if !hash.Available() {
+		return nil, fmt.Errorf("hash function %d is not available", hash)
+	}
+	h := hash.New()
+	h.Write(loadFileDataBlock)
+	return h.Sum(nil), nil
+}
+
+// DAPKey is the key of a security domain provider or controlling authority used to sign DAP blocks
+type DAPKey struct {
+	// Signer is an RSA or ECDSA private key, RSA signatures are PKCS#1 v1.5 and ECDSA signatures are the raw r||s signature
+	Signer crypto.Signer
+	// Hash is the digest of the load file data block hash which is signed, SHA-256 is used when zero
+	Hash crypto.Hash
+}
+
+// NewDAPBlock signs the load file data block hash for the security domain
+func NewDAPBlock(key DAPKey, randR io.Reader, securityDomainAID, loadFileHash []byte) (DAPBlock, error) {
+	if key.Signer == nil {
+		return DAPBlock{}, fmt.Errorf("must supply a DAP key")
+	}
+	if len(loadFileHash) == 0 {
+		return DAPBlock{}, fmt.Errorf("must supply the load file data block hash")
+	}
+	hash := key.Hash
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+	sig, err := signMessage(key.Signer, hash, randR, loadFileHash)
+	if err != nil {
+		return DAPBlock{}, fmt.Errorf("signing DAP: %w", err)
+	}
+	return DAPBlock{SecurityDomainAID: append([]byte{}, securityDomainAID...), Signature: sig}, nil
+}
+
+// Verify checks the DAP block signature over the load file data block hash, as the security domain does
+func (d DAPBlock) Verify(pub crypto.PublicKey, hash crypto.Hash, loadFileHash []byte) error {
+	if err := verifyMessage(pub, hash, loadFileHash, d.Signature); err != nil {
+		return fmt.Errorf("invalid DAP signature: %w", err)
+	}
+	return nil
+}
+
+// DAPRequired reports whether a load needs a DAP block from a security domain with the privileges.
+// A security domain with mandated DAP verification checks every load on the card, one with DAP verification only checks loads into itself.
+func DAPRequired(sdPrivileges Privileges, loadsIntoThisDomain bool) bool {
+	if sdPrivileges.MandatedDAPVerification {
+		return true
+	}
+	return sdPrivileges.DAPVerification && loadsIntoThisDomain
+}
@@ -0,0 +1,73 @@
+package gpapdu
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadFileHash(t *testing.T) {
+	block := []byte{0xC4, 0x01, 0x00}
+	sha1Sum := sha1.Sum(block)
+	sha256Sum := sha256.Sum256(block)
+	tests := []struct {
+		name      string
+		hash      crypto.Hash
+		want      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "SHA-1", hash: crypto.SHA1, want: sha1Sum[:], assertion: assert.NoError},
+		{name: "SHA-256", hash: crypto.SHA256, want: sha256Sum[:], assertion: assert.NoError},
+		{name: "MD5", hash: crypto.MD5, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadFileHash(tt.hash, block)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewDAPBlock(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	sd := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00, 0x00}
+	hash, _ := LoadFileHash(crypto.SHA256, []byte{0x01, 0x02})
+	tests := []struct {
+		name      string
+		key       DAPKey
+		public    crypto.PublicKey
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "RSA SHA-1", key: DAPKey{Signer: rsaKey, Hash: crypto.SHA1}, public: &rsaKey.PublicKey, assertion: assert.NoError},
+		{name: "ECDSA", key: DAPKey{Signer: ecKey, Hash: crypto.SHA384}, public: &ecKey.PublicKey, assertion: assert.NoError},
+		{name: "no key", assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			block, err := NewDAPBlock(tt.key, rand.Reader, sd, hash)
+			tt.assertion(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, sd, block.SecurityDomainAID)
+			assert.NoError(t, block.Verify(tt.public, tt.key.Hash, hash))
+			assert.Error(t, block.Verify(tt.public, tt.key.Hash, hash[1:]))
+		})
+	}
+}
+
+func TestDAPRequired(t *testing.T) {
+	assert.True(t, DAPRequired(Privileges{MandatedDAPVerification: true}, false))
+	assert.True(t, DAPRequired(Privileges{DAPVerification: true}, true))
+	assert.False(t, DAPRequired(Privileges{DAPVerification: true}, false))
+	assert.False(t, DAPRequired(Privileges{SecurityDomain: true}, true))
+}
@@ -1,69 +1,114 @@
 package gpapdu
 
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+)
+
 const (
 	tagELFileOrAppID                 = 0x4F
 	tagCRTFDS                        = 0xB6
 	tagSecurityDomainID              = 0x42
 	tagSecurityDomainImageNumber     = 0x45
 	tagApplicationProviderIdentifier = 0x5F20
-	tagTokenID                       = 0x9E
+	tagTokenID                       = 0x93
+	tagKeyIdentifier                 = 0xD0
+	tagKeyVersionNumber              = 0xD2
 )
 
 // DeleteCommand is a Delete command, either DeleteKey or DeleteCardContent
 type DeleteCommand interface {
-	unimplementableDeleteCommandToBytes() []byte
+	unimplementableDeleteCommandToBytes() ([]byte, error)
 }
 
 // ControlReferenceTemplateForDigitalSignature is a control reference template for digital signature
 type ControlReferenceTemplateForDigitalSignature struct {
-	SecurityDomainID          []byte `asn1:"optional,omitempty,tag:42"`
-	SecurityDomainImageNumber []byte `asn1:"optional,omitempty,tag:45"`
-	ApplicationProviderID     []byte `asn1:"optional,omitempty,tag:5F20"`
-	TokenID                   []byte `asn1:"optional,omitempty,tag:93"`
+	SecurityDomainID          []byte `bertlv:"42"`
+	SecurityDomainImageNumber []byte `bertlv:"45"`
+	ApplicationProviderID     []byte `bertlv:"5F20"`
+	TokenID                   []byte `bertlv:"93"`
 }
 
 // ToBerTlv encodes the data as BER-TLV
-// func (c ControlReferenceTemplateForDigitalSignature) ToBerTlv() (obj bertlv.Object) {
-// 	obj.Tag = bertlv.TagFromUintForced(tagCRTFDS)
-// 	buf := bytes.NewBuffer(nil)
-// 	writer, _ := bertlv.NewWriter(buf)
-// 	if c.SecurityDomainID != nil {
-// 		writer.Write(bertlv.Object{
-// 			Tag:   bertlv.TagFromUintForced(tagSecurityDomainID),
-// 			Value: c.SecurityDomainID,
-// 		})
-// 	}
-// 	if c.SecurityDomainImageNumber != nil {
-// 		writer.Write(bertlv.Object{
-// 			Tag:   bertlv.TagFromUintForced(tagSecurityDomainImageNumber),
-// 			Value: c.SecurityDomainImageNumber,
-// 		})
-// 	}
-// 	if c.ApplicationProviderID != nil {
-// 		writer.Write(bertlv.Object{
-// 			Tag:   bertlv.TagFromUintForced(tagApplicationProviderIdentifier),
-// 			Value: c.ApplicationProviderID,
-// 		})
-// 	}
-// 	if c.TokenID != nil {
-// 		writer.Write(bertlv.Object{
-// 			Tag:   bertlv.TagFromUintForced(tagTokenID),
-// 			Value: c.TokenID,
-// 		})
-// 	}
-// 	obj.Value = buf.Bytes()
-// 	return
-// }
+func (c ControlReferenceTemplateForDigitalSignature) ToBerTlv() (obj bertlv.Object) {
+	obj.Tag = bertlv.TagFromUintForced(tagCRTFDS)
+	buf := bytes.NewBuffer(nil)
+	// Writing to a bytes.Buffer with a valid tag cannot fail
+	writer, _ := bertlv.NewWriter(buf)
+	if c.SecurityDomainID != nil {
+		writer.Write(bertlv.Object{
+			Tag:   bertlv.TagFromUintForced(tagSecurityDomainID),
+			Value: c.SecurityDomainID,
+		})
+	}
+	if c.SecurityDomainImageNumber != nil {
+		writer.Write(bertlv.Object{
+			Tag:   bertlv.TagFromUintForced(tagSecurityDomainImageNumber),
+			Value: c.SecurityDomainImageNumber,
+		})
+	}
+	if c.ApplicationProviderID != nil {
+		writer.Write(bertlv.Object{
+			Tag:   bertlv.TagFromUintForced(tagApplicationProviderIdentifier),
+			Value: c.ApplicationProviderID,
+		})
+	}
+	if c.TokenID != nil {
+		writer.Write(bertlv.Object{
+			Tag:   bertlv.TagFromUintForced(tagTokenID),
+			Value: c.TokenID,
+		})
+	}
+	obj.Value = buf.Bytes()
+	return
+}
 
 // DeleteCardContent is Delete [card content] command
 type DeleteCardContent struct {
 	ELFileOrAppID []byte
 	CRTFDS        *ControlReferenceTemplateForDigitalSignature
+	Token         []byte // Delegated management token, see Sign
 }
 
-func (d DeleteCardContent) unimplementableDeleteCommandToBytes() []byte {
+func (d DeleteCardContent) unimplementableDeleteCommandToBytes() ([]byte, error) {
+	data, err := d.dataWithoutToken()
+	if err != nil {
+		return nil, err
+	}
+	return appendToken(data, d.Token)
+}
+
+// Sign sets the delegated management token for the command, deleteRelatedObjects must match the value passed to Client.Delete
+func (d *DeleteCardContent) Sign(key TokenKey, randR io.Reader, deleteRelatedObjects bool) (err error) {
+	data, err := d.dataWithoutToken()
+	if err != nil {
+		return err
+	}
+	p1, err := deleteTokenP1(key, data)
+	if err != nil {
+		return err
+	}
+	d.Token, err = key.SignToken(randR, p1, deleteP2(deleteRelatedObjects), data)
+	return err
+}
 
-	panic("not implemented") // FIXME
+func (d DeleteCardContent) dataWithoutToken() ([]byte, error) {
+	if len(d.ELFileOrAppID) < 5 || len(d.ELFileOrAppID) > 16 {
+		return nil, fmt.Errorf("AID must be between 5 and 16 bytes, got %d", len(d.ELFileOrAppID))
+	}
+	buf := bytes.NewBuffer(nil)
+	writer, _ := bertlv.NewWriter(buf)
+	writer.Write(bertlv.Object{
+		Tag:   bertlv.TagFromUintForced(tagELFileOrAppID),
+		Value: d.ELFileOrAppID,
+	})
+	if d.CRTFDS != nil {
+		writer.Write(d.CRTFDS.ToBerTlv())
+	}
+	return buf.Bytes(), nil
 }
 
 // DeleteKey is a Delete [key] command
@@ -72,8 +117,65 @@ type DeleteKey struct {
 	KeyIdentifier           byte
 	IncludeKeyVersionNumber bool
 	KeyVersionNumber        byte
+	Token                   []byte // Delegated management token, see Sign
+}
+
+func (d DeleteKey) unimplementableDeleteCommandToBytes() ([]byte, error) {
+	data, err := d.dataWithoutToken()
+	if err != nil {
+		return nil, err
+	}
+	return appendToken(data, d.Token)
+}
+
+// Sign sets the delegated management token for the command
+func (d *DeleteKey) Sign(key TokenKey, randR io.Reader) (err error) {
+	data, err := d.dataWithoutToken()
+	if err != nil {
+		return err
+	}
+	p1, err := deleteTokenP1(key, data)
+	if err != nil {
+		return err
+	}
+	d.Token, err = key.SignToken(randR, p1, deleteP2(false), data)
+	return err
+}
+
+// deleteTokenP1 returns the P1 of the first DELETE command sent with the data and a token from the key, with b8 set when the command will be chained
+func deleteTokenP1(key TokenKey, data []byte) (byte, error) {
+	length, err := key.tokenLength()
+	if err != nil {
+		return 0, err
+	}
+	full, err := appendToken(data, make([]byte, length))
+	if err != nil {
+		return 0, err
+	}
+	if len(full) > ChainBlockSize {
+		return b8, nil
+	}
+	return 0x00, nil
+}
+
+func (d DeleteKey) dataWithoutToken() ([]byte, error) {
+	if !d.IncludeKeyIdentifer && !d.IncludeKeyVersionNumber {
+		return nil, fmt.Errorf("must include the key identifier, key version number or both")
+	}
+	var data []byte
+	if d.IncludeKeyIdentifer {
+		data = append(data, tagKeyIdentifier, 0x01, d.KeyIdentifier)
+	}
+	if d.IncludeKeyVersionNumber {
+		data = append(data, tagKeyVersionNumber, 0x01, d.KeyVersionNumber)
+	}
+	return data, nil
 }
 
-func (d DeleteKey) unimplementableDeleteCommandToBytes() []byte {
-	panic("not implemented") // FIXME
+// deleteP2 is the P2 of a DELETE command
+func deleteP2(deleteRelatedObjects bool) byte {
+	if deleteRelatedObjects {
+		return b8
+	}
+	return 0x00
 }
@@ -1,9 +1,9 @@
 package gpapdu
 
 import (
-	"encoding/asn1"
 	"testing"
 
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -12,12 +12,30 @@ func TestDeleteCardContent_unimplementableDeleteCommandToBytes(t *testing.T) {
 		ELFileOrAppID []byte
 		CRTFDS        *ControlReferenceTemplateForDigitalSignature
 	}
+	aid := []byte{0xA0, 0x00, 0x00, 0x01, 0x51}
 	tests := []struct {
-		name   string
-		fields fields
-		want   []byte
+		name      string
+		fields    fields
+		want      []byte
+		assertion assert.ErrorAssertionFunc
 	}{
-		// TODO: Add test cases.
+		{
+			name:      "AID only",
+			fields:    fields{ELFileOrAppID: aid},
+			want:      []byte{0x4F, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "with CRT",
+			fields:    fields{ELFileOrAppID: aid, CRTFDS: &ControlReferenceTemplateForDigitalSignature{TokenID: []byte{0x01}}},
+			want:      []byte{0x4F, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51, 0xB6, 0x03, 0x93, 0x01, 0x01},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "AID too short",
+			fields:    fields{ELFileOrAppID: aid[:4]},
+			assertion: assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -25,7 +43,9 @@ func TestDeleteCardContent_unimplementableDeleteCommandToBytes(t *testing.T) {
 				ELFileOrAppID: tt.fields.ELFileOrAppID,
 				CRTFDS:        tt.fields.CRTFDS,
 			}
-			assert.Equal(t, tt.want, d.unimplementableDeleteCommandToBytes())
+			got, err := d.unimplementableDeleteCommandToBytes()
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
 		})
 	}
 }
@@ -38,11 +58,28 @@ func TestDeleteKey_unimplementableDeleteCommandToBytes(t *testing.T) {
 		KeyVersionNumber        byte
 	}
 	tests := []struct {
-		name   string
-		fields fields
-		want   []byte
+		name      string
+		fields    fields
+		want      []byte
+		assertion assert.ErrorAssertionFunc
 	}{
-		// TODO: Add test cases.
+		{
+			name:      "identifier and version",
+			fields:    fields{IncludeKeyIdentifer: true, KeyIdentifier: 0x01, IncludeKeyVersionNumber: true, KeyVersionNumber: 0x30},
+			want:      []byte{0xD0, 0x01, 0x01, 0xD2, 0x01, 0x30},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "version only",
+			fields:    fields{IncludeKeyVersionNumber: true, KeyVersionNumber: 0x30},
+			want:      []byte{0xD2, 0x01, 0x30},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "neither",
+			fields:    fields{KeyIdentifier: 0x01},
+			assertion: assert.Error,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -52,11 +89,25 @@ func TestDeleteKey_unimplementableDeleteCommandToBytes(t *testing.T) {
 				IncludeKeyVersionNumber: tt.fields.IncludeKeyVersionNumber,
 				KeyVersionNumber:        tt.fields.KeyVersionNumber,
 			}
-			assert.Equal(t, tt.want, d.unimplementableDeleteCommandToBytes())
+			got, err := d.unimplementableDeleteCommandToBytes()
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
 		})
 	}
 }
 
+func TestControlReferenceTemplateForDigitalSignature_ToBerTlv(t *testing.T) {
+	c := ControlReferenceTemplateForDigitalSignature{
+		ApplicationProviderID:     []byte{1},
+		SecurityDomainID:          []byte{2},
+		SecurityDomainImageNumber: []byte{3},
+		TokenID:                   []byte{4},
+	}
+	data, err := c.ToBerTlv().ToBytes()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xB6, 0x0D, 0x42, 0x01, 0x02, 0x45, 0x01, 0x03, 0x5F, 0x20, 0x01, 0x01, 0x93, 0x01, 0x04}, data)
+}
+
 func TestASN1(t *testing.T) {
 	c := ControlReferenceTemplateForDigitalSignature{
 		ApplicationProviderID:     []byte{1},
@@ -64,7 +115,11 @@ func TestASN1(t *testing.T) {
 		SecurityDomainImageNumber: []byte{3},
 		TokenID:                   []byte{4},
 	}
-	data, err := asn1.MarshalWithParams(c, "tag:B6")
+	data, err := bertlv.Marshal(struct {
+		CRT ControlReferenceTemplateForDigitalSignature `bertlv:"B6"`
+	}{c})
 	assert.NoError(t, err)
-	assert.Equal(t, []byte{}, data)
+	assert.Equal(t, []byte{0xB6, 0x0D, 0x42, 0x01, 0x02, 0x45, 0x01, 0x03, 0x5F, 0x20, 0x01, 0x01, 0x93, 0x01, 0x04}, data)
+	want, _ := c.ToBerTlv().ToBytes()
+	assert.Equal(t, want, data)
 }
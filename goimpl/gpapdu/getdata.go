@@ -0,0 +1,365 @@
+package gpapdu
+
+import (
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+	"github.com/llkennedy/globalplatform/goimpl/gpids"
+)
+
+const (
+	// InstructionGetDataOdd is the GetData instruction with the odd instruction byte, which carries a data field
+	InstructionGetDataOdd apdu.Instruction = 0xCB
+	// InstructionPutDataOdd is the PutData instruction with the odd instruction byte, whose data field is BER-TLV encoded
+	InstructionPutDataOdd apdu.Instruction = 0xDB
+)
+
+// DataTag is the tag of a data object read with GET DATA or written with PUT DATA, which is sent in P1 and P2
+type DataTag uint16
+
+const (
+	// DataIssuerIdentificationNumber is the Issuer Identification Number
+	DataIssuerIdentificationNumber DataTag = 0x42
+	// DataCardImageNumber is the Card Image Number
+	DataCardImageNumber DataTag = 0x45
+	// DataCardData is the Card Data, which wraps the Card Recognition Data
+	DataCardData DataTag = 0x66
+	// DataCardCapabilityInformation is the Card Capability Information
+	DataCardCapabilityInformation DataTag = 0x67
+	// DataSequenceCounter is the Sequence Counter of the default key version number
+	DataSequenceCounter DataTag = 0xC1
+	// DataConfirmationCounter is the Confirmation Counter
+	DataConfirmationCounter DataTag = 0xC2
+	// DataKeyInformationTemplate is the Key Information Template
+	DataKeyInformationTemplate DataTag = 0xE0
+)
+
+const (
+	tagCardRecognitionData          = 0x73
+	tagObjectIdentifier             = 0x06
+	tagCardManagementTypeAndVersion = 0x60
+	tagCardIdentificationScheme     = 0x63
+	tagSecureChannelProtocol        = 0x64
+	tagCardConfigurationDetails     = 0x65
+	tagCardChipDetails              = 0x66
+	tagSecureChannelCapability      = 0xA0
+	tagCapabilityProtocol           = 0x80
+	tagCapabilityOptions            = 0x81
+	tagCapabilityKeyLengths         = 0x82
+	tagSecurityDomainPrivileges     = 0x81
+	tagApplicationPrivileges        = 0x82
+	tagLoadFileDataBlockHashes      = 0x83
+	tagTokenCipherSuites            = 0x84
+	tagReceiptCipherSuites          = 0x85
+	tagDAPCipherSuites              = 0x86
+	tagKeyParameterReferenceValues  = 0x87
+)
+
+// DataParser decodes the value of a data object
+type DataParser func(value []byte) (interface{}, error)
+
+// DataParsers maps tags to the parsers of their values
+type DataParsers map[DataTag]DataParser
+
+// DefaultDataParsers returns the parsers for the common GP data objects, which callers may extend with parsers for proprietary tags
+func DefaultDataParsers() DataParsers {
+	return DataParsers{
+		DataCardData:                  func(value []byte) (interface{}, error) { return ParseCardData(value) },
+		DataCardCapabilityInformation: func(value []byte) (interface{}, error) { return ParseCardCapabilityInformation(value) },
+		DataSequenceCounter:           func(value []byte) (interface{}, error) { return parseCounter(value) },
+		DataConfirmationCounter:       func(value []byte) (interface{}, error) { return parseCounter(value) },
+		DataKeyInformationTemplate:    func(value []byte) (interface{}, error) { return ParseKeyInformationTemplate(value) },
+	}
+}
+
+// Register adds or replaces the parser for a tag
+func (d DataParsers) Register(tag DataTag, parser DataParser) {
+	d[tag] = parser
+}
+
+// DataObject is a data object read with GET DATA
+type DataObject struct {
+	Tag   DataTag
+	Value []byte
+	// Parsed is the result of the parser for the tag, or nil if there is none.
+	// The default parsers return CardRecognitionData for card data, CardCapabilities for card capability information, uint32 for counters and []KeyInformation for key information templates.
+	Parsed interface{}
+}
+
+// GetDataCommand reads a data object
+type GetDataCommand struct {
+	Tag DataTag
+	// Odd uses the odd instruction byte, which sends Data in the data field
+	Odd  bool
+	Data []byte
+	// Parsers are consulted before the defaults
+	Parsers DataParsers
+}
+
+// ToCommand converts the GET DATA command to a command
+func (g GetDataCommand) ToCommand() (Command, error) {
+	if !g.Odd && len(g.Data) > 0 {
+		return Command{}, fmt.Errorf("GET DATA with the even instruction byte has no data field")
+	}
+	ins := apdu.InstructionGetData
+	if g.Odd {
+		ins = InstructionGetDataOdd
+	}
+	return Command{
+		Class:              Class{IsGPCommand: true},
+		Instruction:        ins,
+		P1:                 byte(g.Tag >> 8),
+		P2:                 byte(g.Tag),
+		Data:               g.Data,
+		ExpectResponseData: true,
+	}, nil
+}
+
+// ParseResponse parses the data object in response data, which must be the TLV object with the requested tag
+func (g GetDataCommand) ParseResponse(data []byte) (DataObject, error) {
+	tag, err := bertlv.TagFromUint(uint64(g.Tag))
+	if err != nil {
+		return DataObject{}, fmt.Errorf("invalid tag %X: %w", uint16(g.Tag), err)
+	}
+	objects, err := bertlv.ReadAll(data)
+	if err != nil {
+		return DataObject{}, fmt.Errorf("reading data object: %w", err)
+	}
+	if len(objects) != 1 || objects[0].Tag != tag {
+		return DataObject{}, fmt.Errorf("expected a single data object with tag %X", uint16(g.Tag))
+	}
+	out := DataObject{Tag: g.Tag, Value: objects[0].Value}
+	parser, ok := g.Parsers[g.Tag]
+	if !ok {
+		parser = DefaultDataParsers()[g.Tag]
+	}
+	if parser == nil {
+		return out, nil
+	}
+	if out.Parsed, err = parser(out.Value); err != nil {
+		return DataObject{}, fmt.Errorf("parsing data object %X: %w", uint16(g.Tag), err)
+	}
+	return out, nil
+}
+
+// PutDataCommand writes a data object
+type PutDataCommand struct {
+	Tag DataTag
+	// Odd uses the odd instruction byte, where Data holds BER-TLV data objects rather than the value of Tag
+	Odd  bool
+	Data []byte
+}
+
+// ToCommand converts the PUT DATA command to a command
+func (p PutDataCommand) ToCommand() Command {
+	ins := apdu.InstructionPutData
+	if p.Odd {
+		ins = InstructionPutDataOdd
+	}
+	return Command{
+		Class:       Class{IsGPCommand: true},
+		Instruction: ins,
+		P1:          byte(p.Tag >> 8),
+		P2:          byte(p.Tag),
+		Data:        p.Data,
+	}
+}
+
+// GetData reads and parses a data object
+func (c *Client) GetData(cmd GetDataCommand) (DataObject, error) {
+	command, err := cmd.ToCommand()
+	if err != nil {
+		return DataObject{}, err
+	}
+	res, err := c.sendChained(command)
+	if err != nil {
+		return DataObject{}, err
+	}
+	return cmd.ParseResponse(res.Data)
+}
+
+// PutData writes a data object, chaining the data field if it is too long for one command
+func (c *Client) PutData(cmd PutDataCommand) error {
+	_, err := c.sendChained(cmd.ToCommand())
+	return err
+}
+
+// parseCounter decodes a big-endian counter of up to 4 bytes
+func parseCounter(value []byte) (uint32, error) {
+	if len(value) == 0 || len(value) > 4 {
+		return 0, fmt.Errorf("counter must be 1 to 4 bytes, got %d", len(value))
+	}
+	var out uint32
+	for _, b := range value {
+		out = out<<8 | uint32(b)
+	}
+	return out, nil
+}
+
+// SecureChannelProtocolVersion is a secure channel protocol supported by the card and its "i" parameter
+type SecureChannelProtocolVersion struct {
+	Protocol byte
+	Options  byte
+}
+
+// CardRecognitionData describes the card, as found in Card Data
+type CardRecognitionData struct {
+	// CardManagementTypeAndVersion is the GP version, e.g. 1.2.840.114283.2.2.3.1 for 2.3.1
+	CardManagementTypeAndVersion asn1.ObjectIdentifier
+	CardIdentificationScheme     asn1.ObjectIdentifier
+	SecureChannelProtocols       []SecureChannelProtocolVersion
+	// CardConfigurationDetails and CardChipDetails are issuer specific
+	CardConfigurationDetails []byte
+	CardChipDetails          []byte
+	// Other objects, such as security domain trust points
+	Other []bertlv.Object
+}
+
+// ParseCardData parses the value of Card Data, which holds the Card Recognition Data
+func ParseCardData(value []byte) (CardRecognitionData, error) {
+	objects, err := bertlv.ReadAll(value)
+	if err != nil {
+		return CardRecognitionData{}, fmt.Errorf("reading card data: %w", err)
+	}
+	if len(objects) != 1 || objects[0].Tag != bertlv.TagFromUintForced(tagCardRecognitionData) {
+		return CardRecognitionData{}, fmt.Errorf("card data must hold exactly one card recognition data object")
+	}
+	fields, err := bertlv.ReadAll(objects[0].Value)
+	if err != nil {
+		return CardRecognitionData{}, fmt.Errorf("reading card recognition data: %w", err)
+	}
+	var out CardRecognitionData
+	for _, field := range fields {
+		switch field.Tag {
+		case bertlv.TagFromUintForced(tagObjectIdentifier):
+			// Identifies GP as the tag allocation authority, nothing to keep
+		case bertlv.TagFromUintForced(tagCardManagementTypeAndVersion):
+			if out.CardManagementTypeAndVersion, err = wrappedOID(field.Value); err != nil {
+				return CardRecognitionData{}, err
+			}
+		case bertlv.TagFromUintForced(tagCardIdentificationScheme):
+			if out.CardIdentificationScheme, err = wrappedOID(field.Value); err != nil {
+				return CardRecognitionData{}, err
+			}
+		case bertlv.TagFromUintForced(tagSecureChannelProtocol):
+			oid, err := wrappedOID(field.Value)
+			if err != nil {
+				return CardRecognitionData{}, err
+			}
+			prefix := gpids.SecureChannelProtocolOID()
+			if len(oid) != len(prefix)+2 || !oid[:len(prefix)].Equal(prefix) || oid[len(prefix)] > 0xFF || oid[len(prefix)+1] > 0xFF {
+				return CardRecognitionData{}, fmt.Errorf("invalid secure channel protocol OID %s", oid)
+			}
+			out.SecureChannelProtocols = append(out.SecureChannelProtocols, SecureChannelProtocolVersion{Protocol: byte(oid[len(prefix)]), Options: byte(oid[len(prefix)+1])})
+		case bertlv.TagFromUintForced(tagCardConfigurationDetails):
+			out.CardConfigurationDetails = field.Value
+		case bertlv.TagFromUintForced(tagCardChipDetails):
+			out.CardChipDetails = field.Value
+		default:
+			out.Other = append(out.Other, field)
+		}
+	}
+	return out, nil
+}
+
+// wrappedOID decodes the single OID object inside a Card Recognition Data field
+func wrappedOID(value []byte) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	rest, err := asn1.Unmarshal(value, &oid)
+	if err != nil {
+		return nil, fmt.Errorf("reading OID: %w", err)
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%d bytes after OID", len(rest))
+	}
+	return oid, nil
+}
+
+// SecureChannelCapability is a secure channel protocol supported by the card, with every "i" parameter it supports
+type SecureChannelCapability struct {
+	Protocol byte
+	Options  []byte
+	// KeyLengths is the bitmap of supported AES key lengths for SCP03, absent for other protocols
+	KeyLengths []byte
+	Other      []bertlv.Object
+}
+
+// CardCapabilities is the Card Capability Information, the lists of supported algorithms and privileges are kept as their raw bitmaps
+type CardCapabilities struct {
+	SecureChannels           []SecureChannelCapability
+	SecurityDomainPrivileges []byte
+	ApplicationPrivileges    []byte
+	LoadFileDataBlockHashes  []byte
+	TokenCipherSuites        []byte
+	ReceiptCipherSuites      []byte
+	DAPCipherSuites          []byte
+	KeyParameterReferences   []byte
+	Other                    []bertlv.Object
+}
+
+// ParseCardCapabilityInformation parses the value of the Card Capability Information
+func ParseCardCapabilityInformation(value []byte) (CardCapabilities, error) {
+	objects, err := bertlv.ReadAll(value)
+	if err != nil {
+		return CardCapabilities{}, fmt.Errorf("reading card capability information: %w", err)
+	}
+	var out CardCapabilities
+	for _, obj := range objects {
+		switch obj.Tag {
+		case bertlv.TagFromUintForced(tagSecureChannelCapability):
+			scp, err := parseSecureChannelCapability(obj.Value)
+			if err != nil {
+				return CardCapabilities{}, err
+			}
+			out.SecureChannels = append(out.SecureChannels, scp)
+		case bertlv.TagFromUintForced(tagSecurityDomainPrivileges):
+			out.SecurityDomainPrivileges = obj.Value
+		case bertlv.TagFromUintForced(tagApplicationPrivileges):
+			out.ApplicationPrivileges = obj.Value
+		case bertlv.TagFromUintForced(tagLoadFileDataBlockHashes):
+			out.LoadFileDataBlockHashes = obj.Value
+		case bertlv.TagFromUintForced(tagTokenCipherSuites):
+			out.TokenCipherSuites = obj.Value
+		case bertlv.TagFromUintForced(tagReceiptCipherSuites):
+			out.ReceiptCipherSuites = obj.Value
+		case bertlv.TagFromUintForced(tagDAPCipherSuites):
+			out.DAPCipherSuites = obj.Value
+		case bertlv.TagFromUintForced(tagKeyParameterReferenceValues):
+			out.KeyParameterReferences = obj.Value
+		default:
+			out.Other = append(out.Other, obj)
+		}
+	}
+	return out, nil
+}
+
+func parseSecureChannelCapability(value []byte) (SecureChannelCapability, error) {
+	fields, err := bertlv.ReadAll(value)
+	if err != nil {
+		return SecureChannelCapability{}, fmt.Errorf("reading secure channel capability: %w", err)
+	}
+	var out SecureChannelCapability
+	hasProtocol := false
+	for _, field := range fields {
+		switch field.Tag {
+		case bertlv.TagFromUintForced(tagCapabilityProtocol):
+			if len(field.Value) != 1 {
+				return SecureChannelCapability{}, fmt.Errorf("secure channel protocol must be 1 byte, got %d", len(field.Value))
+			}
+			out.Protocol = field.Value[0]
+			hasProtocol = true
+		case bertlv.TagFromUintForced(tagCapabilityOptions):
+			out.Options = field.Value
+		case bertlv.TagFromUintForced(tagCapabilityKeyLengths):
+			out.KeyLengths = field.Value
+		default:
+			out.Other = append(out.Other, field)
+		}
+	}
+	if !hasProtocol {
+		return SecureChannelCapability{}, fmt.Errorf("secure channel capability without protocol")
+	}
+	return out, nil
+}
@@ -0,0 +1,123 @@
+package gpapdu
+
+import (
+	"encoding/asn1"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDataCommand_ParseResponse(t *testing.T) {
+	cardData := []byte{
+		0x66, 0x31, 0x73, 0x2F,
+		0x06, 0x07, 0x2A, 0x86, 0x48, 0x86, 0xFC, 0x6B, 0x01,
+		0x60, 0x0C, 0x06, 0x0A, 0x2A, 0x86, 0x48, 0x86, 0xFC, 0x6B, 0x02, 0x02, 0x03, 0x01,
+		0x63, 0x09, 0x06, 0x07, 0x2A, 0x86, 0x48, 0x86, 0xFC, 0x6B, 0x03,
+		0x64, 0x0B, 0x06, 0x09, 0x2A, 0x86, 0x48, 0x86, 0xFC, 0x6B, 0x04, 0x03, 0x70,
+	}
+	capabilities := []byte{0x67, 0x0E, 0xA0, 0x09, 0x80, 0x01, 0x03, 0x81, 0x01, 0x10, 0x82, 0x01, 0x01, 0x81, 0x01, 0xFE}
+	tests := []struct {
+		name      string
+		cmd       GetDataCommand
+		data      []byte
+		want      DataObject
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "card data",
+			cmd:  GetDataCommand{Tag: DataCardData},
+			data: cardData,
+			want: DataObject{Tag: DataCardData, Value: cardData[2:], Parsed: CardRecognitionData{
+				CardManagementTypeAndVersion: asn1.ObjectIdentifier{1, 2, 840, 114283, 2, 2, 3, 1},
+				CardIdentificationScheme:     asn1.ObjectIdentifier{1, 2, 840, 114283, 3},
+				SecureChannelProtocols:       []SecureChannelProtocolVersion{{Protocol: 0x03, Options: 0x70}},
+			}},
+			assertion: assert.NoError,
+		},
+		{
+			name: "card capability information",
+			cmd:  GetDataCommand{Tag: DataCardCapabilityInformation},
+			data: capabilities,
+			want: DataObject{Tag: DataCardCapabilityInformation, Value: capabilities[2:], Parsed: CardCapabilities{
+				SecureChannels:           []SecureChannelCapability{{Protocol: 0x03, Options: []byte{0x10}, KeyLengths: []byte{0x01}}},
+				SecurityDomainPrivileges: []byte{0xFE},
+			}},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "sequence counter",
+			cmd:       GetDataCommand{Tag: DataSequenceCounter},
+			data:      []byte{0xC1, 0x03, 0x00, 0x01, 0x02},
+			want:      DataObject{Tag: DataSequenceCounter, Value: []byte{0x00, 0x01, 0x02}, Parsed: uint32(0x0102)},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "IIN has no parser",
+			cmd:       GetDataCommand{Tag: DataIssuerIdentificationNumber},
+			data:      []byte{0x42, 0x03, 0x12, 0x34, 0x56},
+			want:      DataObject{Tag: DataIssuerIdentificationNumber, Value: []byte{0x12, 0x34, 0x56}},
+			assertion: assert.NoError,
+		},
+		{
+			name: "proprietary parser",
+			cmd: GetDataCommand{Tag: 0x9F7F, Parsers: DataParsers{0x9F7F: func(value []byte) (interface{}, error) {
+				return len(value), nil
+			}}},
+			data:      []byte{0x9F, 0x7F, 0x02, 0x01, 0x02},
+			want:      DataObject{Tag: 0x9F7F, Value: []byte{0x01, 0x02}, Parsed: 2},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "wrong tag",
+			cmd:       GetDataCommand{Tag: DataConfirmationCounter},
+			data:      []byte{0xC1, 0x02, 0x00, 0x01},
+			assertion: assert.Error,
+		},
+		{
+			name:      "bad counter",
+			cmd:       GetDataCommand{Tag: DataConfirmationCounter},
+			data:      []byte{0xC2, 0x00},
+			assertion: assert.Error,
+		},
+		{
+			name:      "bad card data",
+			cmd:       GetDataCommand{Tag: DataCardData},
+			data:      []byte{0x66, 0x02, 0x74, 0x00},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.ParseResponse(tt.data)
+			tt.assertion(t, err)
+			if err == nil {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClient_GetData(t *testing.T) {
+	card := &fixedCard{data: []byte{0xE0, 0x06, 0xC0, 0x04, 0x01, 0x30, 0x88, 0x10}}
+	got, err := NewClient(card).GetData(GetDataCommand{Tag: DataKeyInformationTemplate})
+	assert.NoError(t, err)
+	assert.Equal(t, []KeyInformation{{KeyIdentifier: 0x01, KeyVersionNumber: 0x30, Components: []KeyComponentInformation{{Type: AES, Length: 16}}}}, got.Parsed)
+	if assert.Len(t, card.commands, 1) {
+		assert.Equal(t, apdu.InstructionGetData, card.commands[0].Instruction)
+		assert.Equal(t, byte(0x00), card.commands[0].P1)
+		assert.Equal(t, byte(0xE0), card.commands[0].P2)
+	}
+	_, err = NewClient(card).GetData(GetDataCommand{Tag: DataCardData, Data: []byte{0x5C, 0x00}})
+	assert.Error(t, err)
+}
+
+func TestClient_PutData(t *testing.T) {
+	card := &fixedCard{}
+	assert.NoError(t, NewClient(card).PutData(PutDataCommand{Tag: 0x9F7F, Odd: true, Data: []byte{0x01}}))
+	if assert.Len(t, card.commands, 1) {
+		assert.Equal(t, InstructionPutDataOdd, card.commands[0].Instruction)
+		assert.Equal(t, byte(0x9F), card.commands[0].P1)
+		assert.Equal(t, byte(0x7F), card.commands[0].P2)
+	}
+}
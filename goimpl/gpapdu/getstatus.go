@@ -0,0 +1,254 @@
+package gpapdu
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+	lifecycle "github.com/llkennedy/globalplatform/goimpl/lifcecycle"
+)
+
+const (
+	// InstructionGetStatus is the GetStatus instruction
+	InstructionGetStatus apdu.Instruction = 0xF2
+)
+
+const (
+	tagRegistryEntry               = 0xE3
+	tagLifeCycleState              = 0x9F70
+	tagPrivileges                  = 0xC5
+	tagExecutableLoadFileAID       = 0xC4
+	tagVersionNumber               = 0xCE
+	tagExecutableModuleAID         = 0x84
+	tagAssociatedSecurityDomainAID = 0xCC
+)
+
+// StatusSubset selects which part of the registry GET STATUS returns
+type StatusSubset byte
+
+const (
+	// StatusIssuerSecurityDomain returns the issuer security domain only
+	StatusIssuerSecurityDomain StatusSubset = 0x80
+	// StatusApplications returns applications, including security domains
+	StatusApplications StatusSubset = 0x40
+	// StatusExecutableLoadFiles returns executable load files
+	StatusExecutableLoadFiles StatusSubset = 0x20
+	// StatusExecutableLoadFilesAndModules returns executable load files and their executable modules
+	StatusExecutableLoadFilesAndModules StatusSubset = 0x10
+)
+
+const (
+	getStatusTLV            = b2
+	getStatusNextOccurrence = b1
+)
+
+// GetStatusCommand reads registry entries
+type GetStatusCommand struct {
+	Subset StatusSubset
+	// AID restricts the results to entries whose AID starts with it, every entry is returned when empty
+	AID []byte
+	// Legacy requests the format from before GP 2.2 rather than the E3 TLV format
+	Legacy bool
+}
+
+// RegistryEntry is one entry of the GP registry
+type RegistryEntry struct {
+	AID []byte
+	// LifeCycleState is the raw state, interpreted through the accessor for the kind of entry
+	LifeCycleState byte
+	// Privileges are decoded leniently from RawPrivileges, PrivilegesError reports why the card's encoding is not valid for INSTALL
+	Privileges                  Privileges
+	RawPrivileges               []byte
+	PrivilegesError             error
+	ExecutableLoadFileAID       []byte
+	AssociatedSecurityDomainAID []byte
+	Version                     []byte
+	ExecutableModuleAIDs        [][]byte
+}
+
+// CardLifeCycle interprets the state of the issuer security domain entry as the card life cycle state
+func (r RegistryEntry) CardLifeCycle() lifecycle.Card {
+	return lifecycle.Card(r.LifeCycleState)
+}
+
+// ApplicationLifeCycle interprets the state of an application entry
+func (r RegistryEntry) ApplicationLifeCycle() lifecycle.Application {
+	return lifecycle.Application(r.LifeCycleState)
+}
+
+// SecurityDomainLifeCycle interprets the state of a security domain entry
+func (r RegistryEntry) SecurityDomainLifeCycle() lifecycle.SecurityDomain {
+	return lifecycle.SecurityDomain(r.LifeCycleState)
+}
+
+// ExecutableLoadFileLifeCycle interprets the state of an executable load file entry
+func (r RegistryEntry) ExecutableLoadFileLifeCycle() lifecycle.ExecutableLoadFile {
+	return lifecycle.ExecutableLoadFile(r.LifeCycleState)
+}
+
+// setPrivileges records the privileges as the card encoded them, so one unusual entry does not fail the whole registry.
+// Invalid encodings are decoded from their first 3 bytes with missing bytes as zero, and the problem is kept in PrivilegesError.
+func (r *RegistryEntry) setPrivileges(raw []byte) {
+	r.RawPrivileges = raw
+	if r.Privileges, r.PrivilegesError = ParsePrivileges(raw); r.PrivilegesError != nil {
+		var full [3]byte
+		copy(full[:], raw)
+		r.Privileges = PrivilegesFromBytes(full)
+	}
+}
+
+// ToCommand builds the first GET STATUS command, next occurrences set P2 b1
+func (g GetStatusCommand) ToCommand() (Command, error) {
+	switch g.Subset {
+	case StatusIssuerSecurityDomain, StatusApplications, StatusExecutableLoadFiles, StatusExecutableLoadFilesAndModules:
+	default:
+		return Command{}, fmt.Errorf("unknown GET STATUS subset %X", byte(g.Subset))
+	}
+	if len(g.AID) > 16 {
+		return Command{}, fmt.Errorf("AID search criteria must be at most 16 bytes, got %d", len(g.AID))
+	}
+	cmd := Command{
+		Class:              Class{IsGPCommand: true},
+		Instruction:        InstructionGetStatus,
+		P1:                 byte(g.Subset),
+		Data:               append([]byte{tagELFileOrAppID, byte(len(g.AID))}, g.AID...),
+		ExpectResponseData: true,
+	}
+	if !g.Legacy {
+		cmd.P2 = getStatusTLV
+	}
+	return cmd, nil
+}
+
+// ParseRegistryEntries parses the data of one GET STATUS response
+func (g GetStatusCommand) ParseRegistryEntries(data []byte) ([]RegistryEntry, error) {
+	if g.Legacy {
+		return parseLegacyRegistryEntries(data, g.Subset == StatusExecutableLoadFilesAndModules)
+	}
+	return parseTLVRegistryEntries(data)
+}
+
+func parseLegacyRegistryEntries(data []byte, withModules bool) ([]RegistryEntry, error) {
+	var entries []RegistryEntry
+	next := func(n int) ([]byte, error) {
+		if n > len(data) {
+			return nil, fmt.Errorf("registry entry truncated")
+		}
+		out := append([]byte{}, data[:n]...)
+		data = data[n:]
+		return out, nil
+	}
+	for len(data) > 0 {
+		var entry RegistryEntry
+		length, err := next(1)
+		if err != nil {
+			return nil, err
+		}
+		if entry.AID, err = next(int(length[0])); err != nil {
+			return nil, err
+		}
+		state, err := next(2)
+		if err != nil {
+			return nil, err
+		}
+		entry.LifeCycleState = state[0]
+		entry.setPrivileges(state[1:])
+		if withModules {
+			count, err := next(1)
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < int(count[0]); i++ {
+				length, err := next(1)
+				if err != nil {
+					return nil, err
+				}
+				module, err := next(int(length[0]))
+				if err != nil {
+					return nil, err
+				}
+				entry.ExecutableModuleAIDs = append(entry.ExecutableModuleAIDs, module)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func parseTLVRegistryEntries(data []byte) ([]RegistryEntry, error) {
+	objects, err := bertlv.ReadAll(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading registry entries: %w", err)
+	}
+	entries := make([]RegistryEntry, 0, len(objects))
+	for _, obj := range objects {
+		if obj.Tag != bertlv.TagFromUintForced(tagRegistryEntry) {
+			return nil, fmt.Errorf("expected registry entry E3, got %+v", obj.Tag)
+		}
+		fields, err := bertlv.ReadAll(obj.Value)
+		if err != nil {
+			return nil, fmt.Errorf("reading registry entry: %w", err)
+		}
+		var entry RegistryEntry
+		for _, field := range fields {
+			switch field.Tag {
+			case bertlv.TagFromUintForced(tagELFileOrAppID):
+				entry.AID = field.Value
+			case bertlv.TagFromUintForced(tagLifeCycleState):
+				if len(field.Value) != 1 {
+					return nil, fmt.Errorf("life cycle state must be 1 byte, got %d", len(field.Value))
+				}
+				entry.LifeCycleState = field.Value[0]
+			case bertlv.TagFromUintForced(tagPrivileges):
+				entry.setPrivileges(field.Value)
+			case bertlv.TagFromUintForced(tagExecutableLoadFileAID):
+				entry.ExecutableLoadFileAID = field.Value
+			case bertlv.TagFromUintForced(tagVersionNumber):
+				entry.Version = field.Value
+			case bertlv.TagFromUintForced(tagExecutableModuleAID):
+				entry.ExecutableModuleAIDs = append(entry.ExecutableModuleAIDs, field.Value)
+			case bertlv.TagFromUintForced(tagAssociatedSecurityDomainAID):
+				entry.AssociatedSecurityDomainAID = field.Value
+			}
+			// Other tags, such as implicit selection parameters, are not interpreted
+		}
+		if entry.AID == nil {
+			return nil, fmt.Errorf("registry entry has no AID")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// GetStatus reads every matching registry entry, repeating the command for the next occurrences while the card answers 6310
+func (c *Client) GetStatus(cmd GetStatusCommand) ([]RegistryEntry, error) {
+	command, err := cmd.ToCommand()
+	if err != nil {
+		return nil, err
+	}
+	var entries []RegistryEntry
+	for {
+		res, err := c.sendChained(command)
+		var raw apdu.RawStatus
+		if status := res.GetStatus(); status != nil {
+			raw = status.Raw()
+		}
+		moreData := raw == (apdu.RawStatus{SW1: 0x63, SW2: 0x10})
+		if err != nil && !moreData {
+			// 6A88 means nothing matched the search criteria
+			if raw == (apdu.RawStatus{SW1: 0x6A, SW2: 0x88}) {
+				return entries, nil
+			}
+			return nil, err
+		}
+		page, err := cmd.ParseRegistryEntries(res.Data)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, page...)
+		if !moreData {
+			return entries, nil
+		}
+		command.P2 |= getStatusNextOccurrence
+	}
+}
@@ -0,0 +1,174 @@
+package gpapdu
+
+import (
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	lifecycle "github.com/llkennedy/globalplatform/goimpl/lifcecycle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStatusCommand_ParseRegistryEntries(t *testing.T) {
+	isd := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00, 0x00}
+	pkg := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01}
+	module := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x01}
+	tests := []struct {
+		name      string
+		cmd       GetStatusCommand
+		data      []byte
+		want      []RegistryEntry
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "TLV application",
+			cmd:  GetStatusCommand{Subset: StatusApplications},
+			data: append(append(append(append([]byte{0xE3, 0x1E, 0x4F, 0x08}, isd...), 0x9F, 0x70, 0x01, 0x0F, 0xC5, 0x03, 0x9E, 0xFE, 0x80, 0xC4, 0x06), pkg...), 0xCC, 0x01, 0xAA),
+			want: []RegistryEntry{{
+				AID:                         isd,
+				LifeCycleState:              0x0F,
+				Privileges:                  PrivilegesFromBytes([3]byte{0x9E, 0xFE, 0x80}),
+				RawPrivileges:               []byte{0x9E, 0xFE, 0x80},
+				ExecutableLoadFileAID:       pkg,
+				AssociatedSecurityDomainAID: []byte{0xAA},
+			}},
+			assertion: assert.NoError,
+		},
+		{
+			name: "TLV load file with modules",
+			cmd:  GetStatusCommand{Subset: StatusExecutableLoadFilesAndModules},
+			data: append(append(append(append([]byte{0xE3, 0x1C, 0x4F, 0x06}, pkg...), 0x9F, 0x70, 0x01, 0x01, 0xCE, 0x02, 0x01, 0x00, 0x84, 0x07), module...), 0xCF, 0x01, 0x00),
+			want: []RegistryEntry{{
+				AID:                  pkg,
+				LifeCycleState:       0x01,
+				Version:              []byte{0x01, 0x00},
+				ExecutableModuleAIDs: [][]byte{module},
+			}},
+			assertion: assert.NoError,
+		},
+		{
+			name: "legacy load file with modules",
+			cmd:  GetStatusCommand{Subset: StatusExecutableLoadFilesAndModules, Legacy: true},
+			data: append(append(append([]byte{0x06}, pkg...), 0x01, 0x00, 0x01, 0x07), module...),
+			want: []RegistryEntry{{
+				AID:                  pkg,
+				LifeCycleState:       0x01,
+				RawPrivileges:        []byte{0x00},
+				ExecutableModuleAIDs: [][]byte{module},
+			}},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "legacy truncated",
+			cmd:       GetStatusCommand{Subset: StatusApplications, Legacy: true},
+			data:      append([]byte{0x08}, isd[:4]...),
+			assertion: assert.Error,
+		},
+		{
+			name:      "TLV without AID",
+			cmd:       GetStatusCommand{Subset: StatusApplications},
+			data:      []byte{0xE3, 0x04, 0x9F, 0x70, 0x01, 0x07},
+			assertion: assert.Error,
+		},
+		{
+			name:      "TLV wrong template",
+			cmd:       GetStatusCommand{Subset: StatusApplications},
+			data:      []byte{0xE2, 0x00},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.ParseRegistryEntries(tt.data)
+			tt.assertion(t, err)
+			if err == nil {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetStatusCommand_ParseRegistryEntries_invalidPrivileges(t *testing.T) {
+	data := []byte{
+		// DAP Verification without Security Domain
+		0xE3, 0x0B, 0x4F, 0x02, 0xA0, 0x01, 0x9F, 0x70, 0x01, 0x07, 0xC5, 0x01, 0x40,
+		// Two bytes of privileges
+		0xE3, 0x08, 0x4F, 0x02, 0xA0, 0x02, 0xC5, 0x02, 0x80, 0x02,
+		0xE3, 0x09, 0x4F, 0x02, 0xA0, 0x03, 0xC5, 0x03, 0x80, 0x00, 0x00,
+	}
+	entries, err := GetStatusCommand{Subset: StatusApplications}.ParseRegistryEntries(data)
+	assert.NoError(t, err)
+	if !assert.Len(t, entries, 3) {
+		return
+	}
+	assert.Error(t, entries[0].PrivilegesError)
+	assert.Equal(t, []byte{0x40}, entries[0].RawPrivileges)
+	assert.True(t, entries[0].Privileges.DAPVerification)
+	assert.Equal(t, byte(0x07), entries[0].LifeCycleState)
+	assert.Error(t, entries[1].PrivilegesError)
+	assert.Equal(t, []byte{0x80, 0x02}, entries[1].RawPrivileges)
+	assert.True(t, entries[1].Privileges.SecurityDomain)
+	assert.NoError(t, entries[2].PrivilegesError)
+	assert.True(t, entries[2].Privileges.SecurityDomain)
+
+	entries, err = GetStatusCommand{Subset: StatusApplications, Legacy: true}.ParseRegistryEntries([]byte{0x02, 0xA0, 0x01, 0x07, 0x40})
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Error(t, entries[0].PrivilegesError)
+		assert.Equal(t, []byte{0x40}, entries[0].RawPrivileges)
+	}
+}
+
+func TestRegistryEntry_lifeCycles(t *testing.T) {
+	assert.Equal(t, lifecycle.CardSecured, RegistryEntry{LifeCycleState: 0x0F}.CardLifeCycle())
+	assert.Equal(t, lifecycle.ApplicationLocked, RegistryEntry{LifeCycleState: 0x83}.ApplicationLifeCycle())
+	assert.Equal(t, lifecycle.SecurityDomainPersonalized, RegistryEntry{LifeCycleState: 0x0F}.SecurityDomainLifeCycle())
+	assert.Equal(t, lifecycle.ExecutableLoadFileLoaded, RegistryEntry{LifeCycleState: 0x01}.ExecutableLoadFileLifeCycle())
+}
+
+// pagedCard answers GET STATUS with one entry per response, with 6310 until the last
+type pagedCard struct {
+	pages    [][]byte
+	status   apdu.RawStatus
+	commands []apdu.Command
+}
+
+func (c *pagedCard) Send(cmd apdu.Command) (apdu.Response, error) {
+	c.commands = append(c.commands, cmd)
+	if c.status.SW1 != 0 {
+		return apdu.Response{Status: c.status.Identify()}, nil
+	}
+	page := c.pages[len(c.commands)-1]
+	status := apdu.RawStatus{SW1: 0x90}
+	if len(c.commands) < len(c.pages) {
+		status = apdu.RawStatus{SW1: 0x63, SW2: 0x10}
+	}
+	return apdu.Response{Data: page, Status: status.Identify()}, nil
+}
+
+func TestClient_GetStatus(t *testing.T) {
+	first := []byte{0xE3, 0x0B, 0x4F, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x9F, 0x70, 0x01, 0x07}
+	second := []byte{0xE3, 0x0B, 0x4F, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x52, 0x9F, 0x70, 0x01, 0x07}
+	card := &pagedCard{pages: [][]byte{first, second}}
+	entries, err := NewClient(card).GetStatus(GetStatusCommand{Subset: StatusApplications})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	if assert.Len(t, card.commands, 2) {
+		assert.Equal(t, byte(0x40), card.commands[0].P1)
+		assert.Equal(t, byte(0x02), card.commands[0].P2)
+		assert.Equal(t, byte(0x03), card.commands[1].P2)
+		assert.Equal(t, []byte{0x4F, 0x00}, card.commands[0].Data)
+	}
+	t.Run("nothing found", func(t *testing.T) {
+		entries, err := NewClient(&pagedCard{status: apdu.RawStatus{SW1: 0x6A, SW2: 0x88}}).GetStatus(GetStatusCommand{Subset: StatusExecutableLoadFiles})
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+	t.Run("refused", func(t *testing.T) {
+		_, err := NewClient(&pagedCard{status: apdu.RawStatus{SW1: 0x69, SW2: 0x82}}).GetStatus(GetStatusCommand{Subset: StatusExecutableLoadFiles})
+		assert.Error(t, err)
+	})
+	t.Run("bad subset", func(t *testing.T) {
+		_, err := NewClient(&pagedCard{}).GetStatus(GetStatusCommand{Subset: 0x01})
+		assert.Error(t, err)
+	})
+}
@@ -1,15 +1,15 @@
 package gpapdu
 
 // Commands is the full client command list
-// TODO: none of these have actual arguments/returns yet
 type Commands interface {
-	Delete(deleteRelatedObjects bool, cmd DeleteCommand, logicalChannel uint8) (confirmation []byte, err error)
-	GetData()
-	GetStatus()
-	Install() // TODO: there's a LOT of weirdness for this one
-	Load()
-	PutKey()
-	Select()
-	SetStatus()
-	StoreData()
+	Delete(deleteRelatedObjects bool, cmd DeleteCommand, logicalChannel uint8) (confirmation *ResponseConfirmation, err error)
+	GetData(cmd GetDataCommand) (DataObject, error)
+	GetStatus(cmd GetStatusCommand) ([]RegistryEntry, error)
+	Install(cmd InstallCommand) (*ResponseConfirmation, error)
+	Load(cmd LoadCommand) (*ResponseConfirmation, error)
+	PutData(cmd PutDataCommand) error
+	PutKey(cmd PutKeyCommand, dek SensitiveDataEncryptor) error
+	Select(aid []byte) ([]byte, error)
+	SetStatus(cmd SetStatusCommand) error
+	StoreData(cmd StoreDataCommand) ([][]byte, error)
 }
@@ -0,0 +1,385 @@
+package gpapdu
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+)
+
+const (
+	// InstructionInstall is the Install instruction
+	InstructionInstall apdu.Instruction = 0xE6
+)
+
+const (
+	installForLoad            = 0x02
+	installForInstall         = 0x04
+	installForMakeSelectable  = 0x08
+	installForExtradition     = 0x10
+	installForPersonalization = 0x20
+	installForRegistryUpdate  = 0x40
+)
+
+const (
+	tagApplicationSpecificParameters = 0xC9
+	tagSystemSpecificParameters      = 0xEF
+	tagNonVolatileCodeMemoryQuota    = 0xC6
+	tagVolatileMemoryQuota           = 0xC7
+	tagNonVolatileMemoryQuota        = 0xC8
+	tagGlobalServiceParameters       = 0xCB
+	tagImplicitSelectionParameter    = 0xCF
+	tagVolatileReservedMemory        = 0xD7
+	tagNonVolatileReservedMemory     = 0xD8
+)
+
+// InstallCommand is one of the INSTALL variants
+type InstallCommand interface {
+	// unimplementableInstallCommand returns P1, P2 and the data field without the token
+	unimplementableInstallCommand() (p1, p2 byte, data []byte, err error)
+	installToken() []byte
+}
+
+// SystemParameters are the system specific parameters (tag EF) of INSTALL [for load] and INSTALL [for install], zero values are omitted
+type SystemParameters struct {
+	NonVolatileCodeMemoryQuota uint32 // C6, load only
+	VolatileMemoryQuota        uint32 // C7
+	NonVolatileMemoryQuota     uint32 // C8
+	GlobalService              []byte // CB
+	ImplicitSelection          []byte // One CF object per implicit selection parameter
+	VolatileReservedMemory     uint32 // D7
+	NonVolatileReservedMemory  uint32 // D8
+	// Other objects, such as security domain or issuer-specific parameters, are written after the rest
+	Other []bertlv.Object
+}
+
+// quota encodes a memory quota on 2 bytes, or 4 if it does not fit
+func quota(value uint32) []byte {
+	if value <= 0xFFFF {
+		return []byte{byte(value >> 8), byte(value)}
+	}
+	return []byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}
+}
+
+// ToBerTlv encodes the parameters as an EF object, returning false if there are no parameters
+func (s SystemParameters) ToBerTlv() (obj bertlv.Object, ok bool) {
+	var objects []bertlv.Object
+	addQuota := func(tag uint64, value uint32) {
+		if value != 0 {
+			objects = append(objects, bertlv.Object{Tag: bertlv.TagFromUintForced(tag), Value: quota(value)})
+		}
+	}
+	addQuota(tagNonVolatileCodeMemoryQuota, s.NonVolatileCodeMemoryQuota)
+	addQuota(tagVolatileMemoryQuota, s.VolatileMemoryQuota)
+	addQuota(tagNonVolatileMemoryQuota, s.NonVolatileMemoryQuota)
+	if s.GlobalService != nil {
+		objects = append(objects, bertlv.Object{Tag: bertlv.TagFromUintForced(tagGlobalServiceParameters), Value: s.GlobalService})
+	}
+	for _, parameter := range s.ImplicitSelection {
+		objects = append(objects, bertlv.Object{Tag: bertlv.TagFromUintForced(tagImplicitSelectionParameter), Value: []byte{parameter}})
+	}
+	addQuota(tagVolatileReservedMemory, s.VolatileReservedMemory)
+	addQuota(tagNonVolatileReservedMemory, s.NonVolatileReservedMemory)
+	objects = append(objects, s.Other...)
+	if len(objects) == 0 {
+		return bertlv.Object{}, false
+	}
+	return bertlv.Object{Tag: bertlv.TagFromUintForced(tagSystemSpecificParameters), Value: encodeObjects(objects)}, true
+}
+
+// InstallParameters is the install parameters field of INSTALL [for install]
+type InstallParameters struct {
+	ApplicationSpecific []byte // C9, always sent even when empty
+	System              SystemParameters
+	// Other objects, such as the EA parameters of ETSI TS 102 226, are written after the rest
+	Other []bertlv.Object
+}
+
+// ToBytes encodes the install parameters field
+func (p InstallParameters) ToBytes() []byte {
+	objects := []bertlv.Object{{Tag: bertlv.TagFromUintForced(tagApplicationSpecificParameters), Value: p.ApplicationSpecific}}
+	if system, ok := p.System.ToBerTlv(); ok {
+		objects = append(objects, system)
+	}
+	return encodeObjects(append(objects, p.Other...))
+}
+
+// encodeObjects concatenates the encodings of the objects, which cannot fail on a bytes.Buffer with valid tags
+func encodeObjects(objects []bertlv.Object) []byte {
+	buf := bytes.NewBuffer(nil)
+	writer, _ := bertlv.NewWriter(buf)
+	for _, obj := range objects {
+		writer.Write(obj)
+	}
+	return buf.Bytes()
+}
+
+// installFields builds a data field of length-prefixed fields, AIDs and other short fields have 1 byte lengths and parameters use BER lengths
+type installFields struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (f *installFields) short(name string, value []byte) {
+	if f.err == nil && len(value) > 0xFF {
+		f.err = fmt.Errorf("%s must be at most 255 bytes, got %d", name, len(value))
+	}
+	f.buf.WriteByte(byte(len(value)))
+	f.buf.Write(value)
+}
+
+func (f *installFields) aid(name string, value []byte, optional bool) {
+	if f.err == nil && !(optional && len(value) == 0) && (len(value) < 5 || len(value) > 16) {
+		f.err = fmt.Errorf("%s must be between 5 and 16 bytes, got %d", name, len(value))
+	}
+	f.short(name, value)
+}
+
+func (f *installFields) long(value []byte) {
+	f.buf.Write(bertlv.LengthToBytes(uint64(len(value))))
+	f.buf.Write(value)
+}
+
+func (f *installFields) privileges(p *Privileges) {
+	if p == nil {
+		f.buf.WriteByte(0x00)
+		return
+	}
+	encoded := p.ToBytes()
+	// The single byte form is understood by every card, so it is used when the other two bytes are empty
+	if encoded[1] == 0 && encoded[2] == 0 {
+		f.short("privileges", encoded[:1])
+	} else {
+		f.short("privileges", encoded[:])
+	}
+}
+
+func (f *installFields) bytes() ([]byte, error) {
+	return f.buf.Bytes(), f.err
+}
+
+// InstallForLoad prepares the card for a LOAD sequence
+type InstallForLoad struct {
+	LoadFileAID           []byte
+	SecurityDomainAID     []byte // Empty to load into the security domain the command is sent to
+	LoadFileDataBlockHash []byte // Required with DAP verification or delegated management
+	LoadParameters        SystemParameters
+	Token                 []byte // Delegated management token, see Sign
+}
+
+func (i InstallForLoad) unimplementableInstallCommand() (byte, byte, []byte, error) {
+	var f installFields
+	f.aid("load file AID", i.LoadFileAID, false)
+	f.aid("security domain AID", i.SecurityDomainAID, true)
+	f.short("load file data block hash", i.LoadFileDataBlockHash)
+	var params []byte
+	if obj, ok := i.LoadParameters.ToBerTlv(); ok {
+		params = encodeObjects([]bertlv.Object{obj})
+	}
+	f.long(params)
+	data, err := f.bytes()
+	return installForLoad, 0x00, data, err
+}
+
+func (i InstallForLoad) installToken() []byte {
+	return i.Token
+}
+
+// Sign sets the delegated management token (the load token) for the command
+func (i *InstallForLoad) Sign(key TokenKey, randR io.Reader) (err error) {
+	i.Token, err = signInstall(*i, key, randR)
+	return err
+}
+
+// InstallForInstall installs an application from an executable module, and optionally makes it selectable in the same command
+type InstallForInstall struct {
+	ExecutableLoadFileAID []byte
+	ExecutableModuleAID   []byte
+	ApplicationAID        []byte
+	Privileges            Privileges
+	Parameters            InstallParameters
+	MakeSelectable        bool   // Combines INSTALL [for install] and INSTALL [for make selectable]
+	Token                 []byte // Delegated management token, see Sign
+}
+
+func (i InstallForInstall) unimplementableInstallCommand() (byte, byte, []byte, error) {
+	var f installFields
+	f.aid("executable load file AID", i.ExecutableLoadFileAID, false)
+	f.aid("executable module AID", i.ExecutableModuleAID, false)
+	f.aid("application AID", i.ApplicationAID, false)
+	f.privileges(&i.Privileges)
+	f.long(i.Parameters.ToBytes())
+	data, err := f.bytes()
+	p1 := byte(installForInstall)
+	if i.MakeSelectable {
+		p1 |= installForMakeSelectable
+	}
+	return p1, 0x00, data, err
+}
+
+func (i InstallForInstall) installToken() []byte {
+	return i.Token
+}
+
+// Sign sets the delegated management token (the install token) for the command
+func (i *InstallForInstall) Sign(key TokenKey, randR io.Reader) (err error) {
+	i.Token, err = signInstall(*i, key, randR)
+	return err
+}
+
+// InstallForMakeSelectable makes an installed application selectable
+type InstallForMakeSelectable struct {
+	ApplicationAID []byte
+	Privileges     Privileges
+	Parameters     []byte // Make selectable parameters, e.g. a EF object
+	Token          []byte // Delegated management token, see Sign
+}
+
+func (i InstallForMakeSelectable) unimplementableInstallCommand() (byte, byte, []byte, error) {
+	var f installFields
+	f.short("", nil)
+	f.short("", nil)
+	f.aid("application AID", i.ApplicationAID, false)
+	f.privileges(&i.Privileges)
+	f.long(i.Parameters)
+	data, err := f.bytes()
+	return installForMakeSelectable, 0x00, data, err
+}
+
+func (i InstallForMakeSelectable) installToken() []byte {
+	return i.Token
+}
+
+// Sign sets the delegated management token (the make selectable token) for the command
+func (i *InstallForMakeSelectable) Sign(key TokenKey, randR io.Reader) (err error) {
+	i.Token, err = signInstall(*i, key, randR)
+	return err
+}
+
+// InstallForExtradition associates an application or executable load file with another security domain
+type InstallForExtradition struct {
+	SecurityDomainAID []byte
+	ApplicationAID    []byte // The application or executable load file to extradite
+	Parameters        []byte
+	Token             []byte // Delegated management token, see Sign
+}
+
+func (i InstallForExtradition) unimplementableInstallCommand() (byte, byte, []byte, error) {
+	var f installFields
+	f.aid("security domain AID", i.SecurityDomainAID, false)
+	f.short("", nil)
+	f.aid("application AID", i.ApplicationAID, false)
+	f.short("", nil)
+	f.long(i.Parameters)
+	data, err := f.bytes()
+	return installForExtradition, 0x00, data, err
+}
+
+func (i InstallForExtradition) installToken() []byte {
+	return i.Token
+}
+
+// Sign sets the delegated management token (the extradition token) for the command
+func (i *InstallForExtradition) Sign(key TokenKey, randR io.Reader) (err error) {
+	i.Token, err = signInstall(*i, key, randR)
+	return err
+}
+
+// InstallForRegistryUpdate updates the registry entry of an application, optionally moving it to another security domain
+type InstallForRegistryUpdate struct {
+	SecurityDomainAID []byte      // Empty to leave the associated security domain unchanged
+	ApplicationAID    []byte      // Empty to update only the security domain the command is sent to
+	Privileges        *Privileges // Nil to leave the privileges unchanged
+	Parameters        []byte
+	Token             []byte // Delegated management token, see Sign
+}
+
+func (i InstallForRegistryUpdate) unimplementableInstallCommand() (byte, byte, []byte, error) {
+	var f installFields
+	f.aid("security domain AID", i.SecurityDomainAID, true)
+	f.short("", nil)
+	f.aid("application AID", i.ApplicationAID, true)
+	f.privileges(i.Privileges)
+	f.long(i.Parameters)
+	data, err := f.bytes()
+	return installForRegistryUpdate, 0x00, data, err
+}
+
+func (i InstallForRegistryUpdate) installToken() []byte {
+	return i.Token
+}
+
+// Sign sets the delegated management token (the registry update token) for the command
+func (i *InstallForRegistryUpdate) Sign(key TokenKey, randR io.Reader) (err error) {
+	i.Token, err = signInstall(*i, key, randR)
+	return err
+}
+
+// InstallForPersonalization tells the security domain to forward the following STORE DATA commands to an application
+type InstallForPersonalization struct {
+	ApplicationAID []byte
+}
+
+func (i InstallForPersonalization) unimplementableInstallCommand() (byte, byte, []byte, error) {
+	var f installFields
+	f.short("", nil)
+	f.short("", nil)
+	f.aid("application AID", i.ApplicationAID, false)
+	f.short("", nil)
+	f.short("", nil)
+	// The empty token length is added with the rest
+	data, err := f.bytes()
+	return installForPersonalization, 0x00, data, err
+}
+
+func (i InstallForPersonalization) installToken() []byte {
+	// Personalization is never authorised by a token
+	return nil
+}
+
+// signInstall signs the token data of an INSTALL command
+func signInstall(cmd InstallCommand, key TokenKey, randR io.Reader) ([]byte, error) {
+	p1, p2, data, err := cmd.unimplementableInstallCommand()
+	if err != nil {
+		return nil, err
+	}
+	return key.SignToken(randR, p1, p2, data)
+}
+
+// InstallCommandToCommand builds the INSTALL command, with the token length and any token ending the data field
+func InstallCommandToCommand(cmd InstallCommand) (Command, error) {
+	if cmd == nil {
+		return Command{}, fmt.Errorf("must supply a non-nil command")
+	}
+	p1, p2, data, err := cmd.unimplementableInstallCommand()
+	if err != nil {
+		return Command{}, fmt.Errorf("encoding command: %w", err)
+	}
+	f := installFields{}
+	f.buf.Write(data)
+	f.long(cmd.installToken())
+	data = f.buf.Bytes()
+	return Command{
+		Class:              Class{IsGPCommand: true},
+		Instruction:        InstructionInstall,
+		P1:                 p1,
+		P2:                 p2,
+		Data:               data,
+		ExpectResponseData: true,
+	}, nil
+}
+
+// Install sends an INSTALL command, returning the delegated management confirmation if the card sent one
+func (c *Client) Install(cmd InstallCommand) (*ResponseConfirmation, error) {
+	command, err := InstallCommandToCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.sendChained(command)
+	if err != nil {
+		return nil, err
+	}
+	return optionalConfirmation(res)
+}
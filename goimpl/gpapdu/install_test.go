@@ -0,0 +1,145 @@
+package gpapdu
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallCommandToCommand(t *testing.T) {
+	pkg := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01}
+	module := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x01}
+	app := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x02}
+	sd := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00, 0x00}
+	tests := []struct {
+		name      string
+		cmd       InstallCommand
+		wantP1    byte
+		wantData  []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "for load",
+			cmd:       InstallForLoad{LoadFileAID: pkg, LoadParameters: SystemParameters{NonVolatileCodeMemoryQuota: 0x1000}},
+			wantP1:    0x02,
+			wantData:  append(append([]byte{0x06}, pkg...), 0x00, 0x00, 0x06, 0xEF, 0x04, 0xC6, 0x02, 0x10, 0x00, 0x00),
+			assertion: assert.NoError,
+		},
+		{
+			name: "for install and make selectable",
+			cmd: InstallForInstall{
+				ExecutableLoadFileAID: pkg,
+				ExecutableModuleAID:   module,
+				ApplicationAID:        app,
+				Privileges:            Privileges{CardReset: true},
+				Parameters:            InstallParameters{System: SystemParameters{ImplicitSelection: []byte{0x81}}},
+				MakeSelectable:        true,
+			},
+			wantP1: 0x0C,
+			wantData: append(append(append(append(append(append([]byte{0x06}, pkg...), 0x07), module...), 0x07), app...),
+				0x01, 0x04, 0x07, 0xC9, 0x00, 0xEF, 0x03, 0xCF, 0x01, 0x81, 0x00),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "for install with three byte privileges",
+			cmd:       InstallForInstall{ExecutableLoadFileAID: pkg, ExecutableModuleAID: module, ApplicationAID: app, Privileges: Privileges{ContactlessActivation: true}},
+			wantP1:    0x04,
+			wantData:  append(append(append(append(append(append([]byte{0x06}, pkg...), 0x07), module...), 0x07), app...), 0x03, 0x00, 0x00, 0x20, 0x02, 0xC9, 0x00, 0x00),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "for make selectable",
+			cmd:       InstallForMakeSelectable{ApplicationAID: app},
+			wantP1:    0x08,
+			wantData:  append(append([]byte{0x00, 0x00, 0x07}, app...), 0x01, 0x00, 0x00, 0x00),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "for extradition",
+			cmd:       InstallForExtradition{SecurityDomainAID: sd, ApplicationAID: app},
+			wantP1:    0x10,
+			wantData:  append(append(append(append([]byte{0x08}, sd...), 0x00, 0x07), app...), 0x00, 0x00, 0x00),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "for registry update keeping privileges",
+			cmd:       InstallForRegistryUpdate{ApplicationAID: app},
+			wantP1:    0x40,
+			wantData:  append(append([]byte{0x00, 0x00, 0x07}, app...), 0x00, 0x00, 0x00),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "for personalization",
+			cmd:       InstallForPersonalization{ApplicationAID: app},
+			wantP1:    0x20,
+			wantData:  append(append([]byte{0x00, 0x00, 0x07}, app...), 0x00, 0x00, 0x00),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "AID too short",
+			cmd:       InstallForPersonalization{ApplicationAID: app[:4]},
+			assertion: assert.Error,
+		},
+		{
+			name:      "nil",
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := InstallCommandToCommand(tt.cmd)
+			tt.assertion(t, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, InstructionInstall, got.Instruction)
+			assert.Equal(t, tt.wantP1, got.P1)
+			assert.Equal(t, tt.wantData, got.Data)
+		})
+	}
+}
+
+func TestInstallForInstall_Sign(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cmd := InstallForInstall{
+		ExecutableLoadFileAID: []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01},
+		ExecutableModuleAID:   []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x01},
+		ApplicationAID:        []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x02},
+		Parameters:            InstallParameters{ApplicationSpecific: []byte{0x01}, Other: []bertlv.Object{{Tag: bertlv.TagFromUintForced(0xEA), Value: []byte{0x80, 0x00}}}},
+		MakeSelectable:        true,
+	}
+	p1, p2, unsigned, err := cmd.unimplementableInstallCommand()
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Sign(TokenKey{Signer: ecKey}, rand.Reader))
+	assert.NoError(t, VerifyToken(&ecKey.PublicKey, 0, p1, p2, unsigned, cmd.Token))
+	got, err := InstallCommandToCommand(cmd)
+	assert.NoError(t, err)
+	assert.Equal(t, append(append(append([]byte{}, unsigned...), 0x40), cmd.Token...), got.Data)
+}
+
+func TestClient_Install(t *testing.T) {
+	confirmation := []byte{0x00, 0x02, 0x00, 0x07, 0x01, 0xAA}
+	tests := []struct {
+		name      string
+		card      *fixedCard
+		want      *ResponseConfirmation
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "no confirmation", card: &fixedCard{data: []byte{0x00}}, assertion: assert.NoError},
+		{name: "confirmation", card: &fixedCard{data: confirmation}, want: &ResponseConfirmation{ConfirmationCounter: 7, SDUniqueData: []byte{0xAA}}, assertion: assert.NoError},
+		{name: "refused", card: &fixedCard{status: apdu.RawStatus{SW1: 0x69, SW2: 0x85}}, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewClient(tt.card).Install(InstallForMakeSelectable{ApplicationAID: []byte{0xA0, 0x00, 0x00, 0x01, 0x51}})
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.Len(t, tt.card.commands, 1)
+		})
+	}
+}
@@ -1,3 +1,8 @@
 package gpapdu
 
-const ()
+import "github.com/llkennedy/globalplatform/goimpl/apdu"
+
+const (
+	// InstructionPutKey is the PutKey instruction
+	InstructionPutKey apdu.Instruction = 0xD8
+)
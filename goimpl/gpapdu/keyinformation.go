@@ -0,0 +1,87 @@
+package gpapdu
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+)
+
+const tagKeyInformationData = 0xC0
+
+// KeyComponentInformation is the type and length in bytes of one component of a key
+type KeyComponentInformation struct {
+	Type   KeyType
+	Length uint16
+}
+
+// KeyInformation describes one key on the card, as listed in the Key Information Template
+type KeyInformation struct {
+	KeyIdentifier    byte
+	KeyVersionNumber byte
+	Components       []KeyComponentInformation
+	ExtendedFormat   bool
+	Usage            []byte // Key usage qualifier, extended format only
+	Access           []byte // Key access, extended format only
+}
+
+// ParseKeyInformationTemplate parses the value of the Key Information Template, which holds one key information data object per key
+func ParseKeyInformationTemplate(value []byte) ([]KeyInformation, error) {
+	objects, err := bertlv.ReadAll(value)
+	if err != nil {
+		return nil, fmt.Errorf("reading key information template: %w", err)
+	}
+	var out []KeyInformation
+	for _, obj := range objects {
+		if obj.Tag != bertlv.TagFromUintForced(tagKeyInformationData) {
+			return nil, fmt.Errorf("unexpected object in key information template")
+		}
+		info, err := ParseKeyInformation(obj.Value)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// ParseKeyInformation parses the key information data of a single key, in either the basic or extended format
+func ParseKeyInformation(data []byte) (KeyInformation, error) {
+	if len(data) < 4 {
+		return KeyInformation{}, fmt.Errorf("key information must be at least 4 bytes, got %d", len(data))
+	}
+	info := KeyInformation{KeyIdentifier: data[0], KeyVersionNumber: data[1]}
+	data = data[2:]
+	if KeyType(data[0]) != ExtendedFormat {
+		if len(data)%2 != 0 {
+			return KeyInformation{}, fmt.Errorf("key information for key %02X version %02X has an incomplete component", info.KeyIdentifier, info.KeyVersionNumber)
+		}
+		for i := 0; i < len(data); i += 2 {
+			info.Components = append(info.Components, KeyComponentInformation{Type: KeyType(data[i]), Length: uint16(data[i+1])})
+		}
+		return info, nil
+	}
+	info.ExtendedFormat = true
+	for len(data) > 0 && KeyType(data[0]) == ExtendedFormat {
+		if len(data) < 4 {
+			return KeyInformation{}, fmt.Errorf("key information for key %02X version %02X has an incomplete component", info.KeyIdentifier, info.KeyVersionNumber)
+		}
+		info.Components = append(info.Components, KeyComponentInformation{Type: KeyType(data[1]), Length: uint16(data[2])<<8 | uint16(data[3])})
+		data = data[4:]
+	}
+	// The key usage and key access follow the components, each preceded by its length
+	for _, field := range []*[]byte{&info.Usage, &info.Access} {
+		if len(data) == 0 {
+			break
+		}
+		length := int(data[0])
+		if len(data) < 1+length {
+			return KeyInformation{}, fmt.Errorf("key information for key %02X version %02X is truncated", info.KeyIdentifier, info.KeyVersionNumber)
+		}
+		*field = data[1 : 1+length]
+		data = data[1+length:]
+	}
+	if len(data) > 0 {
+		return KeyInformation{}, fmt.Errorf("%d bytes after key information for key %02X version %02X", len(data), info.KeyIdentifier, info.KeyVersionNumber)
+	}
+	return info, nil
+}
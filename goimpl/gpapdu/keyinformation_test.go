@@ -0,0 +1,51 @@
+package gpapdu
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeyInformationTemplate(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     []byte
+		want      []KeyInformation
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:  "basic",
+			value: []byte{0xC0, 0x04, 0x01, 0x30, 0x88, 0x10, 0xC0, 0x04, 0x02, 0x30, 0x88, 0x10, 0xC0, 0x06, 0x01, 0x01, 0xA1, 0x80, 0xA0, 0x03},
+			want: []KeyInformation{
+				{KeyIdentifier: 0x01, KeyVersionNumber: 0x30, Components: []KeyComponentInformation{{Type: AES, Length: 16}}},
+				{KeyIdentifier: 0x02, KeyVersionNumber: 0x30, Components: []KeyComponentInformation{{Type: AES, Length: 16}}},
+				{KeyIdentifier: 0x01, KeyVersionNumber: 0x01, Components: []KeyComponentInformation{{Type: RSAPublicKeyModulesNClearText, Length: 0x80}, {Type: RSAPublicKeyPubExponentEClearText, Length: 3}}},
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:  "extended",
+			value: []byte{0xC0, 0x0D, 0x01, 0x11, 0xFF, 0xB0, 0x00, 0x41, 0xFF, 0xF0, 0x00, 0x01, 0x01, 0x3C, 0x00},
+			want: []KeyInformation{{
+				KeyIdentifier:    0x01,
+				KeyVersionNumber: 0x11,
+				Components:       []KeyComponentInformation{{Type: ECCPublicKey, Length: 0x41}, {Type: ECCKeyParametersReference, Length: 1}},
+				ExtendedFormat:   true,
+				Usage:            []byte{0x3C},
+				Access:           []byte{},
+			}},
+			assertion: assert.NoError,
+		},
+		{name: "incomplete basic component", value: []byte{0xC0, 0x05, 0x01, 0x30, 0x88, 0x10, 0x88}, assertion: assert.Error},
+		{name: "truncated extended usage", value: []byte{0xC0, 0x08, 0x01, 0x11, 0xFF, 0x88, 0x00, 0x10, 0x02, 0x3C}, assertion: assert.Error},
+		{name: "too short", value: []byte{0xC0, 0x02, 0x01, 0x30}, assertion: assert.Error},
+		{name: "wrong tag", value: []byte{0xC1, 0x04, 0x01, 0x30, 0x88, 0x10}, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseKeyInformationTemplate(tt.value)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -53,3 +53,20 @@ const (
 	// ExtendedFormat is the ExtendedFormat key type
 	ExtendedFormat KeyType = 0xFF
 )
+
+// Secret returns whether components of this type are secret, and must be enciphered with the DEK before they are sent to the card
+func (t KeyType) Secret() bool {
+	switch t {
+	case DESWithImplicitMode, PreSharedKeyForTransportLayerSecurity, AES, HMACSHA1WithImplictLength, HMACSHA1Length160Bits,
+		RSAPrivateKeyModulusN, RSAPrivateKeyPrivateExponentD, RSAPrivateKeyChineseRemainderP, RSAPrivateKeyChineseRemainderQ,
+		RSAPrivateKeyChineseRemainderPQ, RSAPrivateKeyChineseRemainderDP1, RSAPrivateKeyChineseRemainderDQ1, ECCPrivateKey:
+		return true
+	default:
+		return false
+	}
+}
+
+// HasKeyCheckValue returns whether a key check value is sent with and returned for components of this type
+func (t KeyType) HasKeyCheckValue() bool {
+	return t == DESWithImplicitMode || t == AES
+}
@@ -0,0 +1,129 @@
+package gpapdu
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+)
+
+const (
+	// InstructionLoad is the Load instruction
+	InstructionLoad apdu.Instruction = 0xE8
+)
+
+const (
+	tagDAPBlock                  = 0xE2
+	tagDAPSignature              = 0xC3
+	tagLoadFileDataBlock         = 0xC4
+	tagCipheredLoadFileDataBlock = 0xD4
+)
+
+// DefaultLoadBlockSize is the default amount of data in each LOAD command, leaving room for a 16 byte C-MAC and C-DECRYPTION padding within 255 bytes
+const DefaultLoadBlockSize = 223
+
+// DAPBlock is the signature of the load file data block by a security domain with DAP verification
+type DAPBlock struct {
+	SecurityDomainAID []byte
+	Signature         []byte
+}
+
+// LoadCommand is a LOAD sequence for one load file
+type LoadCommand struct {
+	DAPBlocks         []DAPBlock
+	LoadFileDataBlock []byte
+	// Cipher enciphers the load file data block for security domains with the CipheredLoadFileDataBlock privilege, when set the block is padded, enciphered and sent as D4 rather than C4
+	Cipher SensitiveDataEncryptor
+	// BlockSize is the amount of data in each LOAD command, DefaultLoadBlockSize is used when zero.
+	// It must be small enough for the card's buffer once the secure channel has added its MAC and padding.
+	BlockSize int
+	// Progress is called after the card accepts each block with the number of bytes sent so far and the total
+	Progress func(sent, total int)
+}
+
+// ToBytes encodes the full LOAD data, the DAP blocks followed by the load file data block
+func (l LoadCommand) ToBytes() ([]byte, error) {
+	var objects []bertlv.Object
+	for i, dap := range l.DAPBlocks {
+		if len(dap.SecurityDomainAID) < 5 || len(dap.SecurityDomainAID) > 16 {
+			return nil, fmt.Errorf("DAP block %d: security domain AID must be between 5 and 16 bytes, got %d", i, len(dap.SecurityDomainAID))
+		}
+		objects = append(objects, bertlv.Object{
+			Tag: bertlv.TagFromUintForced(tagDAPBlock),
+			Value: encodeObjects([]bertlv.Object{
+				{Tag: bertlv.TagFromUintForced(tagELFileOrAppID), Value: dap.SecurityDomainAID},
+				{Tag: bertlv.TagFromUintForced(tagDAPSignature), Value: dap.Signature},
+			}),
+		})
+	}
+	if len(l.LoadFileDataBlock) == 0 {
+		return nil, fmt.Errorf("load file data block is empty")
+	}
+	block := bertlv.Object{Tag: bertlv.TagFromUintForced(tagLoadFileDataBlock), Value: l.LoadFileDataBlock}
+	if l.Cipher != nil {
+		ciphered, err := l.Cipher.EncryptSensitiveData(pad80(l.LoadFileDataBlock, 16))
+		if err != nil {
+			return nil, fmt.Errorf("enciphering load file data block: %w", err)
+		}
+		block = bertlv.Object{Tag: bertlv.TagFromUintForced(tagCipheredLoadFileDataBlock), Value: ciphered}
+	}
+	return encodeObjects(append(objects, block)), nil
+}
+
+// Commands splits the LOAD data into numbered blocks, P2 is the block number modulo 256 and P1 b8 marks the last block
+func (l LoadCommand) Commands() ([]Command, error) {
+	blockSize := l.BlockSize
+	if blockSize == 0 {
+		blockSize = DefaultLoadBlockSize
+	}
+	if blockSize < 1 || blockSize > 255 {
+		return nil, fmt.Errorf("block size must be between 1 and 255, got %d", blockSize)
+	}
+	data, err := l.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	count := (len(data) + blockSize - 1) / blockSize
+	commands := make([]Command, 0, count)
+	for i := 0; i < count; i++ {
+		start := i * blockSize
+		end := start + blockSize
+		cmd := Command{
+			Class:              Class{IsGPCommand: true},
+			Instruction:        InstructionLoad,
+			P2:                 byte(i % 256),
+			ExpectResponseData: true,
+		}
+		if end >= len(data) {
+			end = len(data)
+			cmd.P1 = b8
+		}
+		cmd.Data = data[start:end]
+		commands = append(commands, cmd)
+	}
+	return commands, nil
+}
+
+// Load sends the LOAD sequence following an INSTALL [for load], returning the delegated management confirmation if the card sent one with the last block
+func (c *Client) Load(cmd LoadCommand) (*ResponseConfirmation, error) {
+	commands, err := cmd.Commands()
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	for _, next := range commands {
+		total += len(next.Data)
+	}
+	sent := 0
+	var res apdu.Response
+	for i, next := range commands {
+		if res, err = c.sendChained(next); err != nil {
+			return nil, fmt.Errorf("loading block %d: %w", i, err)
+		}
+		sent += len(next.Data)
+		if cmd.Progress != nil {
+			cmd.Progress(sent, total)
+		}
+	}
+	return optionalConfirmation(res)
+}
@@ -0,0 +1,116 @@
+package gpapdu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadCommand_ToBytes(t *testing.T) {
+	sd := []byte{0xA0, 0x00, 0x00, 0x01, 0x51}
+	dek := newTestDEK()
+	ciphered, _ := dek.EncryptSensitiveData(append([]byte{0x01, 0x02}, 0x80, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0))
+	tests := []struct {
+		name      string
+		cmd       LoadCommand
+		want      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "plain",
+			cmd:       LoadCommand{LoadFileDataBlock: []byte{0x01, 0x02}},
+			want:      []byte{0xC4, 0x02, 0x01, 0x02},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "with DAP",
+			cmd:       LoadCommand{DAPBlocks: []DAPBlock{{SecurityDomainAID: sd, Signature: []byte{0x55}}}, LoadFileDataBlock: []byte{0x01, 0x02}},
+			want:      append(append([]byte{0xE2, 0x0A, 0x4F, 0x05}, sd...), 0xC3, 0x01, 0x55, 0xC4, 0x02, 0x01, 0x02),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "ciphered",
+			cmd:       LoadCommand{LoadFileDataBlock: []byte{0x01, 0x02}, Cipher: dek},
+			want:      append([]byte{0xD4, 0x10}, ciphered...),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "empty",
+			cmd:       LoadCommand{},
+			assertion: assert.Error,
+		},
+		{
+			name:      "bad DAP AID",
+			cmd:       LoadCommand{DAPBlocks: []DAPBlock{{}}, LoadFileDataBlock: []byte{0x01}},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.ToBytes()
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLoadCommand_Commands(t *testing.T) {
+	block := bytes.Repeat([]byte{0xAB}, 1000)
+	cmds, err := LoadCommand{LoadFileDataBlock: block, BlockSize: 250}.Commands()
+	assert.NoError(t, err)
+	// 4 bytes of C4 header and 1000 bytes of data
+	if assert.Len(t, cmds, 5) {
+		for i, cmd := range cmds {
+			assert.Equal(t, byte(i), cmd.P2)
+			assert.Equal(t, InstructionLoad, cmd.Instruction)
+		}
+		assert.Equal(t, byte(0x00), cmds[3].P1)
+		assert.Equal(t, byte(0x80), cmds[4].P1)
+		assert.Len(t, cmds[4].Data, 4)
+		assert.Equal(t, []byte{0xC4, 0x82, 0x03, 0xE8}, cmds[0].Data[:4])
+	}
+	_, err = LoadCommand{LoadFileDataBlock: block, BlockSize: 256}.Commands()
+	assert.Error(t, err)
+	t.Run("block number wraps", func(t *testing.T) {
+		large := bytes.Repeat([]byte{0xCD}, 300*1024)
+		cmds, err := LoadCommand{LoadFileDataBlock: large}.Commands()
+		assert.NoError(t, err)
+		assert.Greater(t, len(cmds), 256)
+		var data []byte
+		for i, cmd := range cmds {
+			assert.Equal(t, byte(i%256), cmd.P2)
+			if i == len(cmds)-1 {
+				assert.Equal(t, byte(0x80), cmd.P1)
+			} else {
+				assert.Equal(t, byte(0x00), cmd.P1, "block %d", i)
+			}
+			data = append(data, cmd.Data...)
+		}
+		assert.Equal(t, byte(0x00), cmds[256].P2)
+		assert.Equal(t, byte(0x01), cmds[257].P2)
+		assert.Equal(t, append([]byte{0xC4, 0x83, 0x04, 0xB0, 0x00}, large...), data)
+	})
+}
+
+func TestClient_Load(t *testing.T) {
+	card := &fixedCard{data: []byte{0x00}}
+	var progress [][2]int
+	res, err := NewClient(card).Load(LoadCommand{
+		LoadFileDataBlock: make([]byte, 500),
+		Progress: func(sent, total int) {
+			progress = append(progress, [2]int{sent, total})
+		},
+	})
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+	assert.Len(t, card.commands, 3)
+	assert.Equal(t, [][2]int{{223, 504}, {446, 504}, {504, 504}}, progress)
+	t.Run("error stops the sequence", func(t *testing.T) {
+		card := &fixedCard{status: apdu.RawStatus{SW1: 0x6A, SW2: 0x84}}
+		_, err := NewClient(card).Load(LoadCommand{LoadFileDataBlock: make([]byte, 500)})
+		assert.Error(t, err)
+		assert.Len(t, card.commands, 1)
+	})
+}
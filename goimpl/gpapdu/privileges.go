@@ -1,5 +1,12 @@
 package gpapdu
 
+import (
+	"fmt"
+	"strings"
+
+	lifecycle "github.com/llkennedy/globalplatform/goimpl/lifcecycle"
+)
+
 const (
 	privSecurityDomain            = 0x80
 	privDAPVerification           = 0x40
@@ -216,3 +223,85 @@ func PrivilegesFromBytes(in [3]byte) Privileges {
 	}
 	return out
 }
+
+// ParsePrivileges decodes privileges from their 1 byte (legacy) or 3 byte encoding.
+// Privileges which imply others must be encoded with the privileges they imply, so e.g. Mandated DAP Verification without DAP Verification is an error.
+func ParsePrivileges(in []byte) (Privileges, error) {
+	var full [3]byte
+	if len(in) != 1 && len(in) != 3 {
+		return Privileges{}, fmt.Errorf("privileges must be 1 or 3 bytes, got %d", len(in))
+	}
+	copy(full[:], in)
+	sd := full[0]&privSecurityDomain != 0
+	if full[0]&privDAPVerification != 0 && !sd {
+		return Privileges{}, fmt.Errorf("DAP Verification privilege without Security Domain privilege in %X", in)
+	}
+	if full[0]&privDelegatedManagement != 0 && !sd {
+		return Privileges{}, fmt.Errorf("Delegated Management privilege without Security Domain privilege in %X", in)
+	}
+	if full[0]&privMandatedDAPVerification != 0 && (full[0]&privDAPVerification == 0 || !sd) {
+		return Privileges{}, fmt.Errorf("Mandated DAP Verification privilege without DAP Verification and Security Domain privileges in %X", in)
+	}
+	if full[1]&privAuthorizedManagement != 0 && !sd {
+		return Privileges{}, fmt.Errorf("Authorized Management privilege without Security Domain privilege in %X", in)
+	}
+	return PrivilegesFromBytes(full), nil
+}
+
+// Reserved returns the reserved for future use bits of the third privileges byte
+func (p Privileges) Reserved() byte {
+	return p.ToBytes()[2] & (privReserved1 | privReserved2 | privReserved3 | privReserved4)
+}
+
+// String lists the names of the granted privileges, including those implied by others
+func (p Privileges) String() string {
+	encoded := p.ToBytes()
+	names := []struct {
+		index int
+		bit   byte
+		name  string
+	}{
+		{0, privSecurityDomain, "Security Domain"},
+		{0, privDAPVerification, "DAP Verification"},
+		{0, privDelegatedManagement, "Delegated Management"},
+		{0, privCardLock, "Card Lock"},
+		{0, privCardTerminate, "Card Terminate"},
+		{0, privCardReset, "Card Reset"},
+		{0, privCVMManagement, "CVM Management"},
+		{0, privMandatedDAPVerification, "Mandated DAP Verification"},
+		{1, privTrustedPath, "Trusted Path"},
+		{1, privAuthorizedManagement, "Authorized Management"},
+		{1, privTokenManagement, "Token Management"},
+		{1, privGlobalDelete, "Global Delete"},
+		{1, privGlobalLock, "Global Lock"},
+		{1, privGlobalRegistry, "Global Registry"},
+		{1, privFinalApplication, "Final Application"},
+		{1, privGlobalService, "Global Service"},
+		{2, privReceiptGeneration, "Receipt Generation"},
+		{2, privCipheredLoadFileDataBlock, "Ciphered Load File Data Block"},
+		{2, privContactlessActivation, "Contactless Activation"},
+		{2, privContactlessSelfActivation, "Contactless Self-Activation"},
+	}
+	var granted []string
+	for _, n := range names {
+		if encoded[n.index]&n.bit != 0 {
+			granted = append(granted, n.name)
+		}
+	}
+	if reserved := p.Reserved(); reserved != 0 {
+		granted = append(granted, fmt.Sprintf("RFU %02X", reserved))
+	}
+	if len(granted) == 0 {
+		return "none"
+	}
+	return strings.Join(granted, ", ")
+}
+
+// LifeCycleRequester returns the privileges which decide life cycle transitions, the caller sets which role the requester has towards the target
+func (p Privileges) LifeCycleRequester() lifecycle.Requester {
+	return lifecycle.Requester{
+		CardLock:      p.CardLock,
+		CardTerminate: p.CardTerminate,
+		GlobalLock:    p.GlobalLock,
+	}
+}
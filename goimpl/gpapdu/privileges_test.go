@@ -3,6 +3,8 @@ package gpapdu
 import (
 	"testing"
 
+	lifecycle "github.com/llkennedy/globalplatform/goimpl/lifcecycle"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -38,7 +40,10 @@ func TestPrivileges_ToBytes(t *testing.T) {
 		fields fields
 		want   [3]byte
 	}{
-		// TODO: Add test cases.
+		{name: "none", want: [3]byte{}},
+		{name: "implied", fields: fields{MandatedDAPVerification: true}, want: [3]byte{0xC1, 0x00, 0x00}},
+		{name: "authorized management", fields: fields{AuthorizedManagement: true, GlobalService: true}, want: [3]byte{0x80, 0x41, 0x00}},
+		{name: "third byte", fields: fields{ReceiptGeneration: true, ContactlessSelfActivation: true, reserved4: true}, want: [3]byte{0x00, 0x00, 0x91}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -82,7 +87,9 @@ func TestPrivilegesFromBytes(t *testing.T) {
 		args args
 		want Privileges
 	}{
-		// TODO: Add test cases.
+		{name: "none", want: Privileges{}},
+		{name: "implied", args: args{in: [3]byte{0x01, 0x00, 0x00}}, want: Privileges{SecurityDomain: true, DAPVerification: true, MandatedDAPVerification: true}},
+		{name: "reserved", args: args{in: [3]byte{0x00, 0x00, 0x0A}}, want: Privileges{reserved1: true, reserved3: true}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -90,3 +97,38 @@ func TestPrivilegesFromBytes(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePrivileges(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        []byte
+		want      Privileges
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "legacy", in: []byte{0xE0}, want: Privileges{SecurityDomain: true, DAPVerification: true, DelegatedManagement: true}, assertion: assert.NoError},
+		{name: "full", in: []byte{0x9E, 0xFE, 0x80}, want: Privileges{SecurityDomain: true, CardLock: true, CardTerminate: true, CardReset: true, CVMManagement: true, TrustedPath: true, AuthorizedManagement: true, TokenManagement: true, GlobalDelete: true, GlobalLock: true, GlobalRegistry: true, FinalApplication: true, ReceiptGeneration: true}, assertion: assert.NoError},
+		{name: "mandated DAP without DAP", in: []byte{0x81}, assertion: assert.Error},
+		{name: "DAP without security domain", in: []byte{0x40, 0x00, 0x00}, assertion: assert.Error},
+		{name: "delegated management without security domain", in: []byte{0x20}, assertion: assert.Error},
+		{name: "authorized management without security domain", in: []byte{0x00, 0x40, 0x00}, assertion: assert.Error},
+		{name: "wrong length", in: []byte{0x80, 0x00}, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePrivileges(tt.in)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPrivileges_String(t *testing.T) {
+	assert.Equal(t, "none", Privileges{}.String())
+	assert.Equal(t, "Security Domain, DAP Verification, Mandated DAP Verification", Privileges{MandatedDAPVerification: true}.String())
+	assert.Equal(t, "Global Service, Contactless Self-Activation, RFU 03", PrivilegesFromBytes([3]byte{0x00, 0x01, 0x13}).String())
+	assert.Equal(t, byte(0x03), PrivilegesFromBytes([3]byte{0x00, 0x01, 0x13}).Reserved())
+}
+
+func TestPrivileges_LifeCycleRequester(t *testing.T) {
+	assert.Equal(t, lifecycle.Requester{CardLock: true, GlobalLock: true}, Privileges{CardLock: true, GlobalLock: true, CardReset: true}.LifeCycleRequester())
+}
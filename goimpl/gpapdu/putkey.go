@@ -0,0 +1,275 @@
+package gpapdu
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/subtle"
+	"fmt"
+	"io"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+)
+
+const (
+	keyCheckValueLength = 3
+	// maxKeyComponentLength is the largest length which fits in the 3 byte length field of a key data field
+	maxKeyComponentLength = 0xFFFF
+)
+
+// SensitiveDataEncryptor enciphers block-aligned secret key components with the DEK, scp02.Session and scp03.Session both implement it
+type SensitiveDataEncryptor interface {
+	EncryptSensitiveData(data []byte) ([]byte, error)
+}
+
+// KeyComponent is one component of a key in a PUT KEY command, such as a DES key or the modulus of an RSA public key
+type KeyComponent struct {
+	Type  KeyType
+	Value []byte // The clear value, secret components are enciphered with the DEK when the command is built
+}
+
+// PutKeyKey is one key in a PUT KEY command
+type PutKeyKey struct {
+	Components []KeyComponent
+	// ExtendedFormat encodes every component in the extended key data field format, which also carries the key usage and access
+	ExtendedFormat bool
+	Usage          []byte // Key usage qualifier, extended format only
+	Access         []byte // Key access, extended format only
+}
+
+// PutKeyCommand adds a new key set or replaces the keys of an existing one
+type PutKeyCommand struct {
+	// ReplaceKeyVersionNumber is the version of the key set to replace, 0 adds a new key set
+	ReplaceKeyVersionNumber byte
+	// KeyVersionNumber is the version of the new or replacing keys
+	KeyVersionNumber byte
+	// KeyIdentifier is the identifier of the first key, the rest have consecutive identifiers
+	KeyIdentifier byte
+	Keys          []PutKeyKey
+	// Token is the delegated management token, see Sign. Keys sent with a token must fit in a single command.
+	Token []byte
+}
+
+// KeyCheckValue computes the check value of a clear DES or AES key: the leading 3 bytes of 8 zero bytes enciphered with a DES key, or of 16 bytes of 01 enciphered with an AES key
+func KeyCheckValue(keyType KeyType, key []byte) ([]byte, error) {
+	var block cipher.Block
+	var data []byte
+	var err error
+	switch keyType {
+	case DESWithImplicitMode:
+		switch len(key) {
+		case 8:
+			block, err = des.NewCipher(key)
+		case 16:
+			block, err = des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+		default:
+			block, err = des.NewTripleDESCipher(key)
+		}
+		data = make([]byte, des.BlockSize)
+	case AES:
+		block, err = aes.NewCipher(key)
+		data = bytes.Repeat([]byte{0x01}, aes.BlockSize)
+	default:
+		return nil, fmt.Errorf("key type %X has no key check value", byte(keyType))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid key: %w", err)
+	}
+	block.Encrypt(data, data)
+	return data[:keyCheckValueLength], nil
+}
+
+// encode builds the key data field for the component, returning its key check value if it has one
+func (c KeyComponent) encode(dek SensitiveDataEncryptor, key PutKeyKey) (data, kcv []byte, err error) {
+	value := c.Value
+	if c.Type.HasKeyCheckValue() {
+		if kcv, err = KeyCheckValue(c.Type, c.Value); err != nil {
+			return nil, nil, err
+		}
+	}
+	if c.Type.Secret() {
+		if dek == nil {
+			return nil, nil, fmt.Errorf("a DEK is required to send secret key components of type %X", byte(c.Type))
+		}
+		plain := value
+		if c.Type == AES {
+			// AES keys are padded to the block size, with the clear length ahead of the cryptogram
+			plain = make([]byte, (len(value)+aes.BlockSize-1)/aes.BlockSize*aes.BlockSize)
+			copy(plain, value)
+		}
+		if value, err = dek.EncryptSensitiveData(plain); err != nil {
+			return nil, nil, fmt.Errorf("enciphering key component: %w", err)
+		}
+		if c.Type == AES {
+			value = append([]byte{byte(len(c.Value))}, value...)
+		}
+	}
+	if len(value) > maxKeyComponentLength {
+		return nil, nil, fmt.Errorf("key component of %d bytes is too long", len(value))
+	}
+	buf := bytes.NewBuffer(nil)
+	if key.ExtendedFormat {
+		buf.Write([]byte{byte(ExtendedFormat), 0x00, byte(c.Type)})
+	} else {
+		buf.WriteByte(byte(c.Type))
+	}
+	buf.Write(bertlv.LengthToBytes(uint64(len(value))))
+	buf.Write(value)
+	buf.WriteByte(byte(len(kcv)))
+	buf.Write(kcv)
+	if key.ExtendedFormat {
+		if len(key.Usage) > 0xFF || len(key.Access) > 0xFF {
+			return nil, nil, fmt.Errorf("key usage and access must be at most 255 bytes each")
+		}
+		buf.WriteByte(byte(len(key.Usage)))
+		buf.Write(key.Usage)
+		buf.WriteByte(byte(len(key.Access)))
+		buf.Write(key.Access)
+	}
+	return buf.Bytes(), kcv, nil
+}
+
+// encode builds the key data fields for every component of the key, returning the key check value of the first component which has one
+func (k PutKeyKey) encode(dek SensitiveDataEncryptor) (data, kcv []byte, err error) {
+	if len(k.Components) == 0 {
+		return nil, nil, fmt.Errorf("key has no components")
+	}
+	if !k.ExtendedFormat && (k.Usage != nil || k.Access != nil) {
+		return nil, nil, fmt.Errorf("key usage and access require the extended format")
+	}
+	for i, component := range k.Components {
+		encoded, componentKCV, err := component.encode(dek, k)
+		if err != nil {
+			return nil, nil, fmt.Errorf("component %d: %w", i, err)
+		}
+		data = append(data, encoded...)
+		if kcv == nil {
+			kcv = componentKCV
+		}
+	}
+	return data, kcv, nil
+}
+
+// PutKeyPart is one PUT KEY command, with the response data the card must return for it
+type PutKeyPart struct {
+	Command          Command
+	ExpectedResponse []byte // The key version number followed by the key check values of the keys in this command
+}
+
+// Commands builds the PUT KEY commands, enciphering secret components with the DEK.
+// Keys are packed into as few commands as fit in ChainBlockSize bytes, with P1 b8 set on all but the last; a single key too large for one command is sent with command chaining.
+func (p PutKeyCommand) Commands(dek SensitiveDataEncryptor) ([]PutKeyPart, error) {
+	parts, err := p.commandsWithoutToken(dek)
+	if err != nil || len(p.Token) == 0 {
+		return parts, err
+	}
+	if len(parts) != 1 {
+		return nil, fmt.Errorf("keys sent with a token must fit in a single command")
+	}
+	if parts[0].Command.Data, err = appendToken(parts[0].Command.Data, p.Token); err != nil {
+		return nil, err
+	}
+	return parts, nil
+}
+
+// Sign sets the delegated management token for the command, the DEK must be the one the command will be sent with
+func (p *PutKeyCommand) Sign(key TokenKey, randR io.Reader, dek SensitiveDataEncryptor) error {
+	parts, err := p.commandsWithoutToken(dek)
+	if err != nil {
+		return err
+	}
+	if len(parts) != 1 {
+		return fmt.Errorf("keys sent with a token must fit in a single command")
+	}
+	cmd := parts[0].Command
+	p.Token, err = key.SignToken(randR, cmd.P1, cmd.P2, cmd.Data)
+	return err
+}
+
+func (p PutKeyCommand) commandsWithoutToken(dek SensitiveDataEncryptor) ([]PutKeyPart, error) {
+	if len(p.Keys) == 0 {
+		return nil, fmt.Errorf("must supply at least one key")
+	}
+	if p.ReplaceKeyVersionNumber > 0x7F || p.KeyVersionNumber > 0x7F {
+		return nil, fmt.Errorf("key version numbers must be between 00 and 7F")
+	}
+	if p.KeyIdentifier > 0x7F || int(p.KeyIdentifier)+len(p.Keys)-1 > 0x7F {
+		return nil, fmt.Errorf("key identifiers must be between 00 and 7F")
+	}
+	var parts []PutKeyPart
+	var current *PutKeyPart
+	var keysInCurrent int
+	for i, key := range p.Keys {
+		encoded, kcv, err := key.encode(dek)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		if current != nil && len(current.Command.Data)+len(encoded) > ChainBlockSize {
+			current = nil
+		}
+		if current == nil {
+			parts = append(parts, PutKeyPart{
+				Command: Command{
+					Class:              Class{IsGPCommand: true},
+					Instruction:        InstructionPutKey,
+					P1:                 p.ReplaceKeyVersionNumber,
+					P2:                 p.KeyIdentifier + byte(i),
+					Data:               []byte{p.KeyVersionNumber},
+					ExpectResponseData: true,
+				},
+				ExpectedResponse: []byte{p.KeyVersionNumber},
+			})
+			current = &parts[len(parts)-1]
+			keysInCurrent = 0
+		}
+		current.Command.Data = append(current.Command.Data, encoded...)
+		current.ExpectedResponse = append(current.ExpectedResponse, kcv...)
+		keysInCurrent++
+		if keysInCurrent > 1 {
+			current.Command.P2 |= b8
+		}
+	}
+	for i := range parts[:len(parts)-1] {
+		parts[i].Command.P1 |= b8
+	}
+	return parts, nil
+}
+
+// PutKey sends the PUT KEY commands, verifying the key version number and key check values returned by the card.
+// dek is normally the secure channel session the client sends on.
+func (c *Client) PutKey(cmd PutKeyCommand, dek SensitiveDataEncryptor) error {
+	parts, err := cmd.Commands(dek)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		res, err := c.sendChained(part.Command)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare(res.Data, part.ExpectedResponse) != 1 {
+			return fmt.Errorf("card returned %X for PUT KEY, expected %X", res.Data, part.ExpectedResponse)
+		}
+	}
+	return nil
+}
+
+// sendChained sends each command of the chain, returning the response to the last and failing on any error status
+func (c *Client) sendChained(cmd Command) (apdu.Response, error) {
+	if c == nil || c.transport == nil {
+		return apdu.Response{}, fmt.Errorf("cannot send command with nil transport")
+	}
+	var res apdu.Response
+	for _, next := range cmd.Chain() {
+		var err error
+		if res, err = SendOnTransport(c.transport, next); err != nil {
+			return apdu.Response{}, fmt.Errorf("sending command: %w", err)
+		}
+		if err = res.GetStatus().Error(); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,191 @@
+package gpapdu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+	"github.com/stretchr/testify/assert"
+)
+
+// testDEK enciphers in the same way as an SCP03 session
+type testDEK struct {
+	key keystore.Key
+}
+
+func (d testDEK) EncryptSensitiveData(data []byte) ([]byte, error) {
+	return d.key.Encrypt(keystore.ModeCBC, nil, data)
+}
+
+func newTestDEK() testDEK {
+	key, err := keystore.NewSoftwareKey(keystore.AlgorithmAES, bytes.Repeat([]byte{0x42}, 16))
+	if err != nil {
+		panic(err)
+	}
+	return testDEK{key}
+}
+
+var defaultKey = []byte{0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F}
+
+func TestKeyCheckValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyType   KeyType
+		key       []byte
+		want      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "two key triple DES", keyType: DESWithImplicitMode, key: defaultKey, want: []byte{0x8B, 0xAF, 0x47}, assertion: assert.NoError},
+		{name: "three key triple DES", keyType: DESWithImplicitMode, key: append(append([]byte{}, defaultKey...), defaultKey[:8]...), want: []byte{0x8B, 0xAF, 0x47}, assertion: assert.NoError},
+		{name: "AES", keyType: AES, key: defaultKey, want: []byte{0x50, 0x4A, 0x77}, assertion: assert.NoError},
+		{name: "AES wrong length", keyType: AES, key: defaultKey[:10], assertion: assert.Error},
+		{name: "no check value", keyType: RSAPublicKeyModulesNClearText, key: defaultKey, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := KeyCheckValue(tt.keyType, tt.key)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestPutKeyCommand_Commands(t *testing.T) {
+	dek := newTestDEK()
+	encrypted, _ := dek.EncryptSensitiveData(defaultKey)
+	aesKey := PutKeyKey{Components: []KeyComponent{{Type: AES, Value: defaultKey}}}
+	aesKeyData := append(append([]byte{0x88, 0x11, 0x10}, encrypted...), 0x03, 0x50, 0x4A, 0x77)
+	tests := []struct {
+		name      string
+		cmd       PutKeyCommand
+		dek       SensitiveDataEncryptor
+		want      []PutKeyPart
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "replace key set",
+			cmd:  PutKeyCommand{ReplaceKeyVersionNumber: 0x30, KeyVersionNumber: 0x31, KeyIdentifier: 0x01, Keys: []PutKeyKey{aesKey, aesKey, aesKey}},
+			dek:  dek,
+			want: []PutKeyPart{{
+				Command: Command{
+					Class:              Class{IsGPCommand: true},
+					Instruction:        InstructionPutKey,
+					P1:                 0x30,
+					P2:                 0x81,
+					Data:               append(append(append([]byte{0x31}, aesKeyData...), aesKeyData...), aesKeyData...),
+					ExpectResponseData: true,
+				},
+				ExpectedResponse: []byte{0x31, 0x50, 0x4A, 0x77, 0x50, 0x4A, 0x77, 0x50, 0x4A, 0x77},
+			}},
+			assertion: assert.NoError,
+		},
+		{
+			name: "public key in extended format",
+			cmd: PutKeyCommand{KeyVersionNumber: 0x01, KeyIdentifier: 0x02, Keys: []PutKeyKey{{
+				Components:     []KeyComponent{{Type: ECCPublicKey, Value: []byte{0x04, 0x01, 0x02}}, {Type: ECCKeyParametersReference, Value: []byte{0x00}}},
+				ExtendedFormat: true,
+				Usage:          []byte{0x82},
+			}}},
+			want: []PutKeyPart{{
+				Command: Command{
+					Class:       Class{IsGPCommand: true},
+					Instruction: InstructionPutKey,
+					P2:          0x02,
+					Data: []byte{0x01,
+						0xFF, 0x00, 0xB0, 0x03, 0x04, 0x01, 0x02, 0x00, 0x01, 0x82, 0x00,
+						0xFF, 0x00, 0xF0, 0x01, 0x00, 0x00, 0x01, 0x82, 0x00},
+					ExpectResponseData: true,
+				},
+				ExpectedResponse: []byte{0x01},
+			}},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "secret key without DEK",
+			cmd:       PutKeyCommand{KeyVersionNumber: 0x01, Keys: []PutKeyKey{aesKey}},
+			assertion: assert.Error,
+		},
+		{
+			name:      "usage without extended format",
+			cmd:       PutKeyCommand{KeyVersionNumber: 0x01, Keys: []PutKeyKey{{Components: aesKey.Components, Usage: []byte{0x82}}}},
+			dek:       dek,
+			assertion: assert.Error,
+		},
+		{
+			name:      "no keys",
+			cmd:       PutKeyCommand{KeyVersionNumber: 0x01},
+			dek:       dek,
+			assertion: assert.Error,
+		},
+		{
+			name:      "key version number too large",
+			cmd:       PutKeyCommand{KeyVersionNumber: 0x80, Keys: []PutKeyKey{aesKey}},
+			dek:       dek,
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.Commands(tt.dek)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	t.Run("AES-192 is padded", func(t *testing.T) {
+		key := append(append([]byte{}, defaultKey...), defaultKey[:8]...)
+		parts, err := PutKeyCommand{KeyVersionNumber: 0x01, Keys: []PutKeyKey{{Components: []KeyComponent{{Type: AES, Value: key}}}}}.Commands(dek)
+		assert.NoError(t, err)
+		data := parts[0].Command.Data
+		assert.Equal(t, []byte{0x01, 0x88, 0x21, 0x18}, data[:4])
+		assert.Len(t, data, 4+32+4)
+	})
+	t.Run("split across commands", func(t *testing.T) {
+		keys := make([]PutKeyKey, 12)
+		for i := range keys {
+			keys[i] = aesKey
+		}
+		parts, err := PutKeyCommand{KeyVersionNumber: 0x01, KeyIdentifier: 0x01, Keys: keys}.Commands(dek)
+		assert.NoError(t, err)
+		if assert.Len(t, parts, 2) {
+			assert.Equal(t, byte(0x80), parts[0].Command.P1)
+			assert.Equal(t, byte(0x81), parts[0].Command.P2)
+			assert.Equal(t, byte(0x00), parts[1].Command.P1)
+			// 9 keys fit in the first command, leaving the other 3 for the second
+			assert.Equal(t, byte(0x8A), parts[1].Command.P2)
+		}
+	})
+}
+
+// putKeyCard answers PUT KEY with the key version number and a fixed list of key check values
+type putKeyCard struct {
+	kcvs     []byte
+	commands []apdu.Command
+}
+
+func (c *putKeyCard) Send(cmd apdu.Command) (apdu.Response, error) {
+	c.commands = append(c.commands, cmd)
+	return apdu.Response{Data: append([]byte{cmd.Data[0]}, c.kcvs...), Status: apdu.RawStatus{SW1: 0x90}.Identify()}, nil
+}
+
+func TestClient_PutKey(t *testing.T) {
+	cmd := PutKeyCommand{KeyVersionNumber: 0x31, KeyIdentifier: 0x01, Keys: []PutKeyKey{{Components: []KeyComponent{{Type: AES, Value: defaultKey}}}}}
+	tests := []struct {
+		name      string
+		kcvs      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "matching check value", kcvs: []byte{0x50, 0x4A, 0x77}, assertion: assert.NoError},
+		{name: "wrong check value", kcvs: []byte{0x50, 0x4A, 0x78}, assertion: assert.Error},
+		{name: "missing check value", assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &putKeyCard{kcvs: tt.kcvs}
+			tt.assertion(t, NewClient(card).PutKey(cmd, newTestDEK()))
+			if assert.Len(t, card.commands, 1) {
+				assert.Equal(t, InstructionPutKey, card.commands[0].Instruction)
+			}
+		})
+	}
+}
@@ -91,3 +91,53 @@ func (s *SecureChannelSession) getRandR() io.Reader {
 	}
 	return s.randR
 }
+
+// SecurityLevel is the level of secure messaging applied to a secure channel session, as sent in P1 of EXTERNAL AUTHENTICATE
+type SecurityLevel byte
+
+const (
+	// SecurityLevelNone is no secure messaging after authentication
+	SecurityLevelNone SecurityLevel = 0x00
+	// SecurityLevelCMAC is C-MAC on all commands
+	SecurityLevelCMAC SecurityLevel = b1
+	// SecurityLevelCDecryption is C-DECRYPTION, which is only valid in combination with C-MAC
+	SecurityLevelCDecryption SecurityLevel = b2
+	// SecurityLevelRMAC is R-MAC on all responses
+	SecurityLevelRMAC SecurityLevel = b5
+	// SecurityLevelREncryption is R-ENCRYPTION, which is only valid in combination with R-MAC and C-DECRYPTION
+	SecurityLevelREncryption SecurityLevel = b6
+)
+
+// CMAC indicates whether C-MAC is required
+func (s SecurityLevel) CMAC() bool {
+	return s&SecurityLevelCMAC > 0
+}
+
+// CDecryption indicates whether C-DECRYPTION is required
+func (s SecurityLevel) CDecryption() bool {
+	return s&SecurityLevelCDecryption > 0
+}
+
+// RMAC indicates whether R-MAC is required
+func (s SecurityLevel) RMAC() bool {
+	return s&SecurityLevelRMAC > 0
+}
+
+// REncryption indicates whether R-ENCRYPTION is required
+func (s SecurityLevel) REncryption() bool {
+	return s&SecurityLevelREncryption > 0
+}
+
+// Validate checks the security level only combines flags in ways permitted by the spec
+func (s SecurityLevel) Validate() error {
+	if s&^(SecurityLevelCMAC|SecurityLevelCDecryption|SecurityLevelRMAC|SecurityLevelREncryption) != 0 {
+		return fmt.Errorf("security level %02X has reserved bits set", byte(s))
+	}
+	if s.CDecryption() && !s.CMAC() {
+		return fmt.Errorf("C-DECRYPTION requires C-MAC")
+	}
+	if s.REncryption() && (!s.RMAC() || !s.CDecryption()) {
+		return fmt.Errorf("R-ENCRYPTION requires R-MAC and C-DECRYPTION")
+	}
+	return nil
+}
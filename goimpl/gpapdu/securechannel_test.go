@@ -84,3 +84,42 @@ func TestSecureChannelSession_getRandR(t *testing.T) {
 		})
 	}
 }
+
+func TestSecurityLevel_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		s         SecurityLevel
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "none",
+			s:         SecurityLevelNone,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "full",
+			s:         SecurityLevelCMAC | SecurityLevelCDecryption | SecurityLevelRMAC | SecurityLevelREncryption,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "C-DECRYPTION without C-MAC",
+			s:         SecurityLevelCDecryption,
+			assertion: assert.Error,
+		},
+		{
+			name:      "R-ENCRYPTION without C-DECRYPTION",
+			s:         SecurityLevelCMAC | SecurityLevelRMAC | SecurityLevelREncryption,
+			assertion: assert.Error,
+		},
+		{
+			name:      "reserved bits",
+			s:         0x80,
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.assertion(t, tt.s.Validate())
+		})
+	}
+}
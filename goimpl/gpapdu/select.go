@@ -0,0 +1,52 @@
+package gpapdu
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+)
+
+const selectByName = b3
+
+// Select selects an application by AID on the basic logical channel, returning the File Control Information. The issuer security domain is selected when the AID is empty.
+func (c *Client) Select(aid []byte) ([]byte, error) {
+	if len(aid) > 16 {
+		return nil, fmt.Errorf("AID must be at most 16 bytes, got %d", len(aid))
+	}
+	res, err := c.sendChained(Command{
+		Class:              Class{},
+		Instruction:        apdu.InstructionSelect,
+		P1:                 selectByName,
+		P2:                 0x00,
+		Data:               aid,
+		ExpectResponseData: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("SELECT %X: %w", aid, err)
+	}
+	return res.Data, nil
+}
+
+// SecureChannel is an open secure channel session which can encipher sensitive data with its DEK, scp02.Session and scp03.Session both implement it
+type SecureChannel interface {
+	apdu.Transport
+	SensitiveDataEncryptor
+}
+
+// OpenSecureChannel opens a secure channel on the transport with the application or security domain, which has already been selected
+type OpenSecureChannel func(transport apdu.Transport, aid []byte) (SecureChannel, error)
+
+// SelectAndOpen selects the application or security domain and opens a secure channel with it
+func SelectAndOpen(transport apdu.Transport, aid []byte, open OpenSecureChannel) (SecureChannel, error) {
+	if transport == nil || open == nil {
+		return nil, fmt.Errorf("must supply a transport and a way to open the secure channel")
+	}
+	if _, err := NewClient(transport).Select(aid); err != nil {
+		return nil, err
+	}
+	session, err := open(transport, aid)
+	if err != nil {
+		return nil, fmt.Errorf("opening secure channel with %X: %w", aid, err)
+	}
+	return session, nil
+}
@@ -0,0 +1,157 @@
+package gpapdu
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	lifecycle "github.com/llkennedy/globalplatform/goimpl/lifcecycle"
+)
+
+const (
+	// InstructionSetStatus is the SetStatus instruction
+	InstructionSetStatus apdu.Instruction = 0xF0
+)
+
+const (
+	setStatusCard                         = 0x80
+	setStatusApplication                  = 0x40
+	setStatusSecurityDomainAndAssociation = 0x60
+	// setStatusLock and setStatusUnlock are the P2 values which lock and unlock an application or security domain, restoring its previous state on unlock
+	setStatusLock   = 0x80
+	setStatusUnlock = 0x00
+)
+
+// SetStatusCommand is one of the SET STATUS variants.
+// Each variant carries the current state of its target, so illegal transitions are rejected before anything is sent to the card.
+type SetStatusCommand interface {
+	// unimplementableSetStatusCommand returns P1, P2 and the data field
+	unimplementableSetStatusCommand() (p1, p2 byte, data []byte, err error)
+}
+
+// SetCardStatus changes the card life cycle state through the issuer security domain
+type SetCardStatus struct {
+	// AID of the issuer security domain, may be empty
+	AID     []byte
+	Current lifecycle.Card
+	Target  lifecycle.Card
+	// Requester is checked for the privileges the transition needs when set, only the transition itself is checked when nil
+	Requester *lifecycle.Requester
+}
+
+func (s SetCardStatus) unimplementableSetStatusCommand() (p1, p2 byte, data []byte, err error) {
+	if s.Requester != nil {
+		err = s.Current.CheckTransition(s.Target, *s.Requester)
+	} else if !s.Current.CanTransition(s.Target) {
+		err = fmt.Errorf("card life cycle cannot change from %s to %s", s.Current, s.Target)
+	}
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	return setStatusCard, byte(s.Target), s.AID, nil
+}
+
+// SetApplicationStatus locks, unlocks or changes the application specific life cycle state of an application
+type SetApplicationStatus struct {
+	AID     []byte
+	Current lifecycle.Application
+	// Target is ApplicationLocked to lock, the state before locking to unlock, or an application specific state
+	Target lifecycle.Application
+	// Requester is checked for the privileges the transition needs when set, only the transition itself is checked when nil
+	Requester *lifecycle.Requester
+}
+
+func (s SetApplicationStatus) unimplementableSetStatusCommand() (p1, p2 byte, data []byte, err error) {
+	if len(s.AID) == 0 {
+		return 0, 0, nil, fmt.Errorf("application AID is required")
+	}
+	if s.Requester != nil {
+		err = s.Current.CheckTransition(s.Target, *s.Requester)
+	} else if !s.Current.CanTransition(s.Target) {
+		err = fmt.Errorf("application life cycle cannot change from %s to %s", s.Current, s.Target)
+	}
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	switch {
+	case s.Target == lifecycle.ApplicationLocked:
+		p2 = setStatusLock
+	case s.Current.Locked():
+		p2 = setStatusUnlock
+	case s.Current == lifecycle.ApplicationInstalled:
+		return 0, 0, nil, fmt.Errorf("applications are made selectable with INSTALL [for make selectable], not SET STATUS")
+	default:
+		p2 = byte(s.Target)
+	}
+	return setStatusApplication, p2, s.AID, nil
+}
+
+// SetSecurityDomainStatus locks, unlocks or personalizes a security domain
+type SetSecurityDomainStatus struct {
+	AID     []byte
+	Current lifecycle.SecurityDomain
+	// Target is SecurityDomainLocked to lock, the state before locking to unlock, or SecurityDomainPersonalized
+	Target lifecycle.SecurityDomain
+	// WithAssociatedApplications locks or unlocks the applications associated with the security domain along with it
+	WithAssociatedApplications bool
+	// Requester is checked for the privileges the transition needs when set, only the transition itself is checked when nil
+	Requester *lifecycle.Requester
+}
+
+func (s SetSecurityDomainStatus) unimplementableSetStatusCommand() (p1, p2 byte, data []byte, err error) {
+	if len(s.AID) == 0 {
+		return 0, 0, nil, fmt.Errorf("security domain AID is required")
+	}
+	if s.Requester != nil {
+		err = s.Current.CheckTransition(s.Target, *s.Requester)
+	} else if !s.Current.CanTransition(s.Target) {
+		err = fmt.Errorf("security domain life cycle cannot change from %s to %s", s.Current, s.Target)
+	}
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	switch {
+	case s.Target == lifecycle.SecurityDomainLocked:
+		p2 = setStatusLock
+	case s.Current.Locked():
+		p2 = setStatusUnlock
+	case s.Current == lifecycle.SecurityDomainInstalled:
+		return 0, 0, nil, fmt.Errorf("security domains are made selectable with INSTALL [for make selectable], not SET STATUS")
+	case s.WithAssociatedApplications:
+		return 0, 0, nil, fmt.Errorf("security domain and associated applications can only be locked or unlocked")
+	default:
+		p2 = byte(s.Target)
+	}
+	p1 = setStatusApplication
+	if s.WithAssociatedApplications {
+		p1 = setStatusSecurityDomainAndAssociation
+	}
+	return p1, p2, s.AID, nil
+}
+
+// SetStatusCommandToCommand converts a SET STATUS variant to a command
+func SetStatusCommandToCommand(cmd SetStatusCommand) (Command, error) {
+	if cmd == nil {
+		return Command{}, fmt.Errorf("cannot convert nil SET STATUS command")
+	}
+	p1, p2, data, err := cmd.unimplementableSetStatusCommand()
+	if err != nil {
+		return Command{}, err
+	}
+	return Command{
+		Class:       Class{IsGPCommand: true},
+		Instruction: InstructionSetStatus,
+		P1:          p1,
+		P2:          p2,
+		Data:        data,
+	}, nil
+}
+
+// SetStatus changes the life cycle state of the card, an application or a security domain
+func (c *Client) SetStatus(cmd SetStatusCommand) error {
+	command, err := SetStatusCommandToCommand(cmd)
+	if err != nil {
+		return err
+	}
+	_, err = c.sendChained(command)
+	return err
+}
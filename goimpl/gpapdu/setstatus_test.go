@@ -0,0 +1,54 @@
+package gpapdu
+
+import (
+	"testing"
+
+	lifecycle "github.com/llkennedy/globalplatform/goimpl/lifcecycle"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetStatusCommandToCommand(t *testing.T) {
+	aid := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01}
+	tests := []struct {
+		name      string
+		cmd       SetStatusCommand
+		wantP1    byte
+		wantP2    byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "initialize card", cmd: SetCardStatus{Current: lifecycle.CardOPReady, Target: lifecycle.CardInitialized}, wantP1: 0x80, wantP2: 0x07, assertion: assert.NoError},
+		{name: "lock card", cmd: SetCardStatus{Current: lifecycle.CardSecured, Target: lifecycle.CardCardLocked}, wantP1: 0x80, wantP2: 0x7F, assertion: assert.NoError},
+		{name: "unlock card", cmd: SetCardStatus{Current: lifecycle.CardCardLocked, Target: lifecycle.CardSecured}, wantP1: 0x80, wantP2: 0x0F, assertion: assert.NoError},
+		{name: "terminate card", cmd: SetCardStatus{Current: lifecycle.CardCardLocked, Target: lifecycle.CardTerminated}, wantP1: 0x80, wantP2: 0xFF, assertion: assert.NoError},
+		{name: "lock initialized card", cmd: SetCardStatus{Current: lifecycle.CardInitialized, Target: lifecycle.CardCardLocked}, assertion: assert.Error},
+		{name: "revive terminated card", cmd: SetCardStatus{Current: lifecycle.CardTerminated, Target: lifecycle.CardSecured}, assertion: assert.Error},
+		{name: "lock application", cmd: SetApplicationStatus{AID: aid, Current: lifecycle.ApplicationSelectable, Target: lifecycle.ApplicationLocked}, wantP1: 0x40, wantP2: 0x80, assertion: assert.NoError},
+		{name: "unlock application", cmd: SetApplicationStatus{AID: aid, Current: 0x87, Target: lifecycle.ApplicationSelectable}, wantP1: 0x40, wantP2: 0x00, assertion: assert.NoError},
+		{name: "application specific state", cmd: SetApplicationStatus{AID: aid, Current: lifecycle.ApplicationSelectable, Target: 0x1F}, wantP1: 0x40, wantP2: 0x1F, assertion: assert.NoError},
+		{name: "unlock application to another state", cmd: SetApplicationStatus{AID: aid, Current: 0x87, Target: 0x0F}, assertion: assert.Error},
+		{name: "lock locked application", cmd: SetApplicationStatus{AID: aid, Current: 0x87, Target: lifecycle.ApplicationLocked}, assertion: assert.Error},
+		{name: "application back to installed", cmd: SetApplicationStatus{AID: aid, Current: lifecycle.ApplicationSelectable, Target: lifecycle.ApplicationInstalled}, assertion: assert.Error},
+		{name: "application without AID", cmd: SetApplicationStatus{Current: lifecycle.ApplicationSelectable, Target: lifecycle.ApplicationLocked}, assertion: assert.Error},
+		{name: "personalize security domain", cmd: SetSecurityDomainStatus{AID: aid, Current: lifecycle.SecurityDomainSelectable, Target: lifecycle.SecurityDomainPersonalized}, wantP1: 0x40, wantP2: 0x0F, assertion: assert.NoError},
+		{name: "lock security domain and applications", cmd: SetSecurityDomainStatus{AID: aid, Current: lifecycle.SecurityDomainPersonalized, Target: lifecycle.SecurityDomainLocked, WithAssociatedApplications: true}, wantP1: 0x60, wantP2: 0x80, assertion: assert.NoError},
+		{name: "unlock security domain", cmd: SetSecurityDomainStatus{AID: aid, Current: 0x8F, Target: lifecycle.SecurityDomainPersonalized}, wantP1: 0x40, wantP2: 0x00, assertion: assert.NoError},
+		{name: "personalize security domain and applications", cmd: SetSecurityDomainStatus{AID: aid, Current: lifecycle.SecurityDomainSelectable, Target: lifecycle.SecurityDomainPersonalized, WithAssociatedApplications: true}, assertion: assert.Error},
+		{name: "personalize installed security domain", cmd: SetSecurityDomainStatus{AID: aid, Current: lifecycle.SecurityDomainInstalled, Target: lifecycle.SecurityDomainPersonalized}, assertion: assert.Error},
+		{name: "application made selectable", cmd: SetApplicationStatus{AID: aid, Current: lifecycle.ApplicationInstalled, Target: lifecycle.ApplicationSelectable}, assertion: assert.Error},
+		{name: "lock card with privilege", cmd: SetCardStatus{Current: lifecycle.CardSecured, Target: lifecycle.CardCardLocked, Requester: &lifecycle.Requester{CardLock: true}}, wantP1: 0x80, wantP2: 0x7F, assertion: assert.NoError},
+		{name: "lock card without privilege", cmd: SetCardStatus{Current: lifecycle.CardSecured, Target: lifecycle.CardCardLocked, Requester: &lifecycle.Requester{IssuerSecurityDomain: true}}, assertion: assert.Error},
+		{name: "application unlocks itself", cmd: SetApplicationStatus{AID: aid, Current: 0x87, Target: lifecycle.ApplicationSelectable, Requester: &lifecycle.Requester{Self: true}}, assertion: assert.Error},
+		{name: "nil", assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SetStatusCommandToCommand(tt.cmd)
+			tt.assertion(t, err)
+			if err == nil {
+				assert.Equal(t, InstructionSetStatus, got.Instruction)
+				assert.Equal(t, tt.wantP1, got.P1)
+				assert.Equal(t, tt.wantP2, got.P2)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package gpapdu
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+)
+
+const (
+	// InstructionStoreData is the StoreData instruction
+	InstructionStoreData apdu.Instruction = 0xE2
+)
+
+// DefaultStoreDataBlockSize is the default maximum amount of data in each STORE DATA command, leaving room for a 16 byte C-MAC and C-DECRYPTION padding within 255 bytes
+const DefaultStoreDataBlockSize = 223
+
+// StoreDataEncryption is the encryption indicator of STORE DATA, P1 b7-b6
+type StoreDataEncryption byte
+
+const (
+	// StoreDataNoEncryptionInformation indicates nothing about the encryption of the data
+	StoreDataNoEncryptionInformation StoreDataEncryption = 0x00
+	// StoreDataApplicationDependentEncryption indicates encryption the application knows about
+	StoreDataApplicationDependentEncryption StoreDataEncryption = b6
+	// StoreDataEncrypted indicates the data is enciphered with the DEK
+	StoreDataEncrypted StoreDataEncryption = b7 | b6
+)
+
+// StoreDataFormat is the data structure of STORE DATA, P1 b5-b4
+type StoreDataFormat byte
+
+const (
+	// StoreDataNoFormatInformation indicates nothing about the structure of the data
+	StoreDataNoFormatInformation StoreDataFormat = 0x00
+	// StoreDataDGIFormat indicates the data is a sequence of DGIs
+	StoreDataDGIFormat StoreDataFormat = b4
+	// StoreDataBERTLVFormat indicates the data is a sequence of BER-TLV objects
+	StoreDataBERTLVFormat StoreDataFormat = b5
+)
+
+const (
+	storeDataLastBlock            = b8
+	storeDataResponseDataExpected = b1
+)
+
+// StoreDataCommand is a STORE DATA sequence.
+// The records, such as whole DGIs or BER-TLV objects, are packed into as few blocks as possible without splitting any record which fits in a block.
+// A record longer than a block is split across as many blocks as it needs.
+type StoreDataCommand struct {
+	Records    [][]byte
+	Format     StoreDataFormat
+	Encryption StoreDataEncryption
+	// ResponseDataExpected asks the application for response data to every block
+	ResponseDataExpected bool
+	// BlockSize is the maximum amount of data in each STORE DATA command, DefaultStoreDataBlockSize is used when zero.
+	// It must be small enough for the card's buffer once the secure channel has added its MAC and padding.
+	BlockSize int
+	// FirstBlockNumber continues the numbering of an earlier sequence sent with MoreFollows
+	FirstBlockNumber byte
+	// MoreFollows leaves the last block flag clear, so the personalization continues in a later sequence
+	MoreFollows bool
+}
+
+// Commands packs the records into numbered blocks, P2 is the block number modulo 256 and P1 b8 marks the last block
+func (s StoreDataCommand) Commands() ([]Command, error) {
+	switch s.Format {
+	case StoreDataNoFormatInformation, StoreDataDGIFormat, StoreDataBERTLVFormat:
+	default:
+		return nil, fmt.Errorf("invalid STORE DATA format %02X", byte(s.Format))
+	}
+	switch s.Encryption {
+	case StoreDataNoEncryptionInformation, StoreDataApplicationDependentEncryption, StoreDataEncrypted:
+	default:
+		return nil, fmt.Errorf("invalid STORE DATA encryption indicator %02X", byte(s.Encryption))
+	}
+	blockSize := s.BlockSize
+	if blockSize == 0 {
+		blockSize = DefaultStoreDataBlockSize
+	}
+	if blockSize < 1 || blockSize > 255 {
+		return nil, fmt.Errorf("block size must be between 1 and 255, got %d", blockSize)
+	}
+	var blocks [][]byte
+	var current []byte
+	for i, record := range s.Records {
+		if len(record) == 0 {
+			return nil, fmt.Errorf("record %d is empty", i)
+		}
+		if len(current)+len(record) > blockSize && len(current) > 0 {
+			blocks = append(blocks, current)
+			current = nil
+		}
+		for len(record) > blockSize {
+			blocks = append(blocks, record[:blockSize])
+			record = record[blockSize:]
+		}
+		current = append(current, record...)
+	}
+	if len(current) > 0 {
+		blocks = append(blocks, current)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("no data to store")
+	}
+	p1 := byte(s.Encryption) | byte(s.Format)
+	if s.ResponseDataExpected {
+		p1 |= storeDataResponseDataExpected
+	}
+	commands := make([]Command, 0, len(blocks))
+	for i, block := range blocks {
+		cmd := Command{
+			Class:              Class{IsGPCommand: true},
+			Instruction:        InstructionStoreData,
+			P1:                 p1,
+			P2:                 byte((int(s.FirstBlockNumber) + i) % 256),
+			Data:               block,
+			ExpectResponseData: s.ResponseDataExpected,
+		}
+		if i == len(blocks)-1 && !s.MoreFollows {
+			cmd.P1 |= storeDataLastBlock
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, nil
+}
+
+// StoreData sends the STORE DATA sequence, returning the response data to each block
+func (c *Client) StoreData(cmd StoreDataCommand) ([][]byte, error) {
+	commands, err := cmd.Commands()
+	if err != nil {
+		return nil, err
+	}
+	responses := make([][]byte, 0, len(commands))
+	for i, next := range commands {
+		res, err := c.sendChained(next)
+		if err != nil {
+			return nil, fmt.Errorf("storing block %d: %w", i, err)
+		}
+		responses = append(responses, res.Data)
+	}
+	return responses, nil
+}
@@ -0,0 +1,99 @@
+package gpapdu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreDataCommand_Commands(t *testing.T) {
+	small := bytes.Repeat([]byte{0x01}, 100)
+	large := bytes.Repeat([]byte{0x02}, 300)
+	type block struct {
+		p1, p2 byte
+		length int
+	}
+	tests := []struct {
+		name      string
+		cmd       StoreDataCommand
+		want      []block
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "records packed",
+			cmd:       StoreDataCommand{Records: [][]byte{small, small, small}, Format: StoreDataDGIFormat},
+			want:      []block{{0x08, 0x00, 200}, {0x88, 0x01, 100}},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "large record split",
+			cmd:       StoreDataCommand{Records: [][]byte{small, large, small}, Format: StoreDataBERTLVFormat, Encryption: StoreDataEncrypted},
+			want:      []block{{0x70, 0x00, 100}, {0x70, 0x01, 223}, {0xF0, 0x02, 177}},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "continued sequence with response data",
+			cmd:       StoreDataCommand{Records: [][]byte{small}, BlockSize: 64, FirstBlockNumber: 5, MoreFollows: true, ResponseDataExpected: true, Encryption: StoreDataApplicationDependentEncryption},
+			want:      []block{{0x21, 0x05, 64}, {0x21, 0x06, 36}},
+			assertion: assert.NoError,
+		},
+		{name: "no data", cmd: StoreDataCommand{}, assertion: assert.Error},
+		{name: "empty record", cmd: StoreDataCommand{Records: [][]byte{small, {}}}, assertion: assert.Error},
+		{name: "invalid format", cmd: StoreDataCommand{Records: [][]byte{small}, Format: 0x18}, assertion: assert.Error},
+		{name: "invalid encryption", cmd: StoreDataCommand{Records: [][]byte{small}, Encryption: 0x40}, assertion: assert.Error},
+		{name: "block size too large", cmd: StoreDataCommand{Records: [][]byte{small}, BlockSize: 256}, assertion: assert.Error},
+		{
+			name:      "block number wraps",
+			cmd:       StoreDataCommand{Records: [][]byte{small}, FirstBlockNumber: 0xFF, BlockSize: 50},
+			want:      []block{{0x00, 0xFF, 50}, {0x80, 0x00, 50}},
+			assertion: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cmd.Commands()
+			tt.assertion(t, err)
+			if err != nil {
+				return
+			}
+			if assert.Len(t, got, len(tt.want)) {
+				for i, want := range tt.want {
+					assert.Equal(t, InstructionStoreData, got[i].Instruction)
+					assert.Equal(t, want.p1, got[i].P1, "block %d", i)
+					assert.Equal(t, want.p2, got[i].P2, "block %d", i)
+					assert.Len(t, got[i].Data, want.length, "block %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreDataCommand_Commands_manyBlocks(t *testing.T) {
+	records := make([][]byte, 300)
+	for i := range records {
+		records[i] = bytes.Repeat([]byte{byte(i)}, 200)
+	}
+	got, err := StoreDataCommand{Records: records}.Commands()
+	assert.NoError(t, err)
+	if assert.Len(t, got, 300) {
+		for i, cmd := range got {
+			assert.Equal(t, byte(i%256), cmd.P2)
+			if i == len(got)-1 {
+				assert.Equal(t, byte(0x80), cmd.P1)
+			} else {
+				assert.Equal(t, byte(0x00), cmd.P1, "block %d", i)
+			}
+		}
+	}
+}
+
+func TestClient_StoreData(t *testing.T) {
+	card := &fixedCard{data: []byte{0xAA}}
+	responses, err := NewClient(card).StoreData(StoreDataCommand{Records: [][]byte{{0x01, 0x01, 0x01, 0x00}}, Format: StoreDataDGIFormat, ResponseDataExpected: true})
+	assert.NoError(t, err)
+	assert.Equal(t, [][]byte{{0xAA}}, responses)
+	if assert.Len(t, card.commands, 1) {
+		assert.Equal(t, byte(0x89), card.commands[0].P1)
+	}
+}
@@ -0,0 +1,156 @@
+package gpapdu
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+)
+
+const (
+	tagToken = 0x9E
+)
+
+// TokenKey is the key a card issuer uses to authorise delegated management operations
+type TokenKey struct {
+	// Signer is an RSA or ECDSA private key, RSA tokens are PKCS#1 v1.5 signatures and ECDSA tokens are the raw r||s signature
+	Signer crypto.Signer
+	// Hash is the digest of the token data which is signed, SHA-256 is used when zero
+	Hash crypto.Hash
+}
+
+// TokenData builds the data a token signs: P1, P2, the one byte length of the data field and the data field without the token
+func TokenData(p1, p2 byte, data []byte) ([]byte, error) {
+	if len(data) > 255 {
+		return nil, fmt.Errorf("token data field must be at most 255 bytes, got %d", len(data))
+	}
+	out := append([]byte{p1, p2, byte(len(data))}, data...)
+	return out, nil
+}
+
+func (k TokenKey) hash() crypto.Hash {
+	if k.Hash == 0 {
+		return crypto.SHA256
+	}
+	return k.Hash
+}
+
+// SignToken signs the token data of a command
+func (k TokenKey) SignToken(randR io.Reader, p1, p2 byte, data []byte) ([]byte, error) {
+	if k.Signer == nil {
+		return nil, fmt.Errorf("must supply a token key")
+	}
+	message, err := TokenData(p1, p2, data)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := signMessage(k.Signer, k.hash(), randR, message)
+	if err != nil {
+		return nil, fmt.Errorf("signing token: %w", err)
+	}
+	return sig, nil
+}
+
+// tokenLength returns the length of the tokens the key signs
+func (k TokenKey) tokenLength() (int, error) {
+	if k.Signer == nil {
+		return 0, fmt.Errorf("must supply a token key")
+	}
+	switch pub := k.Signer.Public().(type) {
+	case *rsa.PublicKey:
+		return pub.Size(), nil
+	case *ecdsa.PublicKey:
+		return 2 * ((pub.Curve.Params().BitSize + 7) / 8), nil
+	default:
+		return 0, fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// signMessage hashes and signs a message, RSA signatures are PKCS#1 v1.5 and ECDSA signatures are the fixed length r||s
+func signMessage(signer crypto.Signer, hash crypto.Hash, randR io.Reader, message []byte) ([]byte, error) {
+	if !hash.Available() {
+		return nil, fmt.Errorf("hash function %d is not available", hash)
+	}
+	h := hash.New()
+	h.Write(message)
+	digest := h.Sum(nil)
+	sig, err := signer.Sign(randR, digest, hash)
+	if err != nil {
+		return nil, err
+	}
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		return sig, nil
+	case *ecdsa.PublicKey:
+		// crypto.Signer produces ASN.1 ECDSA signatures, cards expect the fixed length r||s
+		var parsed struct{ R, S *big.Int }
+		if _, err = asn1.Unmarshal(sig, &parsed); err != nil {
+			return nil, fmt.Errorf("decoding ECDSA signature: %w", err)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		raw := make([]byte, 2*size)
+		parsed.R.FillBytes(raw[:size])
+		parsed.S.FillBytes(raw[size:])
+		return raw, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// VerifyToken checks a token over a command with the public half of the token key, as the card does
+func VerifyToken(pub crypto.PublicKey, hash crypto.Hash, p1, p2 byte, data, token []byte) error {
+	message, err := TokenData(p1, p2, data)
+	if err != nil {
+		return err
+	}
+	if err = verifyMessage(pub, hash, message, token); err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+	return nil
+}
+
+// verifyMessage checks a signature made by signMessage, SHA-256 is used when the hash is zero
+func verifyMessage(pub crypto.PublicKey, hash crypto.Hash, message, sig []byte) error {
+	if hash == 0 {
+		hash = crypto.SHA256
+	}
+	if !hash.Available() {
+		return fmt.Errorf("hash function %d is not available", hash)
+	}
+	h := hash.New()
+	h.Write(message)
+	digest := h.Sum(nil)
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, hash, digest, sig)
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return fmt.Errorf("signature must be %d bytes, got %d", 2*size, len(sig))
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("signature does not match")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// appendToken appends the token as a 9E object, an empty token is not sent at all
+func appendToken(data, token []byte) ([]byte, error) {
+	if len(token) == 0 {
+		return data, nil
+	}
+	obj, err := bertlv.Object{Tag: bertlv.TagFromUintForced(tagToken), Value: token}.ToBytes()
+	if err != nil {
+		return nil, fmt.Errorf("encoding token: %w", err)
+	}
+	return append(append([]byte{}, data...), obj...), nil
+}
@@ -0,0 +1,107 @@
+package gpapdu
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenData(t *testing.T) {
+	got, err := TokenData(0x00, 0x80, []byte{0xD0, 0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x00, 0x80, 0x02, 0xD0, 0x01}, got)
+	data := make([]byte, 200)
+	got, err = TokenData(0x01, 0x02, data)
+	assert.NoError(t, err)
+	assert.Equal(t, append([]byte{0x01, 0x02, 0xC8}, data...), got)
+	_, err = TokenData(0x00, 0x00, make([]byte, 256))
+	assert.Error(t, err)
+}
+
+func TestTokenKey_SignToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	data := []byte{0x4F, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51}
+	tests := []struct {
+		name      string
+		key       TokenKey
+		public    crypto.PublicKey
+		wantLen   int
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "RSA SHA-1", key: TokenKey{Signer: rsaKey, Hash: crypto.SHA1}, public: &rsaKey.PublicKey, wantLen: 128, assertion: assert.NoError},
+		{name: "RSA default hash", key: TokenKey{Signer: rsaKey}, public: &rsaKey.PublicKey, wantLen: 128, assertion: assert.NoError},
+		{name: "ECDSA P-256", key: TokenKey{Signer: ecKey}, public: &ecKey.PublicKey, wantLen: 64, assertion: assert.NoError},
+		{name: "no key", assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.key.SignToken(rand.Reader, 0x00, 0x80, data)
+			tt.assertion(t, err)
+			if err != nil {
+				return
+			}
+			assert.Len(t, token, tt.wantLen)
+			assert.NoError(t, VerifyToken(tt.public, tt.key.Hash, 0x00, 0x80, data, token))
+			// The token binds P1 and P2 as well as the data
+			assert.Error(t, VerifyToken(tt.public, tt.key.Hash, 0x00, 0x00, data, token))
+			assert.Error(t, VerifyToken(tt.public, tt.key.Hash, 0x00, 0x80, data[1:], token))
+		})
+	}
+}
+
+func TestDeleteCardContent_Sign(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	key := TokenKey{Signer: ecKey}
+	d := DeleteCardContent{ELFileOrAppID: []byte{0xA0, 0x00, 0x00, 0x01, 0x51}}
+	unsigned, _ := d.unimplementableDeleteCommandToBytes()
+	assert.NoError(t, d.Sign(key, rand.Reader, true))
+	signed, err := d.unimplementableDeleteCommandToBytes()
+	assert.NoError(t, err)
+	tokenObj, _ := bertlv.Object{Tag: bertlv.TagFromUintForced(tagToken), Value: d.Token}.ToBytes()
+	assert.Equal(t, append(append([]byte{}, unsigned...), tokenObj...), signed)
+	assert.NoError(t, VerifyToken(&ecKey.PublicKey, 0, 0x00, 0x80, unsigned, d.Token))
+	t.Run("chained", func(t *testing.T) {
+		rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+		d := DeleteCardContent{ELFileOrAppID: []byte{0xA0, 0x00, 0x00, 0x01, 0x51}, CRTFDS: &ControlReferenceTemplateForDigitalSignature{TokenID: make([]byte, 150)}}
+		unsigned, _ := d.unimplementableDeleteCommandToBytes()
+		assert.NoError(t, d.Sign(TokenKey{Signer: rsaKey}, rand.Reader, false))
+		card := &fixedCard{}
+		_, err := NewClient(card).Delete(false, &d, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, card.commands, 2) {
+			assert.Equal(t, byte(0x80), card.commands[0].P1)
+		}
+		assert.NoError(t, VerifyToken(&rsaKey.PublicKey, 0, 0x80, 0x00, unsigned, d.Token))
+		assert.Error(t, VerifyToken(&rsaKey.PublicKey, 0, 0x00, 0x00, unsigned, d.Token))
+	})
+}
+
+func TestPutKeyCommand_Sign(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	dek := newTestDEK()
+	cmd := PutKeyCommand{KeyVersionNumber: 0x31, KeyIdentifier: 0x01, Keys: []PutKeyKey{{Components: []KeyComponent{{Type: AES, Value: defaultKey}}}}}
+	unsigned, err := cmd.Commands(dek)
+	assert.NoError(t, err)
+	assert.NoError(t, cmd.Sign(TokenKey{Signer: ecKey}, rand.Reader, dek))
+	signed, err := cmd.Commands(dek)
+	assert.NoError(t, err)
+	assert.Equal(t, unsigned[0].Command.Data, signed[0].Command.Data[:len(unsigned[0].Command.Data)])
+	assert.NoError(t, VerifyToken(&ecKey.PublicKey, 0, unsigned[0].Command.P1, unsigned[0].Command.P2, unsigned[0].Command.Data, cmd.Token))
+	t.Run("too many keys for one command", func(t *testing.T) {
+		many := cmd
+		many.Token = nil
+		for len(many.Keys) < 12 {
+			many.Keys = append(many.Keys, cmd.Keys[0])
+		}
+		assert.Error(t, many.Sign(TokenKey{Signer: ecKey}, rand.Reader, dek))
+	})
+}
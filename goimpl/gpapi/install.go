@@ -0,0 +1,87 @@
+package gpapi
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/capfile"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+)
+
+// InstallOptions configures InstallCAP
+type InstallOptions struct {
+	// SecurityDomainAID is the security domain to load into, empty for the one the client is connected to
+	SecurityDomainAID []byte
+	// AppletAID selects the applet to install from the package, and may be empty if the package has exactly one applet
+	AppletAID []byte
+	// ApplicationAID is the AID of the new application instance, the applet AID is used when empty
+	ApplicationAID []byte
+	Privileges     gpapdu.Privileges
+	Parameters     gpapdu.InstallParameters
+	// LoadOnly loads the package without installing any applet
+	LoadOnly bool
+	Load     capfile.LoadOptions
+	// BlockSize and Progress are passed on to the LOAD sequence
+	BlockSize int
+	Progress  func(sent, total int)
+}
+
+// InstallCAP loads a CAP file and installs and makes selectable one of its applets, in one call
+func InstallCAP(client *gpapdu.Client, capFile *capfile.CAP, opts InstallOptions) error {
+	if client == nil || capFile == nil {
+		return fmt.Errorf("must supply a client and CAP file")
+	}
+	var applet []byte
+	if !opts.LoadOnly {
+		var err error
+		if applet, err = selectApplet(capFile, opts.AppletAID); err != nil {
+			return err
+		}
+	}
+	if _, err := client.Install(gpapdu.InstallForLoad{LoadFileAID: capFile.Package.AID, SecurityDomainAID: opts.SecurityDomainAID}); err != nil {
+		return fmt.Errorf("INSTALL [for load]: %w", err)
+	}
+	_, err := client.Load(gpapdu.LoadCommand{
+		LoadFileDataBlock: capFile.LoadFileDataBlock(opts.Load),
+		BlockSize:         opts.BlockSize,
+		Progress:          opts.Progress,
+	})
+	if err != nil {
+		return fmt.Errorf("LOAD: %w", err)
+	}
+	if opts.LoadOnly {
+		return nil
+	}
+	application := opts.ApplicationAID
+	if len(application) == 0 {
+		application = applet
+	}
+	_, err = client.Install(gpapdu.InstallForInstall{
+		ExecutableLoadFileAID: capFile.Package.AID,
+		ExecutableModuleAID:   applet,
+		ApplicationAID:        application,
+		Privileges:            opts.Privileges,
+		Parameters:            opts.Parameters,
+		MakeSelectable:        true,
+	})
+	if err != nil {
+		return fmt.Errorf("INSTALL [for install and make selectable]: %w", err)
+	}
+	return nil
+}
+
+// selectApplet finds the applet to install
+func selectApplet(capFile *capfile.CAP, aid []byte) ([]byte, error) {
+	if len(aid) == 0 {
+		if len(capFile.Applets) != 1 {
+			return nil, fmt.Errorf("package has %d applets, the applet AID must be chosen", len(capFile.Applets))
+		}
+		return capFile.Applets[0].AID, nil
+	}
+	for _, applet := range capFile.Applets {
+		if bytes.Equal(applet.AID, aid) {
+			return applet.AID, nil
+		}
+	}
+	return nil, fmt.Errorf("package has no applet %X", aid)
+}
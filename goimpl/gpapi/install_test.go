@@ -0,0 +1,110 @@
+package gpapi
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/capfile"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCAP builds a minimal CAP file with the applets
+func testCAP(t *testing.T, applets ...[]byte) *capfile.CAP {
+	pkg := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01}
+	appletInfo := []byte{byte(len(applets))}
+	for _, aid := range applets {
+		appletInfo = append(append(append(appletInfo, byte(len(aid))), aid...), 0x00, 0x01)
+	}
+	components := map[string][]byte{
+		"Header.cap":       append([]byte{0xDE, 0xCA, 0xFF, 0xED, 0x01, 0x02, 0x04, 0x00, 0x01, byte(len(pkg))}, pkg...),
+		"Directory.cap":    {0x00},
+		"Import.cap":       {0x00},
+		"Applet.cap":       appletInfo,
+		"Class.cap":        {0x00},
+		"Method.cap":       {0x00},
+		"StaticField.cap":  {0x00},
+		"ConstantPool.cap": {0x00},
+		"RefLocation.cap":  {0x00},
+	}
+	tags := map[string]capfile.ComponentTag{"Header.cap": 1, "Directory.cap": 2, "Applet.cap": 3, "Import.cap": 4, "ConstantPool.cap": 5, "Class.cap": 6, "Method.cap": 7, "StaticField.cap": 8, "RefLocation.cap": 9}
+	buf := bytes.NewBuffer(nil)
+	w := zip.NewWriter(buf)
+	for name, info := range components {
+		f, _ := w.Create("pkg/javacard/" + name)
+		f.Write(append([]byte{byte(tags[name]), 0x00, byte(len(info))}, info...))
+	}
+	w.Close()
+	c, err := capfile.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// recordingCard accepts every command
+type recordingCard struct {
+	commands []apdu.Command
+}
+
+func (c *recordingCard) Send(cmd apdu.Command) (apdu.Response, error) {
+	c.commands = append(c.commands, cmd)
+	return apdu.Response{Data: []byte{0x00}, Status: apdu.RawStatus{SW1: 0x90}.Identify()}, nil
+}
+
+func TestInstallCAP(t *testing.T) {
+	first := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x01}
+	second := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x02}
+	tests := []struct {
+		name             string
+		cap              *capfile.CAP
+		opts             InstallOptions
+		wantInstructions []apdu.Instruction
+		assertion        assert.ErrorAssertionFunc
+	}{
+		{
+			name:             "single applet",
+			cap:              testCAP(t, first),
+			wantInstructions: []apdu.Instruction{gpapdu.InstructionInstall, gpapdu.InstructionLoad, gpapdu.InstructionInstall},
+			assertion:        assert.NoError,
+		},
+		{
+			name:             "chosen applet",
+			cap:              testCAP(t, first, second),
+			opts:             InstallOptions{AppletAID: second},
+			wantInstructions: []apdu.Instruction{gpapdu.InstructionInstall, gpapdu.InstructionLoad, gpapdu.InstructionInstall},
+			assertion:        assert.NoError,
+		},
+		{
+			name:             "load only",
+			cap:              testCAP(t),
+			opts:             InstallOptions{LoadOnly: true},
+			wantInstructions: []apdu.Instruction{gpapdu.InstructionInstall, gpapdu.InstructionLoad},
+			assertion:        assert.NoError,
+		},
+		{
+			name:      "ambiguous applet",
+			cap:       testCAP(t, first, second),
+			assertion: assert.Error,
+		},
+		{
+			name:      "unknown applet",
+			cap:       testCAP(t, first),
+			opts:      InstallOptions{AppletAID: second},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &recordingCard{}
+			tt.assertion(t, InstallCAP(gpapdu.NewClient(card), tt.cap, tt.opts))
+			var got []apdu.Instruction
+			for _, cmd := range card.commands {
+				got = append(got, cmd.Instruction)
+			}
+			assert.Equal(t, tt.wantInstructions, got)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package gpapi
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/llkennedy/globalplatform/goimpl/keydiv"
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+// Key identifiers of the static keys within a key set
+const (
+	keyIdentifierENC = 0x01
+	keyIdentifierMAC = 0x02
+	keyIdentifierDEK = 0x03
+)
+
+// KeySetConfig names the static keys of one key version number in a key store
+type KeySetConfig struct {
+	KeyVersionNumber byte
+	ENC, MAC, DEK    string // Labels of the keys in the key store
+	// Diversification derives each card's keys from the labelled master keys, the labelled keys are the card's own keys when nil
+	Diversification keydiv.Method
+}
+
+// KeySetSelector picks the key set to open a secure channel with, from the configured key sets which the card also has
+type KeySetSelector struct {
+	Store keystore.Store
+	// KeySets are tried in order, so the preferred key set comes first
+	KeySets []KeySetConfig
+}
+
+// SelectedKeySet is the key set chosen by a KeySetSelector
+type SelectedKeySet struct {
+	KeyVersionNumber byte
+	// Keys are the card's static keys, already diversified, and convert directly to the Keys of the secure channel protocol.
+	// Open the secure channel with them without a diversification method.
+	Keys keydiv.Keys
+}
+
+// ReadKeyInformation reads the key information template of the selected security domain
+func ReadKeyInformation(client *gpapdu.Client) ([]gpapdu.KeyInformation, error) {
+	if client == nil {
+		return nil, fmt.Errorf("must supply a client")
+	}
+	obj, err := client.GetData(gpapdu.GetDataCommand{Tag: gpapdu.DataKeyInformationTemplate})
+	if err != nil {
+		return nil, fmt.Errorf("GET DATA key information template: %w", err)
+	}
+	template, ok := obj.Parsed.([]gpapdu.KeyInformation)
+	if !ok {
+		return nil, fmt.Errorf("key information template parsed as %T", obj.Parsed)
+	}
+	return template, nil
+}
+
+// Select returns the first configured key set which the card holds as ENC, MAC and DEK keys for the secure channel protocol, with matching key types and lengths.
+// SCP02 uses triple DES keys and SCP03 uses AES keys.
+// Key sets with a diversification method are diversified for the card with the key diversification data from its INITIALIZE UPDATE response.
+func (s KeySetSelector) Select(template []gpapdu.KeyInformation, scp byte, kdd [10]byte) (SelectedKeySet, error) {
+	if s.Store == nil {
+		return SelectedKeySet{}, fmt.Errorf("no key store configured")
+	}
+	var keyType gpapdu.KeyType
+	var algorithm keystore.Algorithm
+	switch scp {
+	case 0x02:
+		keyType, algorithm = gpapdu.DESWithImplicitMode, keystore.AlgorithmTripleDES
+	case 0x03:
+		keyType, algorithm = gpapdu.AES, keystore.AlgorithmAES
+	default:
+		return SelectedKeySet{}, fmt.Errorf("key set selection is not supported for SCP%02X", scp)
+	}
+	var reasons []error
+	for _, config := range s.KeySets {
+		selected, err := s.load(template, config, keyType, algorithm, kdd)
+		if err == nil {
+			return selected, nil
+		}
+		reasons = append(reasons, err)
+	}
+	if len(reasons) == 0 {
+		return SelectedKeySet{}, fmt.Errorf("no key sets configured")
+	}
+	return SelectedKeySet{}, fmt.Errorf("no configured key set matches the card for SCP%02X: %v", scp, reasons)
+}
+
+// load checks the card has every key of the configured key set, loads the keys from the store and diversifies them for the card
+func (s KeySetSelector) load(template []gpapdu.KeyInformation, config KeySetConfig, keyType gpapdu.KeyType, algorithm keystore.Algorithm, kdd [10]byte) (SelectedKeySet, error) {
+	out := SelectedKeySet{KeyVersionNumber: config.KeyVersionNumber}
+	keys := []struct {
+		id    byte
+		label string
+		dest  *keystore.Key
+	}{
+		{keyIdentifierENC, config.ENC, &out.Keys.ENC},
+		{keyIdentifierMAC, config.MAC, &out.Keys.MAC},
+		{keyIdentifierDEK, config.DEK, &out.Keys.DEK},
+	}
+	var lengths [3]uint16
+	for i, key := range keys {
+		info, ok := findKeyInformation(template, config.KeyVersionNumber, key.id)
+		if !ok {
+			return SelectedKeySet{}, fmt.Errorf("card has no key %02X in version %02X", key.id, config.KeyVersionNumber)
+		}
+		if len(info.Components) != 1 || info.Components[0].Type != keyType {
+			return SelectedKeySet{}, fmt.Errorf("key %02X in version %02X is not a single %02X component", key.id, config.KeyVersionNumber, byte(keyType))
+		}
+		lengths[i] = info.Components[0].Length
+		loaded, err := s.Store.Key(key.label)
+		if err != nil {
+			return SelectedKeySet{}, fmt.Errorf("key %02X in version %02X: %w", key.id, config.KeyVersionNumber, err)
+		}
+		*key.dest = loaded
+	}
+	if config.Diversification != nil {
+		diversified, err := keydiv.Diversify(config.Diversification, out.Keys, kdd)
+		if err != nil {
+			return SelectedKeySet{}, fmt.Errorf("key version %02X: %w", config.KeyVersionNumber, err)
+		}
+		out.Keys = diversified
+	}
+	for i, key := range keys {
+		if got := *key.dest; got.Algorithm() != algorithm || got.Length() != int(lengths[i]) {
+			return SelectedKeySet{}, fmt.Errorf("key %q gives a %d byte %s key, card has %d byte key %02X in version %02X", key.label, got.Length(), got.Algorithm(), lengths[i], key.id, config.KeyVersionNumber)
+		}
+	}
+	return out, nil
+}
+
+func findKeyInformation(template []gpapdu.KeyInformation, kvn, id byte) (gpapdu.KeyInformation, bool) {
+	for _, info := range template {
+		if info.KeyVersionNumber == kvn && info.KeyIdentifier == id {
+			return info, true
+		}
+	}
+	return gpapdu.KeyInformation{}, false
+}
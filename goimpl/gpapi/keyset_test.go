@@ -0,0 +1,120 @@
+package gpapi
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/llkennedy/globalplatform/goimpl/keydiv"
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+	"github.com/llkennedy/globalplatform/goimpl/scp03"
+	"github.com/stretchr/testify/assert"
+)
+
+func keySetInformation(kvn byte, keyType gpapdu.KeyType, length uint16) []gpapdu.KeyInformation {
+	var out []gpapdu.KeyInformation
+	for id := byte(1); id <= 3; id++ {
+		out = append(out, gpapdu.KeyInformation{KeyIdentifier: id, KeyVersionNumber: kvn, Components: []gpapdu.KeyComponentInformation{{Type: keyType, Length: length}}})
+	}
+	return out
+}
+
+func TestKeySetSelector_Select(t *testing.T) {
+	store := keystore.NewMemoryStore()
+	for _, label := range []string{"aes-enc", "aes-mac", "aes-dek"} {
+		_, err := store.Import(label, keystore.AlgorithmAES, bytes.Repeat([]byte{0x40}, 16))
+		assert.NoError(t, err)
+	}
+	for _, label := range []string{"des-enc", "des-mac", "des-dek"} {
+		_, err := store.Import(label, keystore.AlgorithmTripleDES, bytes.Repeat([]byte{0x40}, 16))
+		assert.NoError(t, err)
+	}
+	aes := KeySetConfig{KeyVersionNumber: 0x30, ENC: "aes-enc", MAC: "aes-mac", DEK: "aes-dek"}
+	des := KeySetConfig{KeyVersionNumber: 0x20, ENC: "des-enc", MAC: "des-mac", DEK: "des-dek"}
+	card := append(keySetInformation(0x20, gpapdu.DESWithImplicitMode, 16), keySetInformation(0x30, gpapdu.AES, 16)...)
+	tests := []struct {
+		name      string
+		selector  KeySetSelector
+		template  []gpapdu.KeyInformation
+		scp       byte
+		wantKVN   byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "SCP03", selector: KeySetSelector{Store: store, KeySets: []KeySetConfig{des, aes}}, template: card, scp: 0x03, wantKVN: 0x30, assertion: assert.NoError},
+		{name: "SCP02", selector: KeySetSelector{Store: store, KeySets: []KeySetConfig{aes, des}}, template: card, scp: 0x02, wantKVN: 0x20, assertion: assert.NoError},
+		{name: "length mismatch", selector: KeySetSelector{Store: store, KeySets: []KeySetConfig{aes}}, template: keySetInformation(0x30, gpapdu.AES, 32), scp: 0x03, assertion: assert.Error},
+		{name: "not on card", selector: KeySetSelector{Store: store, KeySets: []KeySetConfig{aes}}, template: keySetInformation(0x31, gpapdu.AES, 16), scp: 0x03, assertion: assert.Error},
+		{name: "missing label", selector: KeySetSelector{Store: store, KeySets: []KeySetConfig{{KeyVersionNumber: 0x30, ENC: "aes-enc", MAC: "aes-mac", DEK: "nope"}}}, template: card, scp: 0x03, assertion: assert.Error},
+		{name: "no key sets", selector: KeySetSelector{Store: store}, template: card, scp: 0x03, assertion: assert.Error},
+		{name: "unsupported SCP", selector: KeySetSelector{Store: store, KeySets: []KeySetConfig{aes}}, template: card, scp: 0x11, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.selector.Select(tt.template, tt.scp, [10]byte{})
+			tt.assertion(t, err)
+			if err == nil {
+				assert.Equal(t, tt.wantKVN, got.KeyVersionNumber)
+				assert.NotNil(t, got.Keys.ENC)
+				assert.NotNil(t, got.Keys.MAC)
+				assert.NotNil(t, got.Keys.DEK)
+			}
+		})
+	}
+}
+
+func TestKeySetSelector_Select_diversification(t *testing.T) {
+	store := keystore.NewMemoryStore()
+	var master keydiv.Keys
+	for i, dest := range []*keystore.Key{&master.ENC, &master.MAC, &master.DEK} {
+		var err error
+		*dest, err = store.Import([]string{"master-enc", "master-mac", "master-dek"}[i], keystore.AlgorithmAES, bytes.Repeat([]byte{0x40 + byte(i)}, 16))
+		assert.NoError(t, err)
+	}
+	selector := KeySetSelector{Store: store, KeySets: []KeySetConfig{{KeyVersionNumber: 0x30, ENC: "master-enc", MAC: "master-mac", DEK: "master-dek", Diversification: keydiv.KDF3{}}}}
+	template := keySetInformation(0x30, gpapdu.AES, 16)
+	for _, kdd := range [][10]byte{
+		{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09},
+		{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19},
+	} {
+		t.Run(fmt.Sprintf("%X", kdd), func(t *testing.T) {
+			cardKeys, err := keydiv.Diversify(keydiv.KDF3{}, master, kdd)
+			assert.NoError(t, err)
+			selected, err := selector.Select(template, 0x03, kdd)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, cardKeys, selected.Keys)
+			card, err := scp03.NewCard(scp03.Keys(cardKeys), scp03.CardOptions{KeyVersionNumber: 0x30, KeyDiversificationData: kdd})
+			assert.NoError(t, err)
+			_, err = scp03.Open(card, scp03.Keys(selected.Keys), scp03.Options{KeyVersionNumber: 0x30, SecurityLevel: gpapdu.SecurityLevelCMAC})
+			assert.NoError(t, err)
+			assert.True(t, card.Authenticated())
+		})
+	}
+	t.Run("method does not suit the keys", func(t *testing.T) {
+		emv := selector
+		emv.KeySets = []KeySetConfig{selector.KeySets[0]}
+		emv.KeySets[0].Diversification = keydiv.EMVCPS11{}
+		_, err := emv.Select(template, 0x03, [10]byte{})
+		assert.Error(t, err)
+	})
+}
+
+// templateCard answers GET DATA with a fixed key information template
+type templateCard struct {
+	data []byte
+}
+
+func (c *templateCard) Send(cmd apdu.Command) (apdu.Response, error) {
+	return apdu.Response{Data: c.data, Status: apdu.RawStatus{SW1: 0x90}.Identify()}, nil
+}
+
+func TestReadKeyInformation(t *testing.T) {
+	got, err := ReadKeyInformation(gpapdu.NewClient(&templateCard{data: []byte{0xE0, 0x06, 0xC0, 0x04, 0x01, 0x30, 0x88, 0x10}}))
+	assert.NoError(t, err)
+	assert.Equal(t, keySetInformation(0x30, gpapdu.AES, 16)[:1], got)
+	_, err = ReadKeyInformation(gpapdu.NewClient(&templateCard{data: []byte{0xE0, 0x02, 0xC0, 0x00}}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,170 @@
+package gpapi
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	lifecycle "github.com/llkennedy/globalplatform/goimpl/lifcecycle"
+)
+
+// SecurityDomainOptions describes a supplementary security domain to create from the security domain module already on the card
+type SecurityDomainOptions struct {
+	ExecutableLoadFileAID []byte
+	ExecutableModuleAID   []byte
+	AID                   []byte
+	// Privileges of the new security domain, the Security Domain privilege is always added
+	Privileges gpapdu.Privileges
+	// Parameters carry the security domain install parameters, such as the accepted secure channel protocols
+	Parameters gpapdu.InstallParameters
+}
+
+// CreateSecurityDomain installs and makes selectable a supplementary security domain, the client must be connected to a security domain with the Authorized Management privilege
+func CreateSecurityDomain(client *gpapdu.Client, opts SecurityDomainOptions) error {
+	if client == nil {
+		return fmt.Errorf("must supply a client")
+	}
+	privileges := opts.Privileges
+	privileges.SecurityDomain = true
+	encoded := privileges.ToBytes()
+	if _, err := gpapdu.ParsePrivileges(encoded[:]); err != nil {
+		return fmt.Errorf("invalid security domain privileges: %w", err)
+	}
+	_, err := client.Install(gpapdu.InstallForInstall{
+		ExecutableLoadFileAID: opts.ExecutableLoadFileAID,
+		ExecutableModuleAID:   opts.ExecutableModuleAID,
+		ApplicationAID:        opts.AID,
+		Privileges:            privileges,
+		Parameters:            opts.Parameters,
+		MakeSelectable:        true,
+	})
+	if err != nil {
+		return fmt.Errorf("INSTALL [for install and make selectable]: %w", err)
+	}
+	return nil
+}
+
+// SecurityDomainPersonalization is the data a new security domain is personalized with, in its own secure channel
+type SecurityDomainPersonalization struct {
+	// PutKey replaces or adds to the initial keys the security domain was created with, skipped when nil
+	PutKey *gpapdu.PutKeyCommand
+	// StoreData carries the rest of the personalization data, such as keys on cards which take them as DGIs, sent in order
+	StoreData []gpapdu.StoreDataCommand
+	// Personalized moves the security domain from SELECTABLE to PERSONALIZED once everything is sent
+	Personalized bool
+}
+
+// PersonalizeSecurityDomain selects the security domain, opens a secure channel with it and sends its keys and personalization data
+func PersonalizeSecurityDomain(transport apdu.Transport, aid []byte, open gpapdu.OpenSecureChannel, p SecurityDomainPersonalization) error {
+	session, err := gpapdu.SelectAndOpen(transport, aid, open)
+	if err != nil {
+		return err
+	}
+	client := gpapdu.NewClient(session)
+	if p.PutKey != nil {
+		if err = client.PutKey(*p.PutKey, session); err != nil {
+			return fmt.Errorf("PUT KEY: %w", err)
+		}
+	}
+	for _, cmd := range p.StoreData {
+		if _, err = client.StoreData(cmd); err != nil {
+			return fmt.Errorf("STORE DATA: %w", err)
+		}
+	}
+	if !p.Personalized {
+		return nil
+	}
+	return client.SetStatus(gpapdu.SetSecurityDomainStatus{
+		AID:       aid,
+		Current:   lifecycle.SecurityDomainSelectable,
+		Target:    lifecycle.SecurityDomainPersonalized,
+		Requester: &lifecycle.Requester{Self: true},
+	})
+}
+
+// ExtraditeApplications associates each application or executable load file with the security domain, then reads the registry to confirm every association took effect
+func ExtraditeApplications(client *gpapdu.Client, securityDomainAID []byte, aids [][]byte) error {
+	if client == nil || len(securityDomainAID) == 0 {
+		return fmt.Errorf("must supply a client and security domain AID")
+	}
+	for _, aid := range aids {
+		if _, err := client.Install(gpapdu.InstallForExtradition{SecurityDomainAID: securityDomainAID, ApplicationAID: aid}); err != nil {
+			return fmt.Errorf("INSTALL [for extradition] of %X: %w", aid, err)
+		}
+	}
+	associated, err := SecurityDomainAssociations(client, securityDomainAID)
+	if err != nil {
+		return err
+	}
+	for _, aid := range aids {
+		if _, ok := entryFor(associated, aid); !ok {
+			return fmt.Errorf("%X is not associated with %X after extradition", aid, securityDomainAID)
+		}
+	}
+	return nil
+}
+
+// SecurityDomainAssociations reads the applications and executable load files associated with the security domain
+func SecurityDomainAssociations(client *gpapdu.Client, securityDomainAID []byte) ([]gpapdu.RegistryEntry, error) {
+	if client == nil || len(securityDomainAID) == 0 {
+		return nil, fmt.Errorf("must supply a client and security domain AID")
+	}
+	var associated []gpapdu.RegistryEntry
+	for _, subset := range []gpapdu.StatusSubset{gpapdu.StatusApplications, gpapdu.StatusExecutableLoadFiles} {
+		entries, err := client.GetStatus(gpapdu.GetStatusCommand{Subset: subset})
+		if err != nil {
+			return nil, fmt.Errorf("GET STATUS: %w", err)
+		}
+		for _, entry := range entries {
+			if bytes.Equal(entry.AssociatedSecurityDomainAID, securityDomainAID) && !bytes.Equal(entry.AID, securityDomainAID) {
+				associated = append(associated, entry)
+			}
+		}
+	}
+	return associated, nil
+}
+
+func entryFor(entries []gpapdu.RegistryEntry, aid []byte) (gpapdu.RegistryEntry, bool) {
+	for _, entry := range entries {
+		if bytes.Equal(entry.AID, aid) {
+			return entry, true
+		}
+	}
+	return gpapdu.RegistryEntry{}, false
+}
+
+// SecurityDomainSetup is a whole supplementary security domain workflow
+type SecurityDomainSetup struct {
+	// IssuerSecurityDomainAID is selected to create the security domain and extradite to it, the default application is selected when empty
+	IssuerSecurityDomainAID []byte
+	SecurityDomain          SecurityDomainOptions
+	Personalization         SecurityDomainPersonalization
+	// Extradite lists the applications and executable load files to associate with the new security domain
+	Extradite [][]byte
+}
+
+// SetUpSecurityDomain creates a supplementary security domain through the issuer security domain, personalizes it in its own secure channel, then returns to the issuer security domain to extradite applications to it.
+// The associations of the new security domain are returned once everything is done.
+func SetUpSecurityDomain(transport apdu.Transport, open gpapdu.OpenSecureChannel, setup SecurityDomainSetup) ([]gpapdu.RegistryEntry, error) {
+	isd, err := gpapdu.SelectAndOpen(transport, setup.IssuerSecurityDomainAID, open)
+	if err != nil {
+		return nil, err
+	}
+	if err = CreateSecurityDomain(gpapdu.NewClient(isd), setup.SecurityDomain); err != nil {
+		return nil, err
+	}
+	if err = PersonalizeSecurityDomain(transport, setup.SecurityDomain.AID, open, setup.Personalization); err != nil {
+		return nil, fmt.Errorf("personalizing %X: %w", setup.SecurityDomain.AID, err)
+	}
+	if isd, err = gpapdu.SelectAndOpen(transport, setup.IssuerSecurityDomainAID, open); err != nil {
+		return nil, err
+	}
+	client := gpapdu.NewClient(isd)
+	if len(setup.Extradite) > 0 {
+		if err = ExtraditeApplications(client, setup.SecurityDomain.AID, setup.Extradite); err != nil {
+			return nil, err
+		}
+	}
+	return SecurityDomainAssociations(client, setup.SecurityDomain.AID)
+}
@@ -0,0 +1,187 @@
+package gpapi
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testSecurityDomainAID = []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x53}
+	testApplicationAID    = []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01}
+	// testAssociatedEntry is a SELECTABLE application associated with testSecurityDomainAID
+	testAssociatedEntry = []byte{0xE3, 0x14, 0x4F, 0x06, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x01, 0x9F, 0x70, 0x01, 0x07, 0xCC, 0x06, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x53}
+)
+
+// plainChannel sends commands unwrapped and "enciphers" by inverting every byte
+type plainChannel struct {
+	apdu.Transport
+}
+
+func (p plainChannel) EncryptSensitiveData(data []byte) ([]byte, error) {
+	out := make([]byte, len(data))
+	for i := range data {
+		out[i] = ^data[i]
+	}
+	return out, nil
+}
+
+func openPlainChannel(transport apdu.Transport, aid []byte) (gpapdu.SecureChannel, error) {
+	return plainChannel{transport}, nil
+}
+
+func instructions(commands []apdu.Command) []apdu.Instruction {
+	var out []apdu.Instruction
+	for _, cmd := range commands {
+		out = append(out, cmd.Instruction)
+	}
+	return out
+}
+
+func TestCreateSecurityDomain(t *testing.T) {
+	assert.Error(t, CreateSecurityDomain(nil, SecurityDomainOptions{}))
+	card := &recordingCard{}
+	err := CreateSecurityDomain(gpapdu.NewClient(card), SecurityDomainOptions{
+		ExecutableLoadFileAID: []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x53, 0x50},
+		ExecutableModuleAID:   []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x53, 0x50, 0x43},
+		AID:                   testSecurityDomainAID,
+		Privileges:            gpapdu.Privileges{AuthorizedManagement: true},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, card.commands, 1) {
+		assert.Equal(t, gpapdu.InstructionInstall, card.commands[0].Instruction)
+		assert.Equal(t, byte(0x0C), card.commands[0].P1)
+		// Privileges follow the three AIDs
+		assert.Equal(t, []byte{0x03, 0x80, 0x40, 0x00}, card.commands[0].Data[24:28])
+	}
+	t.Run("invalid AID", func(t *testing.T) {
+		card := &recordingCard{}
+		assert.Error(t, CreateSecurityDomain(gpapdu.NewClient(card), SecurityDomainOptions{AID: []byte{0xA0}}))
+		assert.Empty(t, card.commands)
+	})
+}
+
+func TestPersonalizeSecurityDomain(t *testing.T) {
+	storeData := gpapdu.StoreDataCommand{Records: [][]byte{{0x00, 0x70, 0x01, 0x00}}, Format: gpapdu.StoreDataDGIFormat}
+	tests := []struct {
+		name             string
+		transport        apdu.Transport
+		open             gpapdu.OpenSecureChannel
+		personalization  SecurityDomainPersonalization
+		wantInstructions []apdu.Instruction
+		assertion        assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "no secure channel",
+			transport: &recordingCard{},
+			assertion: assert.Error,
+		},
+		{
+			name:      "secure channel fails",
+			transport: &recordingCard{},
+			open: func(apdu.Transport, []byte) (gpapdu.SecureChannel, error) {
+				return nil, fmt.Errorf("authentication failed")
+			},
+			wantInstructions: []apdu.Instruction{apdu.InstructionSelect},
+			assertion:        assert.Error,
+		},
+		{
+			name:             "store data",
+			transport:        &recordingCard{},
+			open:             openPlainChannel,
+			personalization:  SecurityDomainPersonalization{StoreData: []gpapdu.StoreDataCommand{storeData}},
+			wantInstructions: []apdu.Instruction{apdu.InstructionSelect, gpapdu.InstructionStoreData},
+			assertion:        assert.NoError,
+		},
+		{
+			name:             "personalized",
+			transport:        &recordingCard{},
+			open:             openPlainChannel,
+			personalization:  SecurityDomainPersonalization{StoreData: []gpapdu.StoreDataCommand{storeData}, Personalized: true},
+			wantInstructions: []apdu.Instruction{apdu.InstructionSelect, gpapdu.InstructionStoreData, gpapdu.InstructionSetStatus},
+			assertion:        assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.assertion(t, PersonalizeSecurityDomain(tt.transport, testSecurityDomainAID, tt.open, tt.personalization))
+			card := tt.transport.(*recordingCard)
+			assert.Equal(t, tt.wantInstructions, instructions(card.commands))
+			if tt.personalization.Personalized && len(card.commands) == 3 {
+				assert.Equal(t, byte(0x40), card.commands[2].P1)
+				assert.Equal(t, byte(0x0F), card.commands[2].P2)
+				assert.Equal(t, testSecurityDomainAID, card.commands[2].Data)
+			}
+		})
+	}
+}
+
+func TestExtraditeApplications(t *testing.T) {
+	tests := []struct {
+		name      string
+		entry     []byte
+		aids      [][]byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "associated", entry: testAssociatedEntry, aids: [][]byte{testApplicationAID}, assertion: assert.NoError},
+		{name: "not associated", entry: append(append([]byte{0xE3, 0x0C, 0x4F, 0x06}, testApplicationAID...), 0x9F, 0x70, 0x01, 0x07), aids: [][]byte{testApplicationAID}, assertion: assert.Error},
+		{name: "invalid AID", entry: testAssociatedEntry, aids: [][]byte{{0xA0}}, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &registryCard{entry: tt.entry}
+			tt.assertion(t, ExtraditeApplications(gpapdu.NewClient(card), testSecurityDomainAID, tt.aids))
+		})
+	}
+	t.Run("extradition command", func(t *testing.T) {
+		card := &registryCard{entry: testAssociatedEntry}
+		assert.NoError(t, ExtraditeApplications(gpapdu.NewClient(card), testSecurityDomainAID, [][]byte{testApplicationAID}))
+		assert.Equal(t, []apdu.Instruction{gpapdu.InstructionInstall, gpapdu.InstructionGetStatus, gpapdu.InstructionGetStatus}, instructions(card.commands))
+		assert.Equal(t, byte(0x10), card.commands[0].P1)
+	})
+}
+
+func TestSecurityDomainAssociations(t *testing.T) {
+	_, err := SecurityDomainAssociations(nil, testSecurityDomainAID)
+	assert.Error(t, err)
+	got, err := SecurityDomainAssociations(gpapdu.NewClient(&registryCard{entry: testAssociatedEntry}), testSecurityDomainAID)
+	assert.NoError(t, err)
+	// The same entry is returned for applications and executable load files
+	if assert.Len(t, got, 2) {
+		assert.Equal(t, testApplicationAID, got[0].AID)
+	}
+	got, err = SecurityDomainAssociations(gpapdu.NewClient(&registryCard{entry: testAssociatedEntry}), []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x54})
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+	// Privileges the card encoded badly are reported on the entry rather than failing the whole read
+	oddPrivileges := append(append([]byte{0xE3, 0x17}, testAssociatedEntry[2:]...), 0xC5, 0x01, 0x40)
+	got, err = SecurityDomainAssociations(gpapdu.NewClient(&registryCard{entry: oddPrivileges}), testSecurityDomainAID)
+	assert.NoError(t, err)
+	if assert.Len(t, got, 2) {
+		assert.Error(t, got[0].PrivilegesError)
+	}
+}
+
+func TestSetUpSecurityDomain(t *testing.T) {
+	card := &registryCard{entry: testAssociatedEntry}
+	got, err := SetUpSecurityDomain(card, openPlainChannel, SecurityDomainSetup{
+		SecurityDomain: SecurityDomainOptions{
+			ExecutableLoadFileAID: []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x53, 0x50},
+			ExecutableModuleAID:   []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x53, 0x50, 0x43},
+			AID:                   testSecurityDomainAID,
+		},
+		Personalization: SecurityDomainPersonalization{Personalized: true},
+		Extradite:       [][]byte{testApplicationAID},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, got, 2)
+	assert.Equal(t, []apdu.Instruction{
+		apdu.InstructionSelect, gpapdu.InstructionInstall,
+		apdu.InstructionSelect, gpapdu.InstructionSetStatus,
+		apdu.InstructionSelect, gpapdu.InstructionInstall, gpapdu.InstructionGetStatus, gpapdu.InstructionGetStatus,
+		gpapdu.InstructionGetStatus, gpapdu.InstructionGetStatus,
+	}, instructions(card.commands))
+}
@@ -0,0 +1,76 @@
+package gpapi
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	lifecycle "github.com/llkennedy/globalplatform/goimpl/lifcecycle"
+)
+
+// LockCard reads the card life cycle state from the issuer security domain and moves the card to CARD_LOCKED, if the issuer security domain holds the Card Lock privilege
+func LockCard(client *gpapdu.Client) error {
+	if client == nil {
+		return fmt.Errorf("must supply a client")
+	}
+	isd, err := findEntry(client, gpapdu.StatusIssuerSecurityDomain, nil)
+	if err != nil {
+		return err
+	}
+	requester := isd.Privileges.LifeCycleRequester()
+	requester.IssuerSecurityDomain = true
+	return client.SetStatus(gpapdu.SetCardStatus{
+		AID:       isd.AID,
+		Current:   isd.CardLifeCycle(),
+		Target:    lifecycle.CardCardLocked,
+		Requester: &requester,
+	})
+}
+
+// LockApplication reads the life cycle state of an application or security domain and locks it through the issuer security domain, security domains are locked along with their associated applications.
+// The request is checked against the issuer security domain's privileges, and entries without an associated security domain are taken to be associated with the issuer security domain.
+func LockApplication(client *gpapdu.Client, aid []byte) error {
+	if client == nil || len(aid) == 0 {
+		return fmt.Errorf("must supply a client and AID")
+	}
+	entry, err := findEntry(client, gpapdu.StatusApplications, aid)
+	if err != nil {
+		return err
+	}
+	isd, err := findEntry(client, gpapdu.StatusIssuerSecurityDomain, nil)
+	if err != nil {
+		return err
+	}
+	requester := isd.Privileges.LifeCycleRequester()
+	requester.IssuerSecurityDomain = true
+	requester.AssociatedSecurityDomain = len(entry.AssociatedSecurityDomainAID) == 0 || bytes.Equal(entry.AssociatedSecurityDomainAID, isd.AID)
+	if entry.Privileges.SecurityDomain {
+		return client.SetStatus(gpapdu.SetSecurityDomainStatus{
+			AID:                        entry.AID,
+			Current:                    entry.SecurityDomainLifeCycle(),
+			Target:                     lifecycle.SecurityDomainLocked,
+			WithAssociatedApplications: true,
+			Requester:                  &requester,
+		})
+	}
+	return client.SetStatus(gpapdu.SetApplicationStatus{
+		AID:       entry.AID,
+		Current:   entry.ApplicationLifeCycle(),
+		Target:    lifecycle.ApplicationLocked,
+		Requester: &requester,
+	})
+}
+
+// findEntry reads the registry entry with exactly the AID, or the only entry of the subset when the AID is empty
+func findEntry(client *gpapdu.Client, subset gpapdu.StatusSubset, aid []byte) (gpapdu.RegistryEntry, error) {
+	entries, err := client.GetStatus(gpapdu.GetStatusCommand{Subset: subset, AID: aid})
+	if err != nil {
+		return gpapdu.RegistryEntry{}, fmt.Errorf("GET STATUS: %w", err)
+	}
+	for _, entry := range entries {
+		if len(aid) == 0 || bytes.Equal(entry.AID, aid) {
+			return entry, nil
+		}
+	}
+	return gpapdu.RegistryEntry{}, fmt.Errorf("no registry entry found for %X", aid)
+}
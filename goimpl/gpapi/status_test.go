@@ -0,0 +1,94 @@
+package gpapi
+
+import (
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/stretchr/testify/assert"
+)
+
+// registryCard answers GET STATUS with a fixed registry entry, or the issuer security domain entry when it is set and asked for, and accepts everything else
+type registryCard struct {
+	entry    []byte
+	isd      []byte
+	commands []apdu.Command
+}
+
+func (c *registryCard) Send(cmd apdu.Command) (apdu.Response, error) {
+	c.commands = append(c.commands, cmd)
+	if cmd.Instruction == gpapdu.InstructionGetStatus {
+		if c.isd != nil && cmd.P1 == byte(gpapdu.StatusIssuerSecurityDomain) {
+			return apdu.Response{Data: c.isd, Status: apdu.RawStatus{SW1: 0x90}.Identify()}, nil
+		}
+		return apdu.Response{Data: c.entry, Status: apdu.RawStatus{SW1: 0x90}.Identify()}, nil
+	}
+	return apdu.Response{Status: apdu.RawStatus{SW1: 0x90}.Identify()}, nil
+}
+
+func TestLockCard(t *testing.T) {
+	isd := []byte{0xE3, 0x10, 0x4F, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51, 0xC5, 0x03, 0x9E, 0xFE, 0x80, 0x9F, 0x70, 0x01, 0x0F}
+	card := &registryCard{entry: isd}
+	assert.NoError(t, LockCard(gpapdu.NewClient(card)))
+	if assert.Len(t, card.commands, 2) {
+		assert.Equal(t, gpapdu.InstructionSetStatus, card.commands[1].Instruction)
+		assert.Equal(t, byte(0x80), card.commands[1].P1)
+		assert.Equal(t, byte(0x7F), card.commands[1].P2)
+		assert.Equal(t, []byte{0xA0, 0x00, 0x00, 0x01, 0x51}, card.commands[1].Data)
+	}
+	t.Run("already locked", func(t *testing.T) {
+		locked := append(append([]byte{}, isd[:len(isd)-1]...), 0x7F)
+		card := &registryCard{entry: locked}
+		assert.Error(t, LockCard(gpapdu.NewClient(card)))
+		assert.Len(t, card.commands, 1)
+	})
+	t.Run("without Card Lock privilege", func(t *testing.T) {
+		unprivileged := append([]byte{}, isd...)
+		unprivileged[11] = 0x80
+		card := &registryCard{entry: unprivileged}
+		assert.Error(t, LockCard(gpapdu.NewClient(card)))
+		assert.Len(t, card.commands, 1)
+	})
+}
+
+func TestLockApplication(t *testing.T) {
+	aid := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x01}
+	otherSD := []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x02}
+	isd := []byte{0xE3, 0x10, 0x4F, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51, 0xC5, 0x03, 0x9E, 0xFE, 0x80, 0x9F, 0x70, 0x01, 0x0F}
+	withoutGlobalLock := append([]byte{}, isd...)
+	withoutGlobalLock[12] = 0xF6
+	application := append(append([]byte{0xE3, 0x0C, 0x4F, 0x06}, aid...), 0x9F, 0x70, 0x01, 0x07)
+	associated := append(append(append([]byte{0xE3, 0x14, 0x4F, 0x06}, aid...), 0x9F, 0x70, 0x01, 0x07, 0xCC, 0x06), otherSD...)
+	tests := []struct {
+		name      string
+		entry     []byte
+		isd       []byte
+		wantP1    byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "application", entry: application, isd: withoutGlobalLock, wantP1: 0x40, assertion: assert.NoError},
+		{name: "security domain", entry: append(append([]byte{0xE3, 0x0F, 0x4F, 0x06}, aid...), 0x9F, 0x70, 0x01, 0x0F, 0xC5, 0x01, 0x80), isd: withoutGlobalLock, wantP1: 0x60, assertion: assert.NoError},
+		{name: "associated with another security domain", entry: associated, isd: isd, wantP1: 0x40, assertion: assert.NoError},
+		{name: "associated with another security domain without Global Lock", entry: associated, isd: withoutGlobalLock, assertion: assert.Error},
+		{name: "already locked", entry: append(append([]byte{0xE3, 0x0C, 0x4F, 0x06}, aid...), 0x9F, 0x70, 0x01, 0x87), isd: isd, assertion: assert.Error},
+		{name: "not found", entry: []byte{0xE3, 0x0B, 0x4F, 0x05, 0xA0, 0x00, 0x00, 0x01, 0x51, 0x9F, 0x70, 0x01, 0x07}, isd: isd, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &registryCard{entry: tt.entry, isd: tt.isd}
+			err := LockApplication(gpapdu.NewClient(card), aid)
+			tt.assertion(t, err)
+			if err != nil {
+				for _, cmd := range card.commands {
+					assert.NotEqual(t, gpapdu.InstructionSetStatus, cmd.Instruction)
+				}
+				return
+			}
+			if assert.Len(t, card.commands, 3) {
+				assert.Equal(t, tt.wantP1, card.commands[2].P1)
+				assert.Equal(t, byte(0x80), card.commands[2].P2)
+				assert.Equal(t, aid, card.commands[2].Data)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package gpcert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+)
+
+const (
+	tagCertificate           = 0x7F21
+	tagCertificateStore      = 0xBF21
+	tagSerialNumber          = 0x93
+	tagCAKLOCIdentifier      = 0x42
+	tagSubjectIdentifier     = 0x5F20
+	tagKeyUsage              = 0x95
+	tagEffectiveDate         = 0x5F25
+	tagExpirationDate        = 0x5F24
+	tagPublicKey             = 0x7F49
+	tagPublicKeyPoint        = 0xB0
+	tagKeyParameterReference = 0xF0
+	tagSignature             = 0x5F37
+)
+
+// KeyParameterReference identifies the curve of an ECC key
+type KeyParameterReference byte
+
+const (
+	// KeyParameterP256 is NIST P-256
+	KeyParameterP256 KeyParameterReference = 0x00
+	// KeyParameterP384 is NIST P-384
+	KeyParameterP384 KeyParameterReference = 0x01
+	// KeyParameterP521 is NIST P-521
+	KeyParameterP521 KeyParameterReference = 0x02
+)
+
+// Curve returns the curve referenced
+func (k KeyParameterReference) Curve() (elliptic.Curve, error) {
+	switch k {
+	case KeyParameterP256:
+		return elliptic.P256(), nil
+	case KeyParameterP384:
+		return elliptic.P384(), nil
+	case KeyParameterP521:
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("unsupported key parameter reference %02X", byte(k))
+	}
+}
+
+// KeyParameterReferenceFromCurve finds the reference for a curve
+func KeyParameterReferenceFromCurve(curve elliptic.Curve) (KeyParameterReference, error) {
+	if curve == nil {
+		return 0, fmt.Errorf("nil curve")
+	}
+	switch curve.Params().Name {
+	case "P-256":
+		return KeyParameterP256, nil
+	case "P-384":
+		return KeyParameterP384, nil
+	case "P-521":
+		return KeyParameterP521, nil
+	default:
+		return 0, fmt.Errorf("unsupported curve %s", curve.Params().Name)
+	}
+}
+
+// Key usages (tag 95) of the certified key
+var (
+	// KeyUsageSignatureVerification is the usage of a key which verifies signatures, which every certificate issuing others must have
+	KeyUsageSignatureVerification = []byte{0x00, 0x82}
+	// KeyUsageKeyAgreement is the usage of a key agreement key, such as PK.SD.ECKA
+	KeyUsageKeyAgreement = []byte{0x00, 0x80}
+)
+
+// Certificate is a GlobalPlatform certificate
+type Certificate struct {
+	SerialNumber      []byte
+	CAKLOCIdentifier  []byte // Identifies the issuer, matching the SubjectIdentifier of the issuing certificate or trust anchor
+	SubjectIdentifier []byte
+	KeyUsage          []byte
+	EffectiveDate     time.Time // Optional, the zero value is omitted
+	ExpirationDate    time.Time
+	PublicKey         *ecdsa.PublicKey
+	Signature         []byte // r || s, each the byte length of the issuer's curve
+	// RawTBS is the encoded content the signature covers, it is set by Parse and Sign and takes precedence over re-encoding the fields
+	RawTBS []byte
+}
+
+// HasKeyUsage reports whether the certificate's key usage is the usage, ignoring leading zero bytes of either
+func (c *Certificate) HasKeyUsage(usage []byte) bool {
+	trimmed := bytes.TrimLeft(usage, "\x00")
+	return len(trimmed) > 0 && bytes.Equal(bytes.TrimLeft(c.KeyUsage, "\x00"), trimmed)
+}
+
+// Parse parses a single certificate, including the outer 7F21 tag
+func Parse(data []byte) (*Certificate, error) {
+	objects, err := bertlv.ReadAll(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading certificate: %w", err)
+	}
+	if len(objects) != 1 || objects[0].Tag != bertlv.TagFromUintForced(tagCertificate) {
+		return nil, fmt.Errorf("certificate must be exactly one 7F21 object")
+	}
+	return parseContent(objects[0].Value)
+}
+
+// ParseChain parses a list of certificates, optionally wrapped in the BF21 container returned by GET DATA for CERT.SD
+func ParseChain(data []byte) ([]*Certificate, error) {
+	objects, err := bertlv.ReadAll(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading certificate chain: %w", err)
+	}
+	if len(objects) == 1 && objects[0].Tag == bertlv.TagFromUintForced(tagCertificateStore) {
+		if objects, err = bertlv.ReadAll(objects[0].Value); err != nil {
+			return nil, fmt.Errorf("reading certificate store: %w", err)
+		}
+	}
+	var out []*Certificate
+	for i, obj := range objects {
+		if obj.Tag != bertlv.TagFromUintForced(tagCertificate) {
+			return nil, fmt.Errorf("object %d in chain is not a certificate", i)
+		}
+		cert, err := parseContent(obj.Value)
+		if err != nil {
+			return nil, fmt.Errorf("certificate %d: %w", i, err)
+		}
+		out = append(out, cert)
+	}
+	return out, nil
+}
+
+func parseContent(content []byte) (*Certificate, error) {
+	c := &Certificate{}
+	r := bertlv.NewBytesReader(content)
+	// The signature covers the encoding of every object before it, including any this package does not interpret
+	var tbs []byte
+	for offset := 0; offset < len(content); {
+		n, obj, err := r.Read()
+		if err != nil {
+			return nil, fmt.Errorf("reading certificate field at offset %d: %w", offset, err)
+		}
+		if c.Signature != nil {
+			return nil, fmt.Errorf("certificate field at offset %d follows the signature", offset)
+		}
+		if obj.Tag != bertlv.TagFromUintForced(tagSignature) {
+			tbs = append(tbs, content[offset:offset+n]...)
+		}
+		switch obj.Tag {
+		case bertlv.TagFromUintForced(tagSerialNumber):
+			c.SerialNumber = obj.Value
+		case bertlv.TagFromUintForced(tagCAKLOCIdentifier):
+			c.CAKLOCIdentifier = obj.Value
+		case bertlv.TagFromUintForced(tagSubjectIdentifier):
+			c.SubjectIdentifier = obj.Value
+		case bertlv.TagFromUintForced(tagKeyUsage):
+			c.KeyUsage = obj.Value
+		case bertlv.TagFromUintForced(tagEffectiveDate):
+			if c.EffectiveDate, err = dateFromBCD(obj.Value); err != nil {
+				return nil, fmt.Errorf("effective date: %w", err)
+			}
+		case bertlv.TagFromUintForced(tagExpirationDate):
+			if c.ExpirationDate, err = dateFromBCD(obj.Value); err != nil {
+				return nil, fmt.Errorf("expiration date: %w", err)
+			}
+		case bertlv.TagFromUintForced(tagPublicKey):
+			if c.PublicKey, err = parsePublicKey(obj.Value); err != nil {
+				return nil, fmt.Errorf("public key: %w", err)
+			}
+		case bertlv.TagFromUintForced(tagSignature):
+			c.Signature = obj.Value
+			c.RawTBS = append([]byte{}, tbs...)
+		}
+		offset += n
+	}
+	switch {
+	case c.SerialNumber == nil:
+		return nil, fmt.Errorf("certificate has no serial number")
+	case c.CAKLOCIdentifier == nil:
+		return nil, fmt.Errorf("certificate has no CA-KLOC identifier")
+	case c.SubjectIdentifier == nil:
+		return nil, fmt.Errorf("certificate has no subject identifier")
+	case c.PublicKey == nil:
+		return nil, fmt.Errorf("certificate has no public key")
+	case c.Signature == nil:
+		return nil, fmt.Errorf("certificate has no signature")
+	}
+	return c, nil
+}
+
+func parsePublicKey(content []byte) (*ecdsa.PublicKey, error) {
+	objects, err := bertlv.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	var point []byte
+	var curve elliptic.Curve
+	for _, obj := range objects {
+		switch obj.Tag {
+		case bertlv.TagFromUintForced(tagPublicKeyPoint):
+			point = obj.Value
+		case bertlv.TagFromUintForced(tagKeyParameterReference):
+			if len(obj.Value) != 1 {
+				return nil, fmt.Errorf("key parameter reference must be 1 byte")
+			}
+			if curve, err = KeyParameterReference(obj.Value[0]).Curve(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if point == nil {
+		return nil, fmt.Errorf("no public key point")
+	}
+	if curve == nil {
+		curve = elliptic.P256()
+	}
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil {
+		return nil, fmt.Errorf("public key is not a valid uncompressed point on %s", curve.Params().Name)
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+// TBS encodes the fields covered by the signature, or returns RawTBS if it is set
+func (c *Certificate) TBS() ([]byte, error) {
+	if c.RawTBS != nil {
+		return c.RawTBS, nil
+	}
+	if c.PublicKey == nil {
+		return nil, fmt.Errorf("certificate has no public key")
+	}
+	keyRef, err := KeyParameterReferenceFromCurve(c.PublicKey.Curve)
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.NewBuffer(nil)
+	w, _ := bertlv.NewWriter(buf)
+	write := func(tag uint64, value []byte) {
+		if err == nil {
+			_, err = w.Write(bertlv.Object{Tag: bertlv.TagFromUintForced(tag), Value: value})
+		}
+	}
+	write(tagSerialNumber, c.SerialNumber)
+	write(tagCAKLOCIdentifier, c.CAKLOCIdentifier)
+	write(tagSubjectIdentifier, c.SubjectIdentifier)
+	if c.KeyUsage != nil {
+		write(tagKeyUsage, c.KeyUsage)
+	}
+	if !c.EffectiveDate.IsZero() {
+		write(tagEffectiveDate, dateToBCD(c.EffectiveDate))
+	}
+	write(tagExpirationDate, dateToBCD(c.ExpirationDate))
+	keyBuf := bytes.NewBuffer(nil)
+	keyWriter, _ := bertlv.NewWriter(keyBuf)
+	keyWriter.Write(bertlv.Object{Tag: bertlv.TagFromUintForced(tagPublicKeyPoint), Value: elliptic.Marshal(c.PublicKey.Curve, c.PublicKey.X, c.PublicKey.Y)})
+	keyWriter.Write(bertlv.Object{Tag: bertlv.TagFromUintForced(tagKeyParameterReference), Value: []byte{byte(keyRef)}})
+	write(tagPublicKey, keyBuf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ToBytes encodes the signed certificate, including the outer 7F21 tag
+func (c *Certificate) ToBytes() ([]byte, error) {
+	if c.Signature == nil {
+		return nil, fmt.Errorf("certificate must be signed before encoding")
+	}
+	tbs, err := c.TBS()
+	if err != nil {
+		return nil, err
+	}
+	sig, err := bertlv.Object{Tag: bertlv.TagFromUintForced(tagSignature), Value: c.Signature}.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	return bertlv.Object{
+		Tag:   bertlv.TagFromUintForced(tagCertificate),
+		Value: append(append([]byte{}, tbs...), sig...),
+	}.ToBytes()
+}
+
+// Sign encodes the fields and signs them with the issuer's key, replacing RawTBS and Signature
+func (c *Certificate) Sign(issuer *ecdsa.PrivateKey, randR io.Reader) error {
+	if issuer == nil {
+		return fmt.Errorf("nil issuer key")
+	}
+	c.RawTBS = nil
+	tbs, err := c.TBS()
+	if err != nil {
+		return err
+	}
+	digest, err := digestFor(issuer.Curve, tbs)
+	if err != nil {
+		return err
+	}
+	r, s, err := ecdsa.Sign(randR, issuer, digest)
+	if err != nil {
+		return fmt.Errorf("signing certificate: %w", err)
+	}
+	size := (issuer.Curve.Params().BitSize + 7) / 8
+	sig := make([]byte, 2*size)
+	r.FillBytes(sig[:size])
+	s.FillBytes(sig[size:])
+	c.RawTBS = tbs
+	c.Signature = sig
+	return nil
+}
+
+// CheckSignature verifies the certificate's signature with the issuer's public key
+func (c *Certificate) CheckSignature(issuer *ecdsa.PublicKey) error {
+	if issuer == nil || issuer.Curve == nil {
+		return fmt.Errorf("nil issuer key")
+	}
+	size := (issuer.Curve.Params().BitSize + 7) / 8
+	if len(c.Signature) != 2*size {
+		return fmt.Errorf("signature must be %d bytes for %s, got %d", 2*size, issuer.Curve.Params().Name, len(c.Signature))
+	}
+	tbs, err := c.TBS()
+	if err != nil {
+		return err
+	}
+	digest, err := digestFor(issuer.Curve, tbs)
+	if err != nil {
+		return err
+	}
+	r := new(big.Int).SetBytes(c.Signature[:size])
+	s := new(big.Int).SetBytes(c.Signature[size:])
+	if !ecdsa.Verify(issuer, digest, r, s) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// digestFor hashes data with the hash matching the strength of the curve
+func digestFor(curve elliptic.Curve, data []byte) ([]byte, error) {
+	var h crypto.Hash
+	switch curve.Params().Name {
+	case "P-256":
+		h = crypto.SHA256
+	case "P-384":
+		h = crypto.SHA384
+	case "P-521":
+		h = crypto.SHA512
+	default:
+		return nil, fmt.Errorf("unsupported curve %s", curve.Params().Name)
+	}
+	hash := h.New()
+	hash.Write(data)
+	return hash.Sum(nil), nil
+}
@@ -0,0 +1,189 @@
+package gpcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestCertificate(t *testing.T, issuer *ecdsa.PrivateKey, issuerID, subjectID []byte, subject *ecdsa.PublicKey) *Certificate {
+	return newTestCertificateWithUsage(t, issuer, issuerID, subjectID, subject, KeyUsageKeyAgreement)
+}
+
+func newTestCertificateWithUsage(t *testing.T, issuer *ecdsa.PrivateKey, issuerID, subjectID []byte, subject *ecdsa.PublicKey, usage []byte) *Certificate {
+	c := &Certificate{
+		SerialNumber:      []byte{0x01, 0x02},
+		CAKLOCIdentifier:  issuerID,
+		SubjectIdentifier: subjectID,
+		KeyUsage:          usage,
+		EffectiveDate:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		ExpirationDate:    time.Date(2030, 12, 31, 0, 0, 0, 0, time.UTC),
+		PublicKey:         subject,
+	}
+	assert.NoError(t, c.Sign(issuer, rand.Reader))
+	return c
+}
+
+func TestParse(t *testing.T) {
+	issuer, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	subject, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	cert := newTestCertificate(t, issuer, []byte{0xCA}, []byte{0x5D}, &subject.PublicKey)
+	encoded, err := cert.ToBytes()
+	assert.NoError(t, err)
+	// An unknown tag before the signature must be kept in the signed data
+	extended := append([]byte{}, cert.RawTBS...)
+	extended = append(extended, 0x53, 0x01, 0xFF)
+	extendedCert := &Certificate{RawTBS: extended, Signature: cert.Signature}
+	unsigned, _ := bertlv.Object{Tag: bertlv.TagFromUintForced(tagCertificate), Value: cert.RawTBS}.ToBytes()
+	signed := *cert
+	signed.RawTBS = nil
+	sig, _ := bertlv.Object{Tag: bertlv.TagFromUintForced(tagSignature), Value: cert.Signature}.ToBytes()
+	trailing, _ := bertlv.Object{Tag: bertlv.TagFromUintForced(tagCertificate), Value: append(append(append([]byte{}, cert.RawTBS...), sig...), 0x53, 0x01, 0xFF)}.ToBytes()
+	tests := []struct {
+		name      string
+		data      []byte
+		want      *Certificate
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "round trip",
+			data:      encoded,
+			want:      cert,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "not a certificate",
+			data:      append([]byte{0x7F, 0x22}, encoded[2:]...),
+			assertion: assert.Error,
+		},
+		{
+			name:      "truncated",
+			data:      encoded[:len(encoded)-1],
+			assertion: assert.Error,
+		},
+		{
+			name:      "no signature",
+			data:      unsigned,
+			assertion: assert.Error,
+		},
+		{
+			name:      "field after the signature",
+			data:      trailing,
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.data)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+	t.Run("unknown field before the signature", func(t *testing.T) {
+		data, _ := bertlv.Object{Tag: bertlv.TagFromUintForced(tagCertificate), Value: append(append([]byte{}, extended...), sig...)}.ToBytes()
+		got, err := Parse(data)
+		if assert.NoError(t, err) {
+			assert.Equal(t, extended, got.RawTBS)
+		}
+	})
+	t.Run("signature covers raw content", func(t *testing.T) {
+		assert.NoError(t, signed.CheckSignature(&issuer.PublicKey))
+		assert.Error(t, extendedCert.CheckSignature(&issuer.PublicKey))
+		assert.Error(t, cert.CheckSignature(&subject.PublicKey))
+	})
+}
+
+func TestParseChain(t *testing.T) {
+	issuer, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	first := newTestCertificate(t, issuer, []byte{0x01}, []byte{0x02}, &issuer.PublicKey)
+	second := newTestCertificate(t, issuer, []byte{0x02}, []byte{0x03}, &issuer.PublicKey)
+	firstBytes, _ := first.ToBytes()
+	secondBytes, _ := second.ToBytes()
+	concatenated := append(append([]byte{}, firstBytes...), secondBytes...)
+	tests := []struct {
+		name      string
+		data      []byte
+		want      []*Certificate
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "concatenated",
+			data:      concatenated,
+			want:      []*Certificate{first, second},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "certificate store",
+			data:      append([]byte{0xBF, 0x21, 0x82, byte(len(concatenated) >> 8), byte(len(concatenated))}, concatenated...),
+			want:      []*Certificate{first, second},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "other object",
+			data:      append(append([]byte{}, firstBytes...), 0x93, 0x00),
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChain(tt.data)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_dateFromBCD(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        []byte
+		want      time.Time
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "valid",
+			in:        []byte{0x20, 0x24, 0x02, 0x29},
+			want:      time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "not BCD",
+			in:        []byte{0x20, 0x2A, 0x01, 0x01},
+			assertion: assert.Error,
+		},
+		{
+			name:      "month 13",
+			in:        []byte{0x20, 0x24, 0x13, 0x01},
+			assertion: assert.Error,
+		},
+		{
+			name:      "wrong length",
+			in:        []byte{0x20, 0x24, 0x01},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dateFromBCD(tt.in)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+			if err == nil {
+				assert.Equal(t, tt.in, dateToBCD(got))
+			}
+		})
+	}
+}
+
+func TestCertificate_HasKeyUsage(t *testing.T) {
+	c := &Certificate{KeyUsage: []byte{0x00, 0x82}}
+	assert.True(t, c.HasKeyUsage(KeyUsageSignatureVerification))
+	assert.True(t, c.HasKeyUsage([]byte{0x82}))
+	assert.False(t, c.HasKeyUsage(KeyUsageKeyAgreement))
+	assert.False(t, (&Certificate{}).HasKeyUsage(KeyUsageSignatureVerification))
+	assert.False(t, (&Certificate{}).HasKeyUsage(nil))
+}
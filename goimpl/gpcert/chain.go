@@ -0,0 +1,60 @@
+package gpcert
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"time"
+)
+
+// TrustAnchor is the root of trust a chain is validated against, such as PK.CA-KLOC.ECDSA for CERT.SD or PK.OCE.ECDSA for CERT.OCE
+type TrustAnchor struct {
+	Identifier []byte // Matched against the CA-KLOC identifier of the first certificate, not checked when nil
+	PublicKey  *ecdsa.PublicKey
+}
+
+// VerifyChain validates a chain ordered from the certificate issued by the trust anchor to the leaf, and returns the leaf public key.
+// Each certificate must be signed by the previous (or the anchor), name it as its issuer, and be valid at now.
+// Every certificate except the leaf issues the next, so it must have the signature verification key usage.
+func VerifyChain(chain []*Certificate, anchor TrustAnchor, now time.Time) (*ecdsa.PublicKey, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("empty certificate chain")
+	}
+	if anchor.PublicKey == nil {
+		return nil, fmt.Errorf("trust anchor has no public key")
+	}
+	issuerKey := anchor.PublicKey
+	issuerID := anchor.Identifier
+	for i, cert := range chain {
+		if cert == nil {
+			return nil, fmt.Errorf("certificate %d is nil", i)
+		}
+		if issuerID != nil && !bytes.Equal(issuerID, cert.CAKLOCIdentifier) {
+			return nil, fmt.Errorf("certificate %d names issuer %X, expected %X", i, cert.CAKLOCIdentifier, issuerID)
+		}
+		if err := cert.CheckSignature(issuerKey); err != nil {
+			return nil, fmt.Errorf("certificate %d: %w", i, err)
+		}
+		if err := cert.CheckValidity(now); err != nil {
+			return nil, fmt.Errorf("certificate %d: %w", i, err)
+		}
+		if i < len(chain)-1 && !cert.HasKeyUsage(KeyUsageSignatureVerification) {
+			return nil, fmt.Errorf("certificate %d has key usage %X and cannot issue certificates", i, cert.KeyUsage)
+		}
+		issuerKey = cert.PublicKey
+		issuerID = cert.SubjectIdentifier
+	}
+	return issuerKey, nil
+}
+
+// CheckValidity checks now is within the effective and expiration dates, both of which are inclusive whole days in UTC
+func (c *Certificate) CheckValidity(now time.Time) error {
+	now = now.UTC()
+	if !c.EffectiveDate.IsZero() && now.Before(c.EffectiveDate) {
+		return fmt.Errorf("not effective until %s", c.EffectiveDate.Format("2006-01-02"))
+	}
+	if !c.ExpirationDate.IsZero() && !now.Before(c.ExpirationDate.AddDate(0, 0, 1)) {
+		return fmt.Errorf("expired on %s", c.ExpirationDate.Format("2006-01-02"))
+	}
+	return nil
+}
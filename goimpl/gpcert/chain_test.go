@@ -0,0 +1,119 @@
+package gpcert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyChain(t *testing.T) {
+	root, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	intermediate, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	leaf, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	anchor := TrustAnchor{Identifier: []byte{0xCA}, PublicKey: &root.PublicKey}
+	first := newTestCertificateWithUsage(t, root, []byte{0xCA}, []byte{0x11}, &intermediate.PublicKey, KeyUsageSignatureVerification)
+	leafOnly := newTestCertificate(t, root, []byte{0xCA}, []byte{0x11}, &intermediate.PublicKey)
+	second := newTestCertificate(t, intermediate, []byte{0x11}, []byte{0x5D}, &leaf.PublicKey)
+	wrongIssuer := newTestCertificate(t, intermediate, []byte{0x12}, []byte{0x5D}, &leaf.PublicKey)
+	wrongKey := newTestCertificate(t, leaf, []byte{0x11}, []byte{0x5D}, &leaf.PublicKey)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		chain     []*Certificate
+		anchor    TrustAnchor
+		now       time.Time
+		want      *ecdsa.PublicKey
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "valid",
+			chain:     []*Certificate{first, second},
+			anchor:    anchor,
+			now:       now,
+			want:      &leaf.PublicKey,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "last day of validity",
+			chain:     []*Certificate{first, second},
+			anchor:    anchor,
+			now:       time.Date(2030, 12, 31, 23, 59, 0, 0, time.UTC),
+			want:      &leaf.PublicKey,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "expired",
+			chain:     []*Certificate{first, second},
+			anchor:    anchor,
+			now:       time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC),
+			assertion: assert.Error,
+		},
+		{
+			name:      "not yet effective",
+			chain:     []*Certificate{first, second},
+			anchor:    anchor,
+			now:       time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC),
+			assertion: assert.Error,
+		},
+		{
+			name:      "wrong anchor identifier",
+			chain:     []*Certificate{first, second},
+			anchor:    TrustAnchor{Identifier: []byte{0xCB}, PublicKey: &root.PublicKey},
+			now:       now,
+			assertion: assert.Error,
+		},
+		{
+			name:      "wrong anchor key",
+			chain:     []*Certificate{first, second},
+			anchor:    TrustAnchor{PublicKey: &intermediate.PublicKey},
+			now:       now,
+			assertion: assert.Error,
+		},
+		{
+			name:      "issuer mismatch",
+			chain:     []*Certificate{first, wrongIssuer},
+			anchor:    anchor,
+			now:       now,
+			assertion: assert.Error,
+		},
+		{
+			name:      "signed by wrong key",
+			chain:     []*Certificate{first, wrongKey},
+			anchor:    anchor,
+			now:       now,
+			assertion: assert.Error,
+		},
+		{
+			name:      "intermediate without signature verification usage",
+			chain:     []*Certificate{leafOnly, second},
+			anchor:    anchor,
+			now:       now,
+			assertion: assert.Error,
+		},
+		{
+			name:      "leaf only",
+			chain:     []*Certificate{leafOnly},
+			anchor:    anchor,
+			now:       now,
+			want:      &intermediate.PublicKey,
+			assertion: assert.NoError,
+		},
+		{
+			name:      "empty",
+			anchor:    anchor,
+			now:       now,
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyChain(tt.chain, tt.anchor, tt.now)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package gpcert
+
+import (
+	"fmt"
+	"time"
+)
+
+// dateToBCD encodes a date as 4 bytes of BCD, YYYYMMDD
+func dateToBCD(t time.Time) []byte {
+	t = t.UTC()
+	digits := fmt.Sprintf("%04d%02d%02d", t.Year(), int(t.Month()), t.Day())
+	out := make([]byte, 4)
+	for i := range out {
+		out[i] = (digits[2*i]-'0')<<4 | (digits[2*i+1] - '0')
+	}
+	return out
+}
+
+// dateFromBCD decodes a 4 byte YYYYMMDD BCD date, as the start of that day in UTC
+func dateFromBCD(in []byte) (time.Time, error) {
+	if len(in) != 4 {
+		return time.Time{}, fmt.Errorf("BCD date must be 4 bytes, got %d", len(in))
+	}
+	digits := make([]int, 8)
+	for i, b := range in {
+		high, low := int(b>>4), int(b&0x0F)
+		if high > 9 || low > 9 {
+			return time.Time{}, fmt.Errorf("invalid BCD date %X", in)
+		}
+		digits[2*i], digits[2*i+1] = high, low
+	}
+	year := digits[0]*1000 + digits[1]*100 + digits[2]*10 + digits[3]
+	month := digits[4]*10 + digits[5]
+	day := digits[6]*10 + digits[7]
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, fmt.Errorf("invalid BCD date %X", in)
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), nil
+}
@@ -0,0 +1,2 @@
+// Package gpcert handles GlobalPlatform format BER-TLV certificates (tag 7F21), as used for CERT.SD and CERT.OCE in SCP11
+package gpcert
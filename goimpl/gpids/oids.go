@@ -25,3 +25,21 @@ func BaseOID() asn1.ObjectIdentifier {
 func CardRecognitionDataOID() asn1.ObjectIdentifier {
 	return append(BaseOID(), 1)
 }
+
+// CardManagementTypeAndVersionOID is the OID prefix of the card management type and version in Card Recognition Data, followed by the version -
+// {globalPlatform 2}
+func CardManagementTypeAndVersionOID() asn1.ObjectIdentifier {
+	return append(BaseOID(), 2)
+}
+
+// CardIdentificationSchemeOID is the OID of the GP card identification scheme in Card Recognition Data -
+// {globalPlatform 3}
+func CardIdentificationSchemeOID() asn1.ObjectIdentifier {
+	return append(BaseOID(), 3)
+}
+
+// SecureChannelProtocolOID is the OID prefix of a secure channel protocol in Card Recognition Data, followed by the protocol and its "i" parameter -
+// {globalPlatform 4}
+func SecureChannelProtocolOID() asn1.ObjectIdentifier {
+	return append(BaseOID(), 4)
+}
@@ -0,0 +1,2 @@
+// Package keydiv implements static key diversification, deriving a card's ENC, MAC and DEK keys from a master key set and the key diversification data returned by INITIALIZE UPDATE
+package keydiv
@@ -0,0 +1,123 @@
+package keydiv
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+// Keys is a set of static keys, convertible to and from the Keys of each secure channel protocol
+type Keys struct {
+	ENC keystore.Key
+	MAC keystore.Key
+	DEK keystore.Key
+}
+
+// KeyType identifies which of the static keys is being diversified
+type KeyType byte
+
+const (
+	// KeyTypeENC is the static encryption key
+	KeyTypeENC KeyType = 0x01
+	// KeyTypeMAC is the static MAC key
+	KeyTypeMAC KeyType = 0x02
+	// KeyTypeDEK is the static data encryption key
+	KeyTypeDEK KeyType = 0x03
+)
+
+// Method is a key diversification method
+type Method interface {
+	// DiversifyKey derives the card's key of the given type from the master key and the key diversification data
+	DiversifyKey(master keystore.Key, keyType KeyType, kdd [10]byte) (keystore.Key, error)
+}
+
+// Diversify derives the card's static key set from a master key set
+func Diversify(method Method, master Keys, kdd [10]byte) (keys Keys, err error) {
+	if method == nil {
+		err = fmt.Errorf("nil diversification method")
+		return
+	}
+	if keys.ENC, err = method.DiversifyKey(master.ENC, KeyTypeENC, kdd); err != nil {
+		err = fmt.Errorf("diversifying ENC: %w", err)
+		return
+	}
+	if keys.MAC, err = method.DiversifyKey(master.MAC, KeyTypeMAC, kdd); err != nil {
+		err = fmt.Errorf("diversifying MAC: %w", err)
+		return
+	}
+	if keys.DEK, err = method.DiversifyKey(master.DEK, KeyTypeDEK, kdd); err != nil {
+		err = fmt.Errorf("diversifying DEK: %w", err)
+	}
+	return
+}
+
+// EMVCPS11 is the EMV CPS 1.1 method, which enciphers the last 6 bytes of the diversification data with a triple DES master key
+type EMVCPS11 struct{}
+
+// DiversifyKey derives a 16 byte triple DES key
+func (EMVCPS11) DiversifyKey(master keystore.Key, keyType KeyType, kdd [10]byte) (keystore.Key, error) {
+	data := make([]byte, 0, 16)
+	data = append(data, kdd[4:10]...)
+	data = append(data, 0xF0, byte(keyType))
+	data = append(data, kdd[4:10]...)
+	data = append(data, 0x0F, byte(keyType))
+	return deriveTripleDES(master, data)
+}
+
+// VISA2 is the VISA2 method, which enciphers bytes 0-1 and 4-7 of the diversification data with a triple DES master key
+type VISA2 struct{}
+
+// DiversifyKey derives a 16 byte triple DES key
+func (VISA2) DiversifyKey(master keystore.Key, keyType KeyType, kdd [10]byte) (keystore.Key, error) {
+	data := make([]byte, 0, 16)
+	data = append(data, kdd[0:2]...)
+	data = append(data, kdd[4:8]...)
+	data = append(data, 0xF0, byte(keyType))
+	data = append(data, kdd[0:2]...)
+	data = append(data, kdd[4:8]...)
+	data = append(data, 0x0F, byte(keyType))
+	return deriveTripleDES(master, data)
+}
+
+// KDF3 is the SCP03 style method, which runs the SP800-108 counter mode KDF with AES-CMAC over the diversification data.
+// The input is the 11 byte zero label followed by the key type, a zero separator, L, the counter and the diversification data as context, as in the SCP03 session key derivation.
+// The derived key is the same length as the AES master key.
+type KDF3 struct{}
+
+// DiversifyKey derives an AES key of the same length as the master key
+func (KDF3) DiversifyKey(master keystore.Key, keyType KeyType, kdd [10]byte) (keystore.Key, error) {
+	if master == nil {
+		return nil, fmt.Errorf("nil master key")
+	}
+	if master.Algorithm() != keystore.AlgorithmAES {
+		return nil, fmt.Errorf("KDF3 requires an AES master key, not %s", master.Algorithm())
+	}
+	bits := master.Length() * 8
+	prefix := append(make([]byte, 11), byte(keyType), 0x00, byte(bits>>8), byte(bits))
+	return master.Derive(keystore.Derivation{
+		Method:    keystore.DeriveCounterCMAC,
+		Algorithm: keystore.AlgorithmAES,
+		Length:    master.Length(),
+		Prefix:    prefix,
+		Suffix:    kdd[:],
+		Static:    true,
+	})
+}
+
+// deriveTripleDES enciphers the 16 byte derivation data with a triple DES master key in ECB mode to produce a two-key triple DES key
+func deriveTripleDES(master keystore.Key, data []byte) (keystore.Key, error) {
+	if master == nil {
+		return nil, fmt.Errorf("nil master key")
+	}
+	if master.Algorithm() != keystore.AlgorithmTripleDES {
+		return nil, fmt.Errorf("triple DES master key required, not %s", master.Algorithm())
+	}
+	return master.Derive(keystore.Derivation{
+		Method:    keystore.DeriveEncrypt,
+		Algorithm: keystore.AlgorithmTripleDES,
+		Length:    16,
+		Mode:      keystore.ModeECB,
+		Data:      data,
+		Static:    true,
+	})
+}
@@ -0,0 +1,150 @@
+package keydiv
+
+import (
+	"bytes"
+	"crypto/des"
+	"encoding/hex"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+	"github.com/llkennedy/globalplatform/goimpl/nist/sp800108"
+	"github.com/stretchr/testify/assert"
+)
+
+// encryptTestBlock computes the expected diversified key in the clear, returning it as a software key
+func encryptTestBlock(t *testing.T, key, data []byte) keystore.Key {
+	block, err := des.NewTripleDESCipher(append(append([]byte{}, key...), key[:8]...))
+	assert.NoError(t, err)
+	out := make([]byte, 16)
+	block.Encrypt(out[:8], data[:8])
+	block.Encrypt(out[8:], data[8:])
+	return softwareKey(t, keystore.AlgorithmTripleDES, out)
+}
+
+func softwareKey(t *testing.T, algorithm keystore.Algorithm, material []byte) keystore.Key {
+	key, err := keystore.NewSoftwareKey(algorithm, material)
+	assert.NoError(t, err)
+	return key
+}
+
+func TestDiversify(t *testing.T) {
+	raw := [][]byte{bytes.Repeat([]byte{0x40}, 16), bytes.Repeat([]byte{0x41}, 16), bytes.Repeat([]byte{0x42}, 16)}
+	master := Keys{
+		ENC: softwareKey(t, keystore.AlgorithmTripleDES, raw[0]),
+		MAC: softwareKey(t, keystore.AlgorithmTripleDES, raw[1]),
+		DEK: softwareKey(t, keystore.AlgorithmTripleDES, raw[2]),
+	}
+	kdd := [10]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}
+	tests := []struct {
+		name      string
+		method    Method
+		master    Keys
+		want      Keys
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:   "EMV CPS 1.1",
+			method: EMVCPS11{},
+			master: master,
+			want: Keys{
+				ENC: encryptTestBlock(t, raw[0], []byte{0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0xF0, 0x01, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0F, 0x01}),
+				MAC: encryptTestBlock(t, raw[1], []byte{0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0xF0, 0x02, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0F, 0x02}),
+				DEK: encryptTestBlock(t, raw[2], []byte{0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0xF0, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0F, 0x03}),
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:   "VISA2",
+			method: VISA2{},
+			master: master,
+			want: Keys{
+				ENC: encryptTestBlock(t, raw[0], []byte{0x00, 0x01, 0x04, 0x05, 0x06, 0x07, 0xF0, 0x01, 0x00, 0x01, 0x04, 0x05, 0x06, 0x07, 0x0F, 0x01}),
+				MAC: encryptTestBlock(t, raw[1], []byte{0x00, 0x01, 0x04, 0x05, 0x06, 0x07, 0xF0, 0x02, 0x00, 0x01, 0x04, 0x05, 0x06, 0x07, 0x0F, 0x02}),
+				DEK: encryptTestBlock(t, raw[2], []byte{0x00, 0x01, 0x04, 0x05, 0x06, 0x07, 0xF0, 0x03, 0x00, 0x01, 0x04, 0x05, 0x06, 0x07, 0x0F, 0x03}),
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "triple DES with AES key",
+			method:    EMVCPS11{},
+			master:    Keys{ENC: softwareKey(t, keystore.AlgorithmAES, raw[0]), MAC: master.MAC, DEK: master.DEK},
+			assertion: assert.Error,
+		},
+		{
+			name:      "KDF3 with triple DES key",
+			method:    KDF3{},
+			master:    master,
+			assertion: assert.Error,
+		},
+		{
+			name:      "nil method",
+			master:    master,
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diversify(tt.method, tt.master, kdd)
+			tt.assertion(t, err)
+			if err == nil {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+// The expected KDF3 keys are computed outside Go with the OpenSSL CMAC over each SP800-108 input block of the SCP03 data derivation scheme (GPC Amendment D), e.g. for AES-128 ENC
+//
+//	echo 0000000000000000000000010000800100010203040506070809 | xxd -r -p | openssl mac -cipher AES-128-CBC -macopt hexkey:404142434445464748494A4B4C4D4E4F CMAC
+//
+// and are cross-checked against the NIST SP800-108 counter mode KDF in TestKDF3_DiversifyKey_sp800108.
+func TestKDF3_DiversifyKey(t *testing.T) {
+	kdd := [10]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}
+	aes128 := mustHex("404142434445464748494A4B4C4D4E4F")
+	aes256 := mustHex("404142434445464748494A4B4C4D4E4F505152535455565758595A5B5C5D5E5F")
+	tests := []struct {
+		name    string
+		master  []byte
+		keyType KeyType
+		want    []byte
+	}{
+		{name: "AES-128 ENC", master: aes128, keyType: KeyTypeENC, want: mustHex("B3F55592228C010235B8E327D08A0EB0")},
+		{name: "AES-128 MAC", master: aes128, keyType: KeyTypeMAC, want: mustHex("DC274E156E389708D6E4D8DC7FE9C820")},
+		{name: "AES-128 DEK", master: aes128, keyType: KeyTypeDEK, want: mustHex("F574316B3478D0D5587CB0345E97396A")},
+		{name: "AES-256 ENC", master: aes256, keyType: KeyTypeENC, want: mustHex("B20B7AD8CF70E75300200E535A21B47925C2236A56BCEB8A35121B0ECE6F7C50")},
+		{name: "AES-256 MAC", master: aes256, keyType: KeyTypeMAC, want: mustHex("EF86BF5CC416FFE36AA62A8542E0DAB49FB6D45847D8C5B77A0C37C3E77A1A91")},
+		{name: "AES-256 DEK", master: aes256, keyType: KeyTypeDEK, want: mustHex("9A1DEECE0AEE74A01249EFE022CDBEC60A05F96E73BAA5909E2154B588ABE897")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := KDF3{}.DiversifyKey(softwareKey(t, keystore.AlgorithmAES, tt.master), tt.keyType, kdd)
+			assert.NoError(t, err)
+			assert.Equal(t, softwareKey(t, keystore.AlgorithmAES, tt.want), got)
+		})
+	}
+}
+
+func TestKDF3_DiversifyKey_sp800108(t *testing.T) {
+	kdd := [10]byte{0x9F, 0x21, 0x00, 0x5A, 0x3C, 0x77, 0x10, 0xE2, 0x41, 0x08}
+	ordering := []sp800108.InputStringOrdering{sp800108.InputOrderLabel, sp800108.InputOrderEmptySeparator, sp800108.InputOrderL, sp800108.InputOrderCounter, sp800108.InputOrderContext}
+	for _, length := range []int{16, 24, 32} {
+		master := bytes.Repeat([]byte{0xC3}, length)
+		for _, keyType := range []KeyType{KeyTypeENC, KeyTypeMAC, KeyTypeDEK} {
+			label := append(make([]byte, 11), byte(keyType))
+			bits := length * 8
+			want, err := (&sp800108.CounterKBKDF{}).Derive(&sp800108.PRFCMAC{}, sp800108.CounterLength8, master, label, kdd[:], []byte{byte(bits >> 8), byte(bits)}, ordering)
+			assert.NoError(t, err)
+			got, err := KDF3{}.DiversifyKey(softwareKey(t, keystore.AlgorithmAES, master), keyType, kdd)
+			assert.NoError(t, err)
+			assert.Equal(t, softwareKey(t, keystore.AlgorithmAES, want), got, "AES-%d key type %d", bits, keyType)
+		}
+	}
+}
+
+func mustHex(in string) []byte {
+	out, err := hex.DecodeString(in)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
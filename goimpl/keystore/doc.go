@@ -0,0 +1,2 @@
+// Package keystore abstracts symmetric keys behind handles, so that secure channel code can use keys held in software, an HSM or a PKCS#11 token without ever seeing the key material. FileStore persists software keys to a passphrase-encrypted file.
+package keystore
@@ -0,0 +1,224 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// File store format, all integers big-endian:
+//
+//	magic "GPKS" | version (1) | PBKDF2 iterations (4) | salt (16) | nonce (12) | AES-256-GCM ciphertext and tag
+//
+// The header up to and including the nonce is authenticated as additional data. The plaintext is a sequence of
+// label length (1) | label | algorithm (1) | key length (1) | key.
+var fileStoreMagic = []byte("GPKS")
+
+const (
+	fileStoreVersion = 1
+	fileStoreSalt    = 16
+	fileStoreNonce   = 12
+	fileStoreHeader  = 4 + 1 + 4 + fileStoreSalt + fileStoreNonce
+	// DefaultFileStoreIterations is the PBKDF2-HMAC-SHA256 iteration count used when saving a FileStore
+	DefaultFileStoreIterations = 200000
+	maxFileStoreIterations     = 10000000
+)
+
+type fileEntry struct {
+	algorithm Algorithm
+	material  []byte
+}
+
+// FileStore is a Store of software keys which is persisted to a passphrase-encrypted file. The keys are decrypted into process memory while the store is open.
+type FileStore struct {
+	mutex   sync.RWMutex
+	entries map[string]fileEntry
+	keys    map[string]Key
+	// Iterations is the PBKDF2 iteration count used by Write, DefaultFileStoreIterations if zero
+	Iterations int
+}
+
+// NewFileStore creates an empty FileStore
+func NewFileStore() *FileStore {
+	return &FileStore{entries: map[string]fileEntry{}, keys: map[string]Key{}}
+}
+
+// Key returns the key with the label
+func (s *FileStore) Key(label string) (Key, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	key, ok := s.keys[label]
+	if !ok {
+		return nil, fmt.Errorf("no key with label %q", label)
+	}
+	return key, nil
+}
+
+// Import creates a software key from the key material and adds it with the label, replacing any existing key
+func (s *FileStore) Import(label string, algorithm Algorithm, material []byte) (Key, error) {
+	if len(label) == 0 || len(label) > 255 {
+		return nil, fmt.Errorf("label must be 1 to 255 bytes, got %d", len(label))
+	}
+	key, err := NewSoftwareKey(algorithm, material)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.entries[label] = fileEntry{algorithm: algorithm, material: append([]byte{}, material...)}
+	s.keys[label] = key
+	return key, nil
+}
+
+// Delete removes the key with the label
+func (s *FileStore) Delete(label string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.entries, label)
+	delete(s.keys, label)
+}
+
+// Write encrypts the store under the passphrase and writes it
+func (s *FileStore) Write(w io.Writer, passphrase []byte) error {
+	if len(passphrase) == 0 {
+		return fmt.Errorf("passphrase must not be empty")
+	}
+	iterations := s.Iterations
+	if iterations == 0 {
+		iterations = DefaultFileStoreIterations
+	}
+	if iterations < 0 || iterations > maxFileStoreIterations {
+		return fmt.Errorf("invalid iteration count %d", iterations)
+	}
+	s.mutex.RLock()
+	plaintext := &bytes.Buffer{}
+	for label, entry := range s.entries {
+		plaintext.WriteByte(byte(len(label)))
+		plaintext.WriteString(label)
+		plaintext.WriteByte(byte(entry.algorithm))
+		plaintext.WriteByte(byte(len(entry.material)))
+		plaintext.Write(entry.material)
+	}
+	s.mutex.RUnlock()
+	header := make([]byte, fileStoreHeader)
+	copy(header, fileStoreMagic)
+	header[4] = fileStoreVersion
+	binary.BigEndian.PutUint32(header[5:9], uint32(iterations))
+	if _, err := io.ReadFull(rand.Reader, header[9:]); err != nil {
+		return fmt.Errorf("generating salt and nonce: %w", err)
+	}
+	salt, nonce := header[9:9+fileStoreSalt], header[9+fileStoreSalt:]
+	aead, err := fileStoreAEAD(passphrase, salt, iterations)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(aead.Seal(header, nonce, plaintext.Bytes(), header))
+	return err
+}
+
+// SaveFile encrypts the store under the passphrase and writes it to the path, readable only by the owner
+func (s *FileStore) SaveFile(path string, passphrase []byte) error {
+	buf := &bytes.Buffer{}
+	if err := s.Write(buf, passphrase); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// ReadFileStore reads and decrypts a FileStore
+func ReadFileStore(r io.Reader, passphrase []byte) (*FileStore, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < fileStoreHeader || !bytes.Equal(data[:4], fileStoreMagic) {
+		return nil, fmt.Errorf("not a key store file")
+	}
+	if data[4] != fileStoreVersion {
+		return nil, fmt.Errorf("unsupported key store file version %d", data[4])
+	}
+	iterations := int(binary.BigEndian.Uint32(data[5:9]))
+	if iterations == 0 || iterations > maxFileStoreIterations {
+		return nil, fmt.Errorf("invalid iteration count %d", iterations)
+	}
+	header := data[:fileStoreHeader]
+	salt, nonce := header[9:9+fileStoreSalt], header[9+fileStoreSalt:]
+	aead, err := fileStoreAEAD(passphrase, salt, iterations)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, nonce, data[fileStoreHeader:], header)
+	if err != nil {
+		return nil, fmt.Errorf("wrong passphrase or corrupted key store file")
+	}
+	s := NewFileStore()
+	s.Iterations = iterations
+	for len(plaintext) > 0 {
+		labelLength := int(plaintext[0])
+		if len(plaintext) < 1+labelLength+2 {
+			return nil, fmt.Errorf("truncated key store entry")
+		}
+		label := string(plaintext[1 : 1+labelLength])
+		plaintext = plaintext[1+labelLength:]
+		algorithm, keyLength := Algorithm(plaintext[0]), int(plaintext[1])
+		if len(plaintext) < 2+keyLength {
+			return nil, fmt.Errorf("truncated key %q", label)
+		}
+		if _, err = s.Import(label, algorithm, plaintext[2:2+keyLength]); err != nil {
+			return nil, fmt.Errorf("key %q: %w", label, err)
+		}
+		plaintext = plaintext[2+keyLength:]
+	}
+	return s, nil
+}
+
+// OpenFileStore reads and decrypts a FileStore from the path
+func OpenFileStore(path string, passphrase []byte) (*FileStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadFileStore(f, passphrase)
+}
+
+func fileStoreAEAD(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2SHA256(passphrase, salt, iterations, 32))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// pbkdf2SHA256 is PBKDF2 (RFC 8018) with HMAC-SHA256 as the PRF
+func pbkdf2SHA256(password, salt []byte, iterations, length int) []byte {
+	prf := hmac.New(sha256.New, password)
+	out := make([]byte, 0, length+prf.Size())
+	u := make([]byte, prf.Size())
+	for block := uint32(1); len(out) < length; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u = prf.Sum(u[:0])
+		t := append([]byte{}, u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		out = append(out, t...)
+	}
+	return out[:length]
+}
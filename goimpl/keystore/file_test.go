@@ -0,0 +1,90 @@
+package keystore
+
+import (
+	"bytes"
+	"encoding/hex"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPBKDF2SHA256(t *testing.T) {
+	// RFC 7914 section 11
+	expected, _ := hex.DecodeString("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783")
+	assert.Equal(t, expected, pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64))
+	expected, _ = hex.DecodeString("4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d")
+	assert.Equal(t, expected, pbkdf2SHA256([]byte("Password"), []byte("NaCl"), 80000, 64))
+}
+
+func TestFileStore(t *testing.T) {
+	passphrase := []byte("correct horse battery staple")
+	s := NewFileStore()
+	s.Iterations = 1000
+	enc, err := s.Import("enc", AlgorithmAES, bytes.Repeat([]byte{0x40}, 16))
+	assert.NoError(t, err)
+	mac, err := s.Import("mac", AlgorithmTripleDES, bytes.Repeat([]byte{0x41}, 16))
+	assert.NoError(t, err)
+	_, err = s.Import("bad", AlgorithmDES, make([]byte, 16))
+	assert.Error(t, err)
+	_, err = s.Import("", AlgorithmAES, make([]byte, 16))
+	assert.Error(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, s.Write(buf, passphrase))
+	assert.NotContains(t, buf.String(), string(bytes.Repeat([]byte{0x40}, 16)))
+	encrypted := buf.Bytes()
+
+	t.Run("round trip", func(t *testing.T) {
+		read, err := ReadFileStore(bytes.NewReader(encrypted), passphrase)
+		assert.NoError(t, err)
+		got, err := read.Key("enc")
+		assert.NoError(t, err)
+		assert.Equal(t, enc, got)
+		got, err = read.Key("mac")
+		assert.NoError(t, err)
+		assert.Equal(t, mac, got)
+		_, err = read.Key("dek")
+		assert.Error(t, err)
+		assert.Equal(t, 1000, read.Iterations)
+	})
+	t.Run("wrong passphrase", func(t *testing.T) {
+		read, err := ReadFileStore(bytes.NewReader(encrypted), []byte("Tr0ub4dor&3"))
+		assert.Error(t, err)
+		assert.Nil(t, read)
+	})
+	t.Run("tampered header", func(t *testing.T) {
+		tampered := append([]byte{}, encrypted...)
+		tampered[9] ^= 0x01
+		_, err := ReadFileStore(bytes.NewReader(tampered), passphrase)
+		assert.Error(t, err)
+	})
+	t.Run("tampered ciphertext", func(t *testing.T) {
+		tampered := append([]byte{}, encrypted...)
+		tampered[len(tampered)-1] ^= 0x01
+		_, err := ReadFileStore(bytes.NewReader(tampered), passphrase)
+		assert.Error(t, err)
+	})
+	t.Run("not a key store", func(t *testing.T) {
+		_, err := ReadFileStore(bytes.NewReader([]byte("GPKS")), passphrase)
+		assert.Error(t, err)
+		_, err = ReadFileStore(bytes.NewReader(make([]byte, 64)), passphrase)
+		assert.Error(t, err)
+	})
+	t.Run("empty passphrase", func(t *testing.T) {
+		assert.Error(t, s.Write(&bytes.Buffer{}, nil))
+	})
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.gpks")
+		s.Delete("mac")
+		assert.NoError(t, s.SaveFile(path, passphrase))
+		read, err := OpenFileStore(path, passphrase)
+		assert.NoError(t, err)
+		_, err = read.Key("enc")
+		assert.NoError(t, err)
+		_, err = read.Key("mac")
+		assert.Error(t, err)
+		_, err = OpenFileStore(path, []byte("wrong"))
+		assert.Error(t, err)
+	})
+}
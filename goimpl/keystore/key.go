@@ -0,0 +1,110 @@
+package keystore
+
+import "fmt"
+
+// Algorithm is the block cipher a key is used with
+type Algorithm byte
+
+const (
+	// AlgorithmAES is AES with a 16, 24 or 32 byte key
+	AlgorithmAES Algorithm = iota + 1
+	// AlgorithmTripleDES is two or three key triple DES with a 16 or 24 byte key
+	AlgorithmTripleDES
+	// AlgorithmDES is single DES with an 8 byte key
+	AlgorithmDES
+)
+
+// String returns the name of the algorithm
+func (a Algorithm) String() string {
+	switch a {
+	case AlgorithmAES:
+		return "AES"
+	case AlgorithmTripleDES:
+		return "3DES"
+	case AlgorithmDES:
+		return "DES"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", byte(a))
+	}
+}
+
+// ValidateLength checks a key length in bytes is valid for the algorithm
+func (a Algorithm) ValidateLength(length int) error {
+	switch {
+	case a == AlgorithmAES && (length == 16 || length == 24 || length == 32),
+		a == AlgorithmTripleDES && (length == 16 || length == 24),
+		a == AlgorithmDES && length == 8:
+		return nil
+	case a < AlgorithmAES || a > AlgorithmDES:
+		return fmt.Errorf("unknown algorithm %s", a)
+	default:
+		return fmt.Errorf("invalid %s key length %d", a, length)
+	}
+}
+
+// BlockSize returns the cipher block size in bytes
+func (a Algorithm) BlockSize() int {
+	if a == AlgorithmAES {
+		return 16
+	}
+	return 8
+}
+
+// Mode is a block cipher mode of operation
+type Mode byte
+
+const (
+	// ModeECB is electronic codebook mode, the ICV is ignored
+	ModeECB Mode = iota
+	// ModeCBC is cipher block chaining mode, a nil ICV is all zeroes
+	ModeCBC
+)
+
+// DerivationMethod is a way of deriving a new key from an existing one
+type DerivationMethod byte
+
+const (
+	// DeriveEncrypt uses the leading bytes of the encryption of Data with Mode and ICV as the new key
+	DeriveEncrypt DerivationMethod = iota
+	// DeriveCounterCMAC uses the NIST SP800-108 KDF in counter mode with AES-CMAC as the PRF, with an 8 bit counter between Prefix and Suffix
+	DeriveCounterCMAC
+)
+
+// Derivation describes how to derive a new key
+type Derivation struct {
+	Method    DerivationMethod
+	Algorithm Algorithm // Algorithm of the derived key
+	Length    int       // Length of the derived key in bytes
+	// Mode, ICV and Data are the encryption parameters of DeriveEncrypt
+	Mode Mode
+	ICV  []byte
+	Data []byte
+	// Prefix and Suffix are the fixed input data either side of the counter for DeriveCounterCMAC
+	Prefix, Suffix []byte
+	// Static marks the derived key as a long-term key, such as a diversified card key, rather than a session key. Stores which cannot derive it without exposing its value refuse static derivations.
+	Static bool
+}
+
+// Key is a handle to a symmetric key. Implementations need not have access to the key material, and must be safe for concurrent use.
+type Key interface {
+	// Algorithm returns the algorithm the key is used with
+	Algorithm() Algorithm
+	// Length returns the length of the key in bytes
+	Length() int
+	// Encrypt enciphers block-aligned data
+	Encrypt(mode Mode, icv, data []byte) ([]byte, error)
+	// Decrypt deciphers block-aligned data
+	Decrypt(mode Mode, icv, data []byte) ([]byte, error)
+	// CMAC computes the full block length CMAC of the data
+	CMAC(data []byte) ([]byte, error)
+	// Derive creates a new key from this one, in the same key store
+	Derive(d Derivation) (Key, error)
+	// Unwrap deciphers a key of the given algorithm which was enciphered with this key, and imports it into the same key store
+	Unwrap(mode Mode, icv, wrapped []byte, algorithm Algorithm) (Key, error)
+}
+
+// Store is a collection of named keys
+type Store interface {
+	// Key returns a handle to the key with the label
+	Key(label string) (Key, error)
+}
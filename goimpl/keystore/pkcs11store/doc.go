@@ -0,0 +1,11 @@
+// Package pkcs11store implements keystore.Key with secret keys held in a PKCS#11 token, such as an HSM or SoftHSM.
+//
+// Encryption, CMAC, key unwrapping and encryption based key derivation (SCP02 session keys, EMV CPS 1.1 and VISA2 diversification) all run inside the token, and every key created by a Key is a non-extractable session object.
+// Counter mode CMAC derivation (SCP03 session keys and KDF3 diversification) uses CKM_SP800_108_COUNTER_KDF from PKCS#11 3.0 when the token supports it.
+// Older tokens, including SoftHSM 2.6, have no SP800-108 mechanism, so each CMAC block is computed inside the token and the derived key is assembled in process memory and imported back as a session object.
+// This exposes the derived key, so on those tokens it is only done for SCP03 session keys and KDF3 diversification of static keys is refused with an error.
+//
+// The tests need a token and are skipped unless PKCS11_MODULE, PKCS11_TOKEN_LABEL and PKCS11_PIN are set. Run them against a throwaway SoftHSM token with
+//
+//	make -C goimpl test-softhsm
+package pkcs11store
@@ -0,0 +1,288 @@
+package pkcs11store
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+	"github.com/miekg/pkcs11"
+)
+
+// Key is a handle to a secret key object in a PKCS#11 token
+type Key struct {
+	store     *Store
+	object    pkcs11.ObjectHandle
+	algorithm keystore.Algorithm
+	length    int
+}
+
+// Algorithm returns the algorithm the key is used with
+func (k *Key) Algorithm() keystore.Algorithm {
+	return k.algorithm
+}
+
+// Length returns the length of the key in bytes
+func (k *Key) Length() int {
+	return k.length
+}
+
+// cipherMechanism gets the ECB or CBC mechanism for the key, with the ICV as the CBC parameter
+func (k *Key) cipherMechanism(mode keystore.Mode, icv []byte) (*pkcs11.Mechanism, error) {
+	blockSize := k.algorithm.BlockSize()
+	var ecb, cbc uint
+	switch k.algorithm {
+	case keystore.AlgorithmAES:
+		ecb, cbc = pkcs11.CKM_AES_ECB, pkcs11.CKM_AES_CBC
+	case keystore.AlgorithmTripleDES:
+		ecb, cbc = pkcs11.CKM_DES3_ECB, pkcs11.CKM_DES3_CBC
+	case keystore.AlgorithmDES:
+		ecb, cbc = pkcs11.CKM_DES_ECB, pkcs11.CKM_DES_CBC
+	default:
+		return nil, fmt.Errorf("unknown algorithm %s", k.algorithm)
+	}
+	switch mode {
+	case keystore.ModeECB:
+		return pkcs11.NewMechanism(ecb, nil), nil
+	case keystore.ModeCBC:
+		if icv == nil {
+			icv = make([]byte, blockSize)
+		}
+		if len(icv) != blockSize {
+			return nil, fmt.Errorf("ICV must be %d bytes, got %d", blockSize, len(icv))
+		}
+		return pkcs11.NewMechanism(cbc, append([]byte{}, icv...)), nil
+	default:
+		return nil, fmt.Errorf("unknown mode %d", mode)
+	}
+}
+
+func (k *Key) checkData(data []byte) error {
+	if len(data) == 0 || len(data)%k.algorithm.BlockSize() != 0 {
+		return fmt.Errorf("data length %d is not a non-zero multiple of the block size", len(data))
+	}
+	return nil
+}
+
+// Encrypt enciphers block-aligned data in the token
+func (k *Key) Encrypt(mode keystore.Mode, icv, data []byte) ([]byte, error) {
+	if err := k.checkData(data); err != nil {
+		return nil, err
+	}
+	mech, err := k.cipherMechanism(mode, icv)
+	if err != nil {
+		return nil, err
+	}
+	s := k.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	if err = s.ctx.EncryptInit(s.session, []*pkcs11.Mechanism{mech}, k.object); err != nil {
+		return nil, fmt.Errorf("starting encryption: %w", err)
+	}
+	out, err := s.ctx.Encrypt(s.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting: %w", err)
+	}
+	return out, nil
+}
+
+// Decrypt deciphers block-aligned data in the token
+func (k *Key) Decrypt(mode keystore.Mode, icv, data []byte) ([]byte, error) {
+	if err := k.checkData(data); err != nil {
+		return nil, err
+	}
+	mech, err := k.cipherMechanism(mode, icv)
+	if err != nil {
+		return nil, err
+	}
+	s := k.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	if err = s.ctx.DecryptInit(s.session, []*pkcs11.Mechanism{mech}, k.object); err != nil {
+		return nil, fmt.Errorf("starting decryption: %w", err)
+	}
+	out, err := s.ctx.Decrypt(s.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting: %w", err)
+	}
+	return out, nil
+}
+
+// CMAC computes the full block length CMAC in the token
+func (k *Key) CMAC(data []byte) ([]byte, error) {
+	var mech uint
+	switch k.algorithm {
+	case keystore.AlgorithmAES:
+		mech = pkcs11.CKM_AES_CMAC
+	case keystore.AlgorithmTripleDES:
+		mech = pkcs11.CKM_DES3_CMAC
+	default:
+		return nil, fmt.Errorf("CMAC is not supported for %s keys", k.algorithm)
+	}
+	s := k.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return k.cmacLocked(mech, data)
+}
+
+// cmacLocked computes a CMAC, the caller must hold the mutex
+func (k *Key) cmacLocked(mech uint, data []byte) ([]byte, error) {
+	s := k.store
+	if s.ctx == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	if err := s.ctx.SignInit(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mech, nil)}, k.object); err != nil {
+		return nil, fmt.Errorf("starting CMAC: %w", err)
+	}
+	out, err := s.ctx.Sign(s.session, data)
+	if err != nil {
+		return nil, fmt.Errorf("computing CMAC: %w", err)
+	}
+	return out, nil
+}
+
+// Derive creates a new non-extractable session key from this one
+func (k *Key) Derive(d keystore.Derivation) (keystore.Key, error) {
+	if err := d.Algorithm.ValidateLength(d.Length); err != nil {
+		return nil, fmt.Errorf("invalid derived key: %w", err)
+	}
+	switch d.Method {
+	case keystore.DeriveEncrypt:
+		return k.deriveEncrypt(d)
+	case keystore.DeriveCounterCMAC:
+		return k.deriveCounterCMAC(d)
+	default:
+		return nil, fmt.Errorf("unknown derivation method %d", d.Method)
+	}
+}
+
+// deriveEncrypt derives with the ECB or CBC ENCRYPT_DATA mechanisms, which use the leading bytes of the ciphertext as the new key
+func (k *Key) deriveEncrypt(d keystore.Derivation) (keystore.Key, error) {
+	if err := k.checkData(d.Data); err != nil {
+		return nil, err
+	}
+	var mech uint
+	icv := d.ICV
+	switch {
+	case k.algorithm == keystore.AlgorithmAES && d.Mode == keystore.ModeECB:
+		mech = pkcs11.CKM_AES_ECB_ENCRYPT_DATA
+	case k.algorithm == keystore.AlgorithmAES && d.Mode == keystore.ModeCBC:
+		mech = pkcs11.CKM_AES_CBC_ENCRYPT_DATA
+	case k.algorithm == keystore.AlgorithmTripleDES && d.Mode == keystore.ModeECB:
+		mech = pkcs11.CKM_DES3_ECB_ENCRYPT_DATA
+	case k.algorithm == keystore.AlgorithmTripleDES && d.Mode == keystore.ModeCBC:
+		mech = pkcs11.CKM_DES3_CBC_ENCRYPT_DATA
+	case k.algorithm == keystore.AlgorithmDES && d.Mode == keystore.ModeECB:
+		mech = pkcs11.CKM_DES_ECB_ENCRYPT_DATA
+	case k.algorithm == keystore.AlgorithmDES && d.Mode == keystore.ModeCBC:
+		mech = pkcs11.CKM_DES_CBC_ENCRYPT_DATA
+	default:
+		return nil, fmt.Errorf("unsupported derivation with %s in mode %d", k.algorithm, d.Mode)
+	}
+	if d.Mode == keystore.ModeCBC && icv == nil {
+		icv = make([]byte, k.algorithm.BlockSize())
+	}
+	if d.Mode == keystore.ModeECB {
+		icv = nil
+	}
+	params, err := newEncryptDataParams(k.algorithm.BlockSize(), icv, d.Data)
+	if err != nil {
+		return nil, err
+	}
+	defer params.free()
+	s := k.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	object, err := s.ctx.DeriveKey(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mech, params.bytes)}, k.object, secretKeyTemplate(d.Algorithm, d.Length))
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+	return &Key{store: s, object: object, algorithm: d.Algorithm, length: d.Length}, nil
+}
+
+// deriveCounterCMAC runs the SP800-108 counter mode KDF in the token with CKM_SP800_108_COUNTER_KDF from PKCS#11 3.0.
+// Tokens without that mechanism fall back to computing each CMAC block in the token and assembling the key in process memory,
+// so static derivations such as KDF3 diversification are refused on them.
+func (k *Key) deriveCounterCMAC(d keystore.Derivation) (keystore.Key, error) {
+	if k.algorithm != keystore.AlgorithmAES {
+		return nil, fmt.Errorf("counter mode CMAC derivation requires an AES key, not %s", k.algorithm)
+	}
+	s := k.store
+	if !s.counterKDF {
+		if d.Static {
+			return nil, fmt.Errorf("counter mode CMAC derivation of static keys (KDF3 diversification) requires a token with CKM_SP800_108_COUNTER_KDF, otherwise the derived key would pass through process memory")
+		}
+		return k.deriveCounterCMACBlocks(d)
+	}
+	params := newCounterKDFParams(d.Prefix, d.Suffix)
+	defer params.free()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	object, err := s.ctx.DeriveKey(s.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(ckmSP800108CounterKDF, params.bytes)}, k.object, secretKeyTemplate(d.Algorithm, d.Length))
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+	return &Key{store: s, object: object, algorithm: d.Algorithm, length: d.Length}, nil
+}
+
+// deriveCounterCMACBlocks runs the SP800-108 counter mode KDF with the token computing each CMAC block, then imports the output as a session object
+func (k *Key) deriveCounterCMACBlocks(d keystore.Derivation) (keystore.Key, error) {
+	s := k.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var value []byte
+	defer func() {
+		for i := range value {
+			value[i] = 0
+		}
+	}()
+	for counter := 1; len(value) < d.Length; counter++ {
+		if counter > 0xFF {
+			return nil, fmt.Errorf("derived key too long for an 8 bit counter")
+		}
+		input := append(append(append([]byte{}, d.Prefix...), byte(counter)), d.Suffix...)
+		block, err := k.cmacLocked(pkcs11.CKM_AES_CMAC, input)
+		if err != nil {
+			return nil, err
+		}
+		value = append(value, block...)
+	}
+	template := append(secretKeyTemplate(d.Algorithm, d.Length), pkcs11.NewAttribute(pkcs11.CKA_VALUE, value[:d.Length]))
+	object, err := s.ctx.CreateObject(s.session, template)
+	if err != nil {
+		return nil, fmt.Errorf("creating derived key: %w", err)
+	}
+	return &Key{store: s, object: object, algorithm: d.Algorithm, length: d.Length}, nil
+}
+
+// Unwrap deciphers a wrapped key inside the token as a non-extractable session object
+func (k *Key) Unwrap(mode keystore.Mode, icv, wrapped []byte, algorithm keystore.Algorithm) (keystore.Key, error) {
+	if err := algorithm.ValidateLength(len(wrapped)); err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	mech, err := k.cipherMechanism(mode, icv)
+	if err != nil {
+		return nil, err
+	}
+	s := k.store
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	object, err := s.ctx.UnwrapKey(s.session, []*pkcs11.Mechanism{mech}, k.object, wrapped, secretKeyTemplate(algorithm, len(wrapped)))
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping key: %w", err)
+	}
+	return &Key{store: s, object: object, algorithm: algorithm, length: len(wrapped)}, nil
+}
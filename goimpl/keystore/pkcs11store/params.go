@@ -0,0 +1,173 @@
+package pkcs11store
+
+/*
+#include <stdlib.h>
+#include <string.h>
+
+#ifdef _WIN32
+#pragma pack(push, cryptoki, 1)
+#endif
+
+// CK_KEY_DERIVATION_STRING_DATA
+typedef struct {
+	unsigned char *pData;
+	unsigned long ulLen;
+} derivation_string_data;
+
+// CK_DES_CBC_ENCRYPT_DATA_PARAMS
+typedef struct {
+	unsigned char iv[8];
+	unsigned char *pData;
+	unsigned long length;
+} des_cbc_encrypt_data_params;
+
+// CK_AES_CBC_ENCRYPT_DATA_PARAMS
+typedef struct {
+	unsigned char iv[16];
+	unsigned char *pData;
+	unsigned long length;
+} aes_cbc_encrypt_data_params;
+
+// CK_PRF_DATA_PARAM
+typedef struct {
+	unsigned long dataType;
+	void *pValue;
+	unsigned long ulValueLen;
+} prf_data_param;
+
+// CK_SP800_108_COUNTER_FORMAT
+typedef struct {
+	unsigned char bLittleEndian;
+	unsigned long ulWidthInBits;
+} sp800_108_counter_format;
+
+// CK_SP800_108_KDF_PARAMS
+typedef struct {
+	unsigned long prfType;
+	unsigned long ulNumberOfDataParams;
+	prf_data_param *pDataParams;
+	unsigned long ulAdditionalDerivedKeys;
+	void *pAdditionalDerivedKeys;
+} sp800_108_kdf_params;
+
+#ifdef _WIN32
+#pragma pack(pop, cryptoki)
+#endif
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/miekg/pkcs11"
+)
+
+// PKCS#11 3.0 values which github.com/miekg/pkcs11 does not define
+const (
+	ckmSP800108CounterKDF       = 0x000003AC // CKM_SP800_108_COUNTER_KDF
+	ckSP800108IterationVariable = 0x00000001 // CK_SP800_108_ITERATION_VARIABLE
+	ckSP800108ByteArray         = 0x00000004 // CK_SP800_108_BYTE_ARRAY
+)
+
+// encryptDataParams holds the C memory referenced by the parameter of an ECB or CBC ENCRYPT_DATA derivation mechanism, it must be freed after the derivation
+type encryptDataParams struct {
+	data  unsafe.Pointer
+	bytes []byte
+}
+
+// newEncryptDataParams encodes the parameter for CKM_*_ECB_ENCRYPT_DATA (icv is nil) or CKM_*_CBC_ENCRYPT_DATA
+func newEncryptDataParams(blockSize int, icv, data []byte) (*encryptDataParams, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("no data to derive from")
+	}
+	p := &encryptDataParams{data: C.CBytes(data)}
+	switch {
+	case icv == nil:
+		var s C.derivation_string_data
+		s.pData = (*C.uchar)(p.data)
+		s.ulLen = C.ulong(len(data))
+		p.bytes = C.GoBytes(unsafe.Pointer(&s), C.int(unsafe.Sizeof(s)))
+	case blockSize == 8 && len(icv) == 8:
+		var s C.des_cbc_encrypt_data_params
+		C.memcpy(unsafe.Pointer(&s.iv[0]), unsafe.Pointer(&icv[0]), 8)
+		s.pData = (*C.uchar)(p.data)
+		s.length = C.ulong(len(data))
+		p.bytes = C.GoBytes(unsafe.Pointer(&s), C.int(unsafe.Sizeof(s)))
+	case blockSize == 16 && len(icv) == 16:
+		var s C.aes_cbc_encrypt_data_params
+		C.memcpy(unsafe.Pointer(&s.iv[0]), unsafe.Pointer(&icv[0]), 16)
+		s.pData = (*C.uchar)(p.data)
+		s.length = C.ulong(len(data))
+		p.bytes = C.GoBytes(unsafe.Pointer(&s), C.int(unsafe.Sizeof(s)))
+	default:
+		p.free()
+		return nil, fmt.Errorf("ICV must be %d bytes, got %d", blockSize, len(icv))
+	}
+	return p, nil
+}
+
+func (p *encryptDataParams) free() {
+	C.free(p.data)
+}
+
+// counterKDFParams holds the C memory referenced by the parameter of a CKM_SP800_108_COUNTER_KDF derivation, it must be freed after the derivation
+type counterKDFParams struct {
+	allocations []unsafe.Pointer
+	bytes       []byte
+}
+
+// newCounterKDFParams encodes the parameter for CKM_SP800_108_COUNTER_KDF with AES CMAC as the PRF and prefix | 8 bit counter | suffix as the PRF input
+func newCounterKDFParams(prefix, suffix []byte) *counterKDFParams {
+	p := &counterKDFParams{}
+	var format C.sp800_108_counter_format
+	format.bLittleEndian = 0
+	format.ulWidthInBits = 8
+	var data []C.prf_data_param
+	if len(prefix) > 0 {
+		data = append(data, p.dataParam(ckSP800108ByteArray, unsafe.Pointer(&prefix[0]), len(prefix)))
+	}
+	data = append(data, p.dataParam(ckSP800108IterationVariable, unsafe.Pointer(&format), int(unsafe.Sizeof(format))))
+	if len(suffix) > 0 {
+		data = append(data, p.dataParam(ckSP800108ByteArray, unsafe.Pointer(&suffix[0]), len(suffix)))
+	}
+	var params C.sp800_108_kdf_params
+	params.prfType = C.ulong(pkcs11.CKM_AES_CMAC)
+	params.ulNumberOfDataParams = C.ulong(len(data))
+	params.pDataParams = (*C.prf_data_param)(p.copy(unsafe.Pointer(&data[0]), len(data)*int(unsafe.Sizeof(data[0]))))
+	p.bytes = C.GoBytes(unsafe.Pointer(&params), C.int(unsafe.Sizeof(params)))
+	return p
+}
+
+// dataParam copies the value to C memory and describes it as a CK_PRF_DATA_PARAM
+func (p *counterKDFParams) dataParam(dataType uint, value unsafe.Pointer, length int) C.prf_data_param {
+	var param C.prf_data_param
+	param.dataType = C.ulong(dataType)
+	param.pValue = p.copy(value, length)
+	param.ulValueLen = C.ulong(length)
+	return param
+}
+
+// copy allocates C memory holding a copy of the value
+func (p *counterKDFParams) copy(value unsafe.Pointer, length int) unsafe.Pointer {
+	out := C.malloc(C.size_t(length))
+	C.memcpy(out, value, C.size_t(length))
+	p.allocations = append(p.allocations, out)
+	return out
+}
+
+func (p *counterKDFParams) free() {
+	for _, allocation := range p.allocations {
+		C.free(allocation)
+	}
+}
+
+// ulongFromBytes decodes a native CK_ULONG attribute value
+func ulongFromBytes(b []byte) (uint, error) {
+	var v C.ulong
+	if len(b) != int(unsafe.Sizeof(v)) {
+		return 0, fmt.Errorf("expected %d byte CK_ULONG, got %d bytes", unsafe.Sizeof(v), len(b))
+	}
+	C.memcpy(unsafe.Pointer(&v), unsafe.Pointer(&b[0]), C.size_t(len(b)))
+	return uint(v), nil
+}
@@ -0,0 +1,229 @@
+package pkcs11store
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+	"github.com/miekg/pkcs11"
+)
+
+// Config selects the PKCS#11 module and token
+type Config struct {
+	Module     string // Path to the PKCS#11 library, e.g. /usr/lib/softhsm/libsofthsm2.so
+	TokenLabel string // Label of the token to use
+	PIN        string // User PIN of the token
+}
+
+// Store is a keystore.Store backed by a logged in session on a PKCS#11 token.
+// Every operation is serialised on the one session, so keys from a Store are safe for concurrent use.
+type Store struct {
+	mutex   sync.Mutex
+	ctx     *pkcs11.Ctx
+	session pkcs11.SessionHandle
+	// counterKDF is set when the token supports CKM_SP800_108_COUNTER_KDF
+	counterKDF bool
+}
+
+// Open loads the module, finds the token and logs in as the user
+func Open(config Config) (*Store, error) {
+	ctx := pkcs11.New(config.Module)
+	if ctx == nil {
+		return nil, fmt.Errorf("could not load PKCS#11 module %q", config.Module)
+	}
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, fmt.Errorf("initialising PKCS#11 module: %w", err)
+	}
+	s := &Store{ctx: ctx}
+	slot, err := s.findSlot(config.TokenLabel)
+	if err != nil {
+		s.finalize()
+		return nil, err
+	}
+	if s.counterKDF, err = s.hasMechanism(slot, ckmSP800108CounterKDF); err != nil {
+		s.finalize()
+		return nil, err
+	}
+	if s.session, err = ctx.OpenSession(slot, pkcs11.CKF_SERIAL_SESSION|pkcs11.CKF_RW_SESSION); err != nil {
+		s.finalize()
+		return nil, fmt.Errorf("opening session: %w", err)
+	}
+	if err = ctx.Login(s.session, pkcs11.CKU_USER, config.PIN); err != nil {
+		ctx.CloseSession(s.session)
+		s.finalize()
+		return nil, fmt.Errorf("logging in: %w", err)
+	}
+	return s, nil
+}
+
+func (s *Store) findSlot(label string) (uint, error) {
+	slots, err := s.ctx.GetSlotList(true)
+	if err != nil {
+		return 0, fmt.Errorf("listing slots: %w", err)
+	}
+	for _, slot := range slots {
+		info, err := s.ctx.GetTokenInfo(slot)
+		if err != nil {
+			return 0, fmt.Errorf("reading token info for slot %d: %w", slot, err)
+		}
+		if strings.TrimRight(info.Label, " \x00") == label {
+			return slot, nil
+		}
+	}
+	return 0, fmt.Errorf("no token with label %q", label)
+}
+
+func (s *Store) hasMechanism(slot uint, mechanism uint) (bool, error) {
+	mechanisms, err := s.ctx.GetMechanismList(slot)
+	if err != nil {
+		return false, fmt.Errorf("listing mechanisms: %w", err)
+	}
+	for _, m := range mechanisms {
+		if m.Mechanism == mechanism {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Store) finalize() {
+	s.ctx.Finalize()
+	s.ctx.Destroy()
+}
+
+// Close logs out and unloads the module, every key from the Store is unusable afterwards and session objects are destroyed by the token
+func (s *Store) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx == nil {
+		return nil
+	}
+	s.ctx.Logout(s.session)
+	err := s.ctx.CloseSession(s.session)
+	s.finalize()
+	s.ctx = nil
+	if err != nil {
+		return fmt.Errorf("closing session: %w", err)
+	}
+	return nil
+}
+
+// Key finds the secret key with the label
+func (s *Store) Key(label string) (keystore.Key, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+	if err := s.ctx.FindObjectsInit(s.session, template); err != nil {
+		return nil, fmt.Errorf("finding key %q: %w", label, err)
+	}
+	objects, _, err := s.ctx.FindObjects(s.session, 2)
+	s.ctx.FindObjectsFinal(s.session)
+	if err != nil {
+		return nil, fmt.Errorf("finding key %q: %w", label, err)
+	}
+	switch len(objects) {
+	case 0:
+		return nil, fmt.Errorf("no key with label %q", label)
+	case 1:
+		return s.keyFromObject(objects[0])
+	default:
+		return nil, fmt.Errorf("more than one key with label %q", label)
+	}
+}
+
+// keyFromObject reads the type and length of a secret key object, the caller must hold the mutex
+func (s *Store) keyFromObject(object pkcs11.ObjectHandle) (*Key, error) {
+	attrs, err := s.ctx.GetAttributeValue(s.session, object, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)})
+	if err != nil {
+		return nil, fmt.Errorf("reading key type: %w", err)
+	}
+	keyType, err := ulongFromBytes(attrs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	k := &Key{store: s, object: object}
+	switch keyType {
+	case pkcs11.CKK_DES:
+		k.algorithm, k.length = keystore.AlgorithmDES, 8
+	case pkcs11.CKK_DES2:
+		k.algorithm, k.length = keystore.AlgorithmTripleDES, 16
+	case pkcs11.CKK_DES3:
+		k.algorithm, k.length = keystore.AlgorithmTripleDES, 24
+	case pkcs11.CKK_AES:
+		attrs, err = s.ctx.GetAttributeValue(s.session, object, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, nil)})
+		if err != nil {
+			return nil, fmt.Errorf("reading key length: %w", err)
+		}
+		length, err := ulongFromBytes(attrs[0].Value)
+		if err != nil {
+			return nil, err
+		}
+		k.algorithm, k.length = keystore.AlgorithmAES, int(length)
+	default:
+		return nil, fmt.Errorf("unsupported key type %X", keyType)
+	}
+	return k, nil
+}
+
+// Import creates a non-extractable session object from key material, for testing or for keys which already exist in memory such as those agreed by SCP11
+func (s *Store) Import(label string, algorithm keystore.Algorithm, material []byte) (keystore.Key, error) {
+	if err := algorithm.ValidateLength(len(material)); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.ctx == nil {
+		return nil, fmt.Errorf("store is closed")
+	}
+	template := append(secretKeyTemplate(algorithm, len(material)), pkcs11.NewAttribute(pkcs11.CKA_VALUE, material))
+	if label != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, label))
+	}
+	object, err := s.ctx.CreateObject(s.session, template)
+	if err != nil {
+		return nil, fmt.Errorf("creating key: %w", err)
+	}
+	return &Key{store: s, object: object, algorithm: algorithm, length: len(material)}, nil
+}
+
+// secretKeyTemplate is the template for a new non-extractable session key, CKA_VALUE_LEN is only set for AES since the DES types have a fixed length
+func secretKeyTemplate(algorithm keystore.Algorithm, length int) []*pkcs11.Attribute {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType(algorithm, length)),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DERIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_UNWRAP, true),
+	}
+	if algorithm == keystore.AlgorithmAES {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, length))
+	}
+	return template
+}
+
+// keyType maps an algorithm and length to a PKCS#11 key type
+func keyType(algorithm keystore.Algorithm, length int) uint {
+	switch {
+	case algorithm == keystore.AlgorithmDES:
+		return pkcs11.CKK_DES
+	case algorithm == keystore.AlgorithmTripleDES && length == 16:
+		return pkcs11.CKK_DES2
+	case algorithm == keystore.AlgorithmTripleDES:
+		return pkcs11.CKK_DES3
+	default:
+		return pkcs11.CKK_AES
+	}
+}
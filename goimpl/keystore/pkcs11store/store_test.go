@@ -0,0 +1,140 @@
+package pkcs11store
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/llkennedy/globalplatform/goimpl/keydiv"
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+	"github.com/llkennedy/globalplatform/goimpl/scp03"
+	"github.com/stretchr/testify/assert"
+)
+
+// openTestStore opens the token named by PKCS11_MODULE, PKCS11_TOKEN_LABEL and PKCS11_PIN, e.g. a SoftHSM token, skipping the test when they are not set
+func openTestStore(t *testing.T) *Store {
+	config := Config{
+		Module:     os.Getenv("PKCS11_MODULE"),
+		TokenLabel: os.Getenv("PKCS11_TOKEN_LABEL"),
+		PIN:        os.Getenv("PKCS11_PIN"),
+	}
+	if config.Module == "" {
+		t.Skip("PKCS11_MODULE is not set")
+	}
+	s, err := Open(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// sameKey checks that two keys encrypt the same block identically, since neither can be compared directly
+func sameKey(t *testing.T, want, got keystore.Key) {
+	data := bytes.Repeat([]byte{0x5A}, want.Algorithm().BlockSize())
+	wantOut, err := want.Encrypt(keystore.ModeECB, nil, data)
+	assert.NoError(t, err)
+	gotOut, err := got.Encrypt(keystore.ModeECB, nil, data)
+	assert.NoError(t, err)
+	assert.Equal(t, wantOut, gotOut)
+	assert.Equal(t, want.Algorithm(), got.Algorithm())
+	assert.Equal(t, want.Length(), got.Length())
+}
+
+func TestKey(t *testing.T) {
+	s := openTestStore(t)
+	tests := []struct {
+		name      string
+		algorithm keystore.Algorithm
+		material  []byte
+	}{
+		{name: "AES-128", algorithm: keystore.AlgorithmAES, material: bytes.Repeat([]byte{0x40}, 16)},
+		{name: "AES-256", algorithm: keystore.AlgorithmAES, material: bytes.Repeat([]byte{0x41}, 32)},
+		{name: "two key triple DES", algorithm: keystore.AlgorithmTripleDES, material: append(bytes.Repeat([]byte{0x40}, 8), bytes.Repeat([]byte{0x4F}, 8)...)},
+		{name: "DES", algorithm: keystore.AlgorithmDES, material: bytes.Repeat([]byte{0x43}, 8)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			software, err := keystore.NewSoftwareKey(tt.algorithm, tt.material)
+			assert.NoError(t, err)
+			token, err := s.Import("test-"+tt.name, tt.algorithm, tt.material)
+			if !assert.NoError(t, err) {
+				return
+			}
+			found, err := s.Key("test-" + tt.name)
+			assert.NoError(t, err)
+			sameKey(t, software, found)
+			blockSize := tt.algorithm.BlockSize()
+			data := bytes.Repeat([]byte{0x11, 0x22}, blockSize)
+			icv := bytes.Repeat([]byte{0x33}, blockSize)
+			for _, mode := range []keystore.Mode{keystore.ModeECB, keystore.ModeCBC} {
+				want, _ := software.Encrypt(mode, icv, data)
+				got, err := token.Encrypt(mode, icv, data)
+				assert.NoError(t, err)
+				assert.Equal(t, want, got)
+				plain, err := token.Decrypt(mode, icv, got)
+				assert.NoError(t, err)
+				assert.Equal(t, data, plain)
+				derivation := keystore.Derivation{Method: keystore.DeriveEncrypt, Algorithm: tt.algorithm, Length: len(tt.material), Mode: mode, Data: bytes.Repeat([]byte{0x0F}, len(tt.material))}
+				wantKey, _ := software.Derive(derivation)
+				gotKey, err := token.Derive(derivation)
+				if assert.NoError(t, err) {
+					sameKey(t, wantKey, gotKey)
+				}
+			}
+			if tt.algorithm != keystore.AlgorithmDES {
+				want, _ := software.CMAC(data)
+				got, err := token.CMAC(data)
+				assert.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+			if tt.algorithm == keystore.AlgorithmAES {
+				derivation := keystore.Derivation{Method: keystore.DeriveCounterCMAC, Algorithm: keystore.AlgorithmAES, Length: 32, Prefix: []byte{0x01, 0x02}, Suffix: []byte{0x03}}
+				wantKey, _ := software.Derive(derivation)
+				gotKey, err := token.Derive(derivation)
+				if assert.NoError(t, err) {
+					sameKey(t, wantKey, gotKey)
+				}
+			}
+		})
+	}
+	_, err := s.Key("no such key")
+	assert.Error(t, err)
+}
+
+func TestStore_scp03(t *testing.T) {
+	s := openTestStore(t)
+	var master, software scp03.Keys
+	var err error
+	for i, key := range []*keystore.Key{&master.ENC, &master.MAC, &master.DEK} {
+		if *key, err = s.Import("", keystore.AlgorithmAES, bytes.Repeat([]byte{0x40 + byte(i)}, 16)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	software, _ = scp03.NewKeys(bytes.Repeat([]byte{0x40}, 16), bytes.Repeat([]byte{0x41}, 16), bytes.Repeat([]byte{0x42}, 16))
+	kdd := [10]byte{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19}
+	diversified, err := keydiv.Diversify(keydiv.KDF3{}, keydiv.Keys(master), kdd)
+	if !s.counterKDF {
+		// SoftHSM 2.6 has no CKM_SP800_108_COUNTER_KDF, so diversifying would expose the static keys
+		assert.Error(t, err)
+		card, err := scp03.NewCard(software, scp03.CardOptions{KeyDiversificationData: kdd})
+		assert.NoError(t, err)
+		_, err = scp03.Open(card, master, scp03.Options{SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelRMAC})
+		assert.NoError(t, err)
+		assert.True(t, card.Authenticated())
+		return
+	}
+	if !assert.NoError(t, err) {
+		return
+	}
+	want, _ := keydiv.Diversify(keydiv.KDF3{}, keydiv.Keys(software), kdd)
+	sameKey(t, want.ENC, diversified.ENC)
+	sameKey(t, want.MAC, diversified.MAC)
+	sameKey(t, want.DEK, diversified.DEK)
+	card, err := scp03.NewCard(scp03.Keys(want), scp03.CardOptions{KeyDiversificationData: kdd})
+	assert.NoError(t, err)
+	_, err = scp03.Open(card, master, scp03.Options{SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelRMAC, Diversification: keydiv.KDF3{}})
+	assert.NoError(t, err)
+	assert.True(t, card.Authenticated())
+}
@@ -0,0 +1,186 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"fmt"
+	"sync"
+
+	"github.com/aead/cmac"
+	"github.com/llkennedy/globalplatform/goimpl/nist/sp800108"
+)
+
+// softwareKey is a key held in process memory
+type softwareKey struct {
+	algorithm Algorithm
+	material  []byte
+}
+
+// NewSoftwareKey creates a key held in process memory from a copy of the key material
+func NewSoftwareKey(algorithm Algorithm, material []byte) (Key, error) {
+	if err := algorithm.ValidateLength(len(material)); err != nil {
+		return nil, err
+	}
+	return &softwareKey{
+		algorithm: algorithm,
+		material:  append([]byte{}, material...),
+	}, nil
+}
+
+func (k *softwareKey) Algorithm() Algorithm {
+	return k.algorithm
+}
+
+func (k *softwareKey) Length() int {
+	return len(k.material)
+}
+
+func (k *softwareKey) block() (cipher.Block, error) {
+	switch k.algorithm {
+	case AlgorithmAES:
+		return aes.NewCipher(k.material)
+	case AlgorithmTripleDES:
+		full := k.material
+		if len(full) == 16 {
+			full = append(append([]byte{}, full...), full[:8]...)
+		}
+		return des.NewTripleDESCipher(full)
+	case AlgorithmDES:
+		return des.NewCipher(k.material)
+	default:
+		return nil, fmt.Errorf("unknown algorithm %s", k.algorithm)
+	}
+}
+
+func (k *softwareKey) crypt(encrypt bool, mode Mode, icv, data []byte) ([]byte, error) {
+	block, err := k.block()
+	if err != nil {
+		return nil, err
+	}
+	size := block.BlockSize()
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("data length %d is not a multiple of the block size", len(data))
+	}
+	out := make([]byte, len(data))
+	switch mode {
+	case ModeECB:
+		for i := 0; i < len(data); i += size {
+			if encrypt {
+				block.Encrypt(out[i:i+size], data[i:i+size])
+			} else {
+				block.Decrypt(out[i:i+size], data[i:i+size])
+			}
+		}
+	case ModeCBC:
+		if icv == nil {
+			icv = make([]byte, size)
+		}
+		if len(icv) != size {
+			return nil, fmt.Errorf("ICV must be %d bytes, got %d", size, len(icv))
+		}
+		if encrypt {
+			cipher.NewCBCEncrypter(block, icv).CryptBlocks(out, data)
+		} else {
+			cipher.NewCBCDecrypter(block, icv).CryptBlocks(out, data)
+		}
+	default:
+		return nil, fmt.Errorf("unknown mode %d", mode)
+	}
+	return out, nil
+}
+
+func (k *softwareKey) Encrypt(mode Mode, icv, data []byte) ([]byte, error) {
+	return k.crypt(true, mode, icv, data)
+}
+
+func (k *softwareKey) Decrypt(mode Mode, icv, data []byte) ([]byte, error) {
+	return k.crypt(false, mode, icv, data)
+}
+
+func (k *softwareKey) CMAC(data []byte) ([]byte, error) {
+	block, err := k.block()
+	if err != nil {
+		return nil, err
+	}
+	return cmac.Sum(data, block, block.BlockSize())
+}
+
+func (k *softwareKey) Derive(d Derivation) (Key, error) {
+	if err := d.Algorithm.ValidateLength(d.Length); err != nil {
+		return nil, fmt.Errorf("invalid derived key: %w", err)
+	}
+	var value []byte
+	var err error
+	switch d.Method {
+	case DeriveEncrypt:
+		value, err = k.Encrypt(d.Mode, d.ICV, d.Data)
+	case DeriveCounterCMAC:
+		if k.algorithm != AlgorithmAES {
+			return nil, fmt.Errorf("counter mode CMAC derivation requires an AES key, not %s", k.algorithm)
+		}
+		bits := d.Length * 8
+		ordering := []sp800108.InputStringOrdering{sp800108.InputOrderLabel, sp800108.InputOrderCounter, sp800108.InputOrderContext}
+		kdf := &sp800108.CounterKBKDF{}
+		value, err = kdf.Derive(&sp800108.PRFCMAC{}, sp800108.CounterLength8, k.material, d.Prefix, d.Suffix, []byte{byte(bits >> 8), byte(bits)}, ordering)
+	default:
+		return nil, fmt.Errorf("unknown derivation method %d", d.Method)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("deriving key: %w", err)
+	}
+	if len(value) < d.Length {
+		return nil, fmt.Errorf("derivation produced %d bytes, %d required", len(value), d.Length)
+	}
+	return NewSoftwareKey(d.Algorithm, value[:d.Length])
+}
+
+func (k *softwareKey) Unwrap(mode Mode, icv, wrapped []byte, algorithm Algorithm) (Key, error) {
+	value, err := k.Decrypt(mode, icv, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping key: %w", err)
+	}
+	return NewSoftwareKey(algorithm, value)
+}
+
+// MemoryStore is a Store of keys held in process memory
+type MemoryStore struct {
+	mutex sync.RWMutex
+	keys  map[string]Key
+}
+
+// NewMemoryStore creates an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{keys: map[string]Key{}}
+}
+
+// Key returns the key with the label
+func (s *MemoryStore) Key(label string) (Key, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	key, ok := s.keys[label]
+	if !ok {
+		return nil, fmt.Errorf("no key with label %q", label)
+	}
+	return key, nil
+}
+
+// Put adds or replaces the key with the label
+func (s *MemoryStore) Put(label string, key Key) error {
+	if key == nil {
+		return fmt.Errorf("cannot store nil key")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[label] = key
+	return nil
+}
+
+// Import creates a software key from the key material and adds it with the label
+func (s *MemoryStore) Import(label string, algorithm Algorithm, material []byte) (Key, error) {
+	key, err := NewSoftwareKey(algorithm, material)
+	if err != nil {
+		return nil, err
+	}
+	return key, s.Put(label, key)
+}
@@ -0,0 +1,102 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	"github.com/aead/cmac"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSoftwareKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		material  []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "AES-128", algorithm: AlgorithmAES, material: make([]byte, 16), assertion: assert.NoError},
+		{name: "AES-256", algorithm: AlgorithmAES, material: make([]byte, 32), assertion: assert.NoError},
+		{name: "AES wrong length", algorithm: AlgorithmAES, material: make([]byte, 20), assertion: assert.Error},
+		{name: "two key triple DES", algorithm: AlgorithmTripleDES, material: make([]byte, 16), assertion: assert.NoError},
+		{name: "triple DES wrong length", algorithm: AlgorithmTripleDES, material: make([]byte, 8), assertion: assert.Error},
+		{name: "DES", algorithm: AlgorithmDES, material: make([]byte, 8), assertion: assert.NoError},
+		{name: "unknown algorithm", algorithm: 0, material: make([]byte, 16), assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := NewSoftwareKey(tt.algorithm, tt.material)
+			tt.assertion(t, err)
+			if err == nil {
+				assert.Equal(t, tt.algorithm, key.Algorithm())
+				assert.Equal(t, len(tt.material), key.Length())
+			}
+		})
+	}
+}
+
+func TestSoftwareKey(t *testing.T) {
+	raw := bytes.Repeat([]byte{0x40}, 16)
+	key, err := NewSoftwareKey(AlgorithmAES, raw)
+	assert.NoError(t, err)
+	block, _ := aes.NewCipher(raw)
+	data := bytes.Repeat([]byte{0x11, 0x22}, 16)
+	icv := bytes.Repeat([]byte{0x33}, 16)
+	want := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, icv).CryptBlocks(want, data)
+	t.Run("CBC", func(t *testing.T) {
+		got, err := key.Encrypt(ModeCBC, icv, data)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+		plain, err := key.Decrypt(ModeCBC, icv, got)
+		assert.NoError(t, err)
+		assert.Equal(t, data, plain)
+		_, err = key.Encrypt(ModeCBC, icv, data[:5])
+		assert.Error(t, err)
+	})
+	t.Run("CMAC", func(t *testing.T) {
+		wantMAC, _ := cmac.Sum(data, block, aes.BlockSize)
+		got, err := key.CMAC(data)
+		assert.NoError(t, err)
+		assert.Equal(t, wantMAC, got)
+	})
+	t.Run("derive by encryption", func(t *testing.T) {
+		derived, err := key.Derive(Derivation{Method: DeriveEncrypt, Algorithm: AlgorithmDES, Length: 8, Mode: ModeCBC, ICV: icv, Data: data})
+		assert.NoError(t, err)
+		expected, _ := NewSoftwareKey(AlgorithmDES, want[:8])
+		assert.Equal(t, expected, derived)
+	})
+	t.Run("derive by counter CMAC", func(t *testing.T) {
+		derived, err := key.Derive(Derivation{Method: DeriveCounterCMAC, Algorithm: AlgorithmAES, Length: 32, Prefix: []byte{0xAA}, Suffix: []byte{0xBB}})
+		assert.NoError(t, err)
+		first, _ := cmac.Sum([]byte{0xAA, 0x01, 0xBB}, block, aes.BlockSize)
+		second, _ := cmac.Sum([]byte{0xAA, 0x02, 0xBB}, block, aes.BlockSize)
+		expected, _ := NewSoftwareKey(AlgorithmAES, append(first, second...))
+		assert.Equal(t, expected, derived)
+		des, _ := NewSoftwareKey(AlgorithmTripleDES, raw)
+		_, err = des.Derive(Derivation{Method: DeriveCounterCMAC, Algorithm: AlgorithmAES, Length: 16})
+		assert.Error(t, err)
+	})
+	t.Run("unwrap", func(t *testing.T) {
+		unwrapped, err := key.Unwrap(ModeCBC, icv, want, AlgorithmAES)
+		assert.NoError(t, err)
+		expected, _ := NewSoftwareKey(AlgorithmAES, data)
+		assert.Equal(t, expected, unwrapped)
+	})
+}
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	imported, err := s.Import("enc", AlgorithmAES, make([]byte, 16))
+	assert.NoError(t, err)
+	got, err := s.Key("enc")
+	assert.NoError(t, err)
+	assert.Equal(t, imported, got)
+	_, err = s.Key("mac")
+	assert.Error(t, err)
+	assert.Error(t, s.Put("mac", nil))
+	_, err = s.Import("bad", AlgorithmDES, make([]byte, 16))
+	assert.Error(t, err)
+}
@@ -1,5 +1,7 @@
 package lifecycle
 
+import "fmt"
+
 // Application is an application life cycle state
 type Application byte
 
@@ -18,3 +20,95 @@ func IsValidCustomApplicationState(in Application) bool {
 	// This does mean that SELECTABLE by itself counts as a valid "custom" state, which is consistent with the spec
 	return in&ApplicationSelectable == ApplicationSelectable && in&b8 == 0
 }
+
+// ParseApplication parses an application life cycle state byte, including application specific and locked states
+func ParseApplication(in byte) (Application, error) {
+	a := Application(in)
+	if unlocked := a &^ b8; unlocked == ApplicationInstalled || IsValidCustomApplicationState(unlocked) {
+		return a, nil
+	}
+	return 0, fmt.Errorf("invalid application life cycle state %02X", in)
+}
+
+// Locked reports whether the state is LOCKED
+func (a Application) Locked() bool {
+	return a&b8 != 0
+}
+
+// Unlocked returns the state the application was in before it was locked, which it returns to on unlock
+func (a Application) Unlocked() Application {
+	return a &^ b8
+}
+
+// Next lists the states the application may move to from this one, where ApplicationLocked stands for any locked state
+func (a Application) Next() []Application {
+	if a.Locked() {
+		return []Application{a.Unlocked()}
+	}
+	if a == ApplicationInstalled {
+		return []Application{ApplicationSelectable, ApplicationLocked}
+	}
+	if !IsValidCustomApplicationState(a) {
+		return nil
+	}
+	var next []Application
+	for bits := Application(0); bits <= b4|b5|b6|b7; bits += b4 {
+		if custom := ApplicationSelectable | bits; custom != a {
+			next = append(next, custom)
+		}
+	}
+	return append(next, ApplicationLocked)
+}
+
+// CanTransition reports whether the target is one of the next states, regardless of who asks for it
+func (a Application) CanTransition(target Application) bool {
+	for _, next := range a.Next() {
+		if next == target {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTransition returns an error if the application may not move to the target state, or the requester may not ask for it.
+// Only the application itself changes its application specific state, and it may lock but not unlock itself.
+func (a Application) CheckTransition(target Application, requester Requester) error {
+	if !a.CanTransition(target) {
+		return fmt.Errorf("application life cycle cannot change from %s to %s", a, target)
+	}
+	switch {
+	case target == ApplicationLocked:
+		if !requester.mayLock() {
+			return fmt.Errorf("locking an application requires being the application, its security domain or holding the Global Lock privilege")
+		}
+	case a.Locked():
+		if !requester.mayUnlock() {
+			return fmt.Errorf("unlocking an application requires being its security domain or holding the Global Lock privilege")
+		}
+	case a == ApplicationInstalled:
+		if !requester.AssociatedSecurityDomain {
+			return fmt.Errorf("only the associated security domain can make an application selectable")
+		}
+	default:
+		if !requester.Self {
+			return fmt.Errorf("only the application itself can change its application specific state")
+		}
+	}
+	return nil
+}
+
+// String returns the name of the state
+func (a Application) String() string {
+	switch {
+	case a.Locked():
+		return "LOCKED"
+	case a == ApplicationInstalled:
+		return "INSTALLED"
+	case a == ApplicationSelectable:
+		return "SELECTABLE"
+	case IsValidCustomApplicationState(a):
+		return fmt.Sprintf("APPLICATION_SPECIFIC(%02X)", byte(a))
+	default:
+		return fmt.Sprintf("UNKNOWN(%02X)", byte(a))
+	}
+}
@@ -0,0 +1,68 @@
+package lifecycle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseApplication(t *testing.T) {
+	tests := []struct {
+		name      string
+		in        byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "installed", in: 0x03, assertion: assert.NoError},
+		{name: "selectable", in: 0x07, assertion: assert.NoError},
+		{name: "application specific", in: 0x7F, assertion: assert.NoError},
+		{name: "locked", in: 0x83, assertion: assert.NoError},
+		{name: "locked application specific", in: 0x8F, assertion: assert.NoError},
+		{name: "invalid", in: 0x05, assertion: assert.Error},
+		{name: "invalid locked", in: 0x81, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseApplication(tt.in)
+			tt.assertion(t, err)
+			if err == nil {
+				assert.Equal(t, Application(tt.in), got)
+			}
+		})
+	}
+}
+
+func TestApplication_Next(t *testing.T) {
+	assert.Equal(t, []Application{ApplicationSelectable, ApplicationLocked}, ApplicationInstalled.Next())
+	assert.Equal(t, []Application{0x0F}, Application(0x8F).Next())
+	next := ApplicationSelectable.Next()
+	assert.Len(t, next, 16)
+	assert.NotContains(t, next, ApplicationSelectable)
+	assert.Contains(t, next, Application(0x7F))
+	assert.Contains(t, next, ApplicationLocked)
+}
+
+func TestApplication_CheckTransition(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  Application
+		requester Requester
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "make selectable", from: ApplicationInstalled, to: ApplicationSelectable, requester: Requester{AssociatedSecurityDomain: true}, assertion: assert.NoError},
+		{name: "make itself selectable", from: ApplicationInstalled, to: ApplicationSelectable, requester: Requester{Self: true}, assertion: assert.Error},
+		{name: "application specific", from: ApplicationSelectable, to: 0x0F, requester: Requester{Self: true}, assertion: assert.NoError},
+		{name: "application specific by security domain", from: ApplicationSelectable, to: 0x0F, requester: Requester{AssociatedSecurityDomain: true}, assertion: assert.Error},
+		{name: "lock itself", from: 0x0F, to: ApplicationLocked, requester: Requester{Self: true}, assertion: assert.NoError},
+		{name: "global lock", from: 0x0F, to: ApplicationLocked, requester: Requester{GlobalLock: true}, assertion: assert.NoError},
+		{name: "lock by other", from: 0x0F, to: ApplicationLocked, requester: Requester{CardLock: true}, assertion: assert.Error},
+		{name: "unlock", from: 0x8F, to: 0x0F, requester: Requester{AssociatedSecurityDomain: true}, assertion: assert.NoError},
+		{name: "unlock itself", from: 0x8F, to: 0x0F, requester: Requester{Self: true}, assertion: assert.Error},
+		{name: "unlock to another state", from: 0x8F, to: ApplicationSelectable, requester: Requester{GlobalLock: true}, assertion: assert.Error},
+		{name: "back to installed", from: ApplicationSelectable, to: ApplicationInstalled, requester: Requester{Self: true, AssociatedSecurityDomain: true}, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.assertion(t, tt.from.CheckTransition(tt.to, tt.requester))
+		})
+	}
+}
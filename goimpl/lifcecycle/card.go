@@ -1,5 +1,7 @@
 package lifecycle
 
+import "fmt"
+
 // Card is a life cycle state
 type Card int
 
@@ -15,3 +17,80 @@ const (
 	// CardTerminated is the TERMINATED life cycle state
 	CardTerminated Card = 0xFF
 )
+
+// ParseCard parses a card life cycle state byte
+func ParseCard(in byte) (Card, error) {
+	switch c := Card(in); c {
+	case CardOPReady, CardInitialized, CardSecured, CardCardLocked, CardTerminated:
+		return c, nil
+	default:
+		return 0, fmt.Errorf("invalid card life cycle state %02X", in)
+	}
+}
+
+// Next lists the states the card may move to from this one
+func (c Card) Next() []Card {
+	switch c {
+	case CardOPReady:
+		return []Card{CardInitialized, CardTerminated}
+	case CardInitialized:
+		return []Card{CardSecured, CardTerminated}
+	case CardSecured:
+		return []Card{CardCardLocked, CardTerminated}
+	case CardCardLocked:
+		return []Card{CardSecured, CardTerminated}
+	default:
+		return nil
+	}
+}
+
+// CanTransition reports whether the target is one of the next states, regardless of who asks for it
+func (c Card) CanTransition(target Card) bool {
+	for _, next := range c.Next() {
+		if next == target {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTransition returns an error if the card may not move to the target state, or the requester may not ask for it.
+// OP_READY to INITIALIZED to SECURED is reserved to the issuer security domain, locking and unlocking needs the Card Lock privilege and terminating needs the Card Terminate privilege.
+func (c Card) CheckTransition(target Card, requester Requester) error {
+	if !c.CanTransition(target) {
+		return fmt.Errorf("card life cycle cannot change from %s to %s", c, target)
+	}
+	switch {
+	case target == CardTerminated:
+		if !requester.CardTerminate {
+			return fmt.Errorf("terminating the card requires the Card Terminate privilege")
+		}
+	case target == CardCardLocked || c == CardCardLocked:
+		if !requester.CardLock {
+			return fmt.Errorf("locking or unlocking the card requires the Card Lock privilege")
+		}
+	default:
+		if !requester.IssuerSecurityDomain {
+			return fmt.Errorf("only the issuer security domain can move the card from %s to %s", c, target)
+		}
+	}
+	return nil
+}
+
+// String returns the name of the state
+func (c Card) String() string {
+	switch c {
+	case CardOPReady:
+		return "OP_READY"
+	case CardInitialized:
+		return "INITIALIZED"
+	case CardSecured:
+		return "SECURED"
+	case CardCardLocked:
+		return "CARD_LOCKED"
+	case CardTerminated:
+		return "TERMINATED"
+	default:
+		return fmt.Sprintf("UNKNOWN(%02X)", int(c))
+	}
+}
@@ -0,0 +1,49 @@
+package lifecycle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCard(t *testing.T) {
+	for _, in := range []byte{0x01, 0x07, 0x0F, 0x7F, 0xFF} {
+		got, err := ParseCard(in)
+		assert.NoError(t, err)
+		assert.Equal(t, Card(in), got)
+	}
+	_, err := ParseCard(0x03)
+	assert.Error(t, err)
+}
+
+func TestCard_CheckTransition(t *testing.T) {
+	isd := Requester{IssuerSecurityDomain: true}
+	tests := []struct {
+		name      string
+		from, to  Card
+		requester Requester
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "initialize", from: CardOPReady, to: CardInitialized, requester: isd, assertion: assert.NoError},
+		{name: "secure", from: CardInitialized, to: CardSecured, requester: isd, assertion: assert.NoError},
+		{name: "secure by application", from: CardInitialized, to: CardSecured, requester: Requester{CardLock: true}, assertion: assert.Error},
+		{name: "skip initialized", from: CardOPReady, to: CardSecured, requester: isd, assertion: assert.Error},
+		{name: "lock", from: CardSecured, to: CardCardLocked, requester: Requester{CardLock: true}, assertion: assert.NoError},
+		{name: "lock without privilege", from: CardSecured, to: CardCardLocked, requester: isd, assertion: assert.Error},
+		{name: "unlock", from: CardCardLocked, to: CardSecured, requester: Requester{CardLock: true}, assertion: assert.NoError},
+		{name: "unlock without privilege", from: CardCardLocked, to: CardSecured, requester: isd, assertion: assert.Error},
+		{name: "terminate", from: CardCardLocked, to: CardTerminated, requester: Requester{CardTerminate: true}, assertion: assert.NoError},
+		{name: "terminate without privilege", from: CardSecured, to: CardTerminated, requester: Requester{CardLock: true}, assertion: assert.Error},
+		{name: "terminated is final", from: CardTerminated, to: CardSecured, requester: Requester{CardLock: true, CardTerminate: true, IssuerSecurityDomain: true}, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.assertion(t, tt.from.CheckTransition(tt.to, tt.requester))
+		})
+	}
+}
+
+func TestCard_String(t *testing.T) {
+	assert.Equal(t, "CARD_LOCKED", CardCardLocked.String())
+	assert.Equal(t, "UNKNOWN(03)", Card(0x03).String())
+}
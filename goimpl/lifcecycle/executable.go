@@ -1,5 +1,7 @@
 package lifecycle
 
+import "fmt"
+
 // ExecutableLoadFile is an Executable Load File life cycle state
 type ExecutableLoadFile byte
 
@@ -7,3 +9,19 @@ const (
 	// ExecutableLoadFileLoaded is the LOADED Executable Load File lifecycle state
 	ExecutableLoadFileLoaded ExecutableLoadFile = 0x01
 )
+
+// ParseExecutableLoadFile parses an Executable Load File life cycle state byte, LOADED is the only state as load files are only ever deleted
+func ParseExecutableLoadFile(in byte) (ExecutableLoadFile, error) {
+	if ExecutableLoadFile(in) != ExecutableLoadFileLoaded {
+		return 0, fmt.Errorf("invalid executable load file life cycle state %02X", in)
+	}
+	return ExecutableLoadFileLoaded, nil
+}
+
+// String returns the name of the state
+func (e ExecutableLoadFile) String() string {
+	if e == ExecutableLoadFileLoaded {
+		return "LOADED"
+	}
+	return fmt.Sprintf("UNKNOWN(%02X)", byte(e))
+}
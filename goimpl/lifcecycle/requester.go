@@ -0,0 +1,24 @@
+package lifecycle
+
+// Requester describes the entity asking for a life cycle transition, as far as it decides which transitions it may ask for
+type Requester struct {
+	CardLock      bool // Holds the Card Lock privilege
+	CardTerminate bool // Holds the Card Terminate privilege
+	GlobalLock    bool // Holds the Global Lock privilege
+	// IssuerSecurityDomain is set when the requester is the issuer security domain
+	IssuerSecurityDomain bool
+	// Self is set when the requester is the application or security domain whose state changes
+	Self bool
+	// AssociatedSecurityDomain is set when the requester is the security domain the application or security domain is associated with
+	AssociatedSecurityDomain bool
+}
+
+// mayLock reports whether the requester may lock an application or security domain
+func (r Requester) mayLock() bool {
+	return r.Self || r.AssociatedSecurityDomain || r.GlobalLock
+}
+
+// mayUnlock reports whether the requester may unlock an application or security domain, which cannot unlock itself
+func (r Requester) mayUnlock() bool {
+	return r.AssociatedSecurityDomain || r.GlobalLock
+}
@@ -1,5 +1,7 @@
 package lifecycle
 
+import "fmt"
+
 // SecurityDomain is a Security Domain life cycle state
 type SecurityDomain int
 
@@ -13,3 +15,93 @@ const (
 	// SecurityDomainLocked is the LOCKED security domain life cycle state
 	SecurityDomainLocked SecurityDomain = 0x83
 )
+
+// ParseSecurityDomain parses a security domain life cycle state byte, including locked states
+func ParseSecurityDomain(in byte) (SecurityDomain, error) {
+	s := SecurityDomain(in)
+	switch s.Unlocked() {
+	case SecurityDomainInstalled, SecurityDomainSelectable, SecurityDomainPersonalized:
+		return s, nil
+	default:
+		return 0, fmt.Errorf("invalid security domain life cycle state %02X", in)
+	}
+}
+
+// Locked reports whether the state is LOCKED
+func (s SecurityDomain) Locked() bool {
+	return s&b8 != 0
+}
+
+// Unlocked returns the state the security domain was in before it was locked, which it returns to on unlock
+func (s SecurityDomain) Unlocked() SecurityDomain {
+	return s &^ b8
+}
+
+// Next lists the states the security domain may move to from this one, where SecurityDomainLocked stands for any locked state
+func (s SecurityDomain) Next() []SecurityDomain {
+	switch {
+	case s.Locked():
+		return []SecurityDomain{s.Unlocked()}
+	case s == SecurityDomainInstalled:
+		return []SecurityDomain{SecurityDomainSelectable, SecurityDomainLocked}
+	case s == SecurityDomainSelectable:
+		return []SecurityDomain{SecurityDomainPersonalized, SecurityDomainLocked}
+	case s == SecurityDomainPersonalized:
+		return []SecurityDomain{SecurityDomainLocked}
+	default:
+		return nil
+	}
+}
+
+// CanTransition reports whether the target is one of the next states, regardless of who asks for it
+func (s SecurityDomain) CanTransition(target SecurityDomain) bool {
+	for _, next := range s.Next() {
+		if next == target {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTransition returns an error if the security domain may not move to the target state, or the requester may not ask for it.
+// Only the security domain itself becomes PERSONALIZED, and it may lock but not unlock itself.
+func (s SecurityDomain) CheckTransition(target SecurityDomain, requester Requester) error {
+	if !s.CanTransition(target) {
+		return fmt.Errorf("security domain life cycle cannot change from %s to %s", s, target)
+	}
+	switch {
+	case target == SecurityDomainLocked:
+		if !requester.mayLock() {
+			return fmt.Errorf("locking a security domain requires being the security domain, its associated security domain or holding the Global Lock privilege")
+		}
+	case s.Locked():
+		if !requester.mayUnlock() {
+			return fmt.Errorf("unlocking a security domain requires being its associated security domain or holding the Global Lock privilege")
+		}
+	case s == SecurityDomainInstalled:
+		if !requester.AssociatedSecurityDomain {
+			return fmt.Errorf("only the associated security domain can make a security domain selectable")
+		}
+	default:
+		if !requester.Self {
+			return fmt.Errorf("only the security domain itself can become personalized")
+		}
+	}
+	return nil
+}
+
+// String returns the name of the state
+func (s SecurityDomain) String() string {
+	switch {
+	case s.Locked():
+		return "LOCKED"
+	case s == SecurityDomainInstalled:
+		return "INSTALLED"
+	case s == SecurityDomainSelectable:
+		return "SELECTABLE"
+	case s == SecurityDomainPersonalized:
+		return "PERSONALIZED"
+	default:
+		return fmt.Sprintf("UNKNOWN(%02X)", int(s))
+	}
+}
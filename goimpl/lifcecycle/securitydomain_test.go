@@ -0,0 +1,41 @@
+package lifecycle
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSecurityDomain(t *testing.T) {
+	for _, in := range []byte{0x03, 0x07, 0x0F, 0x83, 0x87, 0x8F} {
+		got, err := ParseSecurityDomain(in)
+		assert.NoError(t, err)
+		assert.Equal(t, SecurityDomain(in), got)
+	}
+	for _, in := range []byte{0x01, 0x1F, 0xFF} {
+		_, err := ParseSecurityDomain(in)
+		assert.Error(t, err)
+	}
+}
+
+func TestSecurityDomain_CheckTransition(t *testing.T) {
+	tests := []struct {
+		name      string
+		from, to  SecurityDomain
+		requester Requester
+		assertion assert.ErrorAssertionFunc
+	}{
+		{name: "personalize", from: SecurityDomainSelectable, to: SecurityDomainPersonalized, requester: Requester{Self: true}, assertion: assert.NoError},
+		{name: "personalize by other", from: SecurityDomainSelectable, to: SecurityDomainPersonalized, requester: Requester{AssociatedSecurityDomain: true}, assertion: assert.Error},
+		{name: "personalize installed", from: SecurityDomainInstalled, to: SecurityDomainPersonalized, requester: Requester{Self: true}, assertion: assert.Error},
+		{name: "lock", from: SecurityDomainPersonalized, to: SecurityDomainLocked, requester: Requester{GlobalLock: true}, assertion: assert.NoError},
+		{name: "unlock", from: 0x8F, to: SecurityDomainPersonalized, requester: Requester{AssociatedSecurityDomain: true}, assertion: assert.NoError},
+		{name: "unlock itself", from: 0x8F, to: SecurityDomainPersonalized, requester: Requester{Self: true}, assertion: assert.Error},
+		{name: "unpersonalize", from: SecurityDomainPersonalized, to: SecurityDomainSelectable, requester: Requester{Self: true}, assertion: assert.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.assertion(t, tt.from.CheckTransition(tt.to, tt.requester))
+		})
+	}
+}
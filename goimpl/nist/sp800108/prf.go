@@ -63,7 +63,7 @@ type PRFCMAC struct{}
 
 // OutputSizeBytes indicates the output size of the PRF in bytes, non-byte multiples of output length are not supported
 func (p *PRFCMAC) OutputSizeBytes() uint {
-	return 128
+	return 16
 }
 
 // Compute generatesd new data from a key and keying material data
@@ -94,3 +94,10 @@ func TestPRFHMAC_unimplementableNISTSP800108PRF(t *testing.T) {
 		})
 	}
 }
+
+func TestPRFCMAC_OutputSizeBytes(t *testing.T) {
+	p := &PRFCMAC{}
+	out, err := p.Compute(make([]byte, 16), []byte{0x01})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(len(out)), p.OutputSizeBytes())
+}
@@ -0,0 +1,13 @@
+package scp02
+
+// These bits exist for bitwise operation shorthand
+const (
+	b1 = 0b00000001
+	b2 = b1 << 1
+	b3 = b2 << 1
+	b4 = b3 << 1
+	b5 = b4 << 1
+	b6 = b5 << 1
+	b7 = b6 << 1
+	b8 = b7 << 1
+)
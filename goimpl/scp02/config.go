@@ -0,0 +1,52 @@
+package scp02
+
+// Configuration is the "i" parameter defined in appendix E.1.1 of the card spec, e.g. 0x15 or 0x55
+type Configuration struct {
+	ThreeKeys                 bool // 3 Secure Channel Keys (ENC, MAC, DEK), otherwise 1 Secure Channel base key
+	CMACOnUnmodifiedAPDU      bool // C-MAC is computed over the header as given, otherwise over the header modified for secure messaging (CLA with the secure messaging bit, Lc including the C-MAC)
+	ExplicitInitiation        bool // Secure channel is initiated explicitly with INITIALIZE UPDATE, otherwise implicitly
+	ICVMACOverAID             bool // The first ICV is a MAC over the AID of the selected application, otherwise zero
+	ICVEncryption             bool // ICVs after the first are encrypted before use in C-MAC computation
+	RMACSupported             bool // R-MAC is supported
+	PseudoRandomCardChallenge bool // The card challenge is generated with the well-known pseudo-random algorithm
+}
+
+// ParseConfiguration parses a byte into the config flags
+func ParseConfiguration(in byte) Configuration {
+	return Configuration{
+		ThreeKeys:                 in&b1 > 0,
+		CMACOnUnmodifiedAPDU:      in&b2 > 0,
+		ExplicitInitiation:        in&b3 > 0,
+		ICVMACOverAID:             in&b4 > 0,
+		ICVEncryption:             in&b5 > 0,
+		RMACSupported:             in&b6 > 0,
+		PseudoRandomCardChallenge: in&b7 > 0,
+	}
+}
+
+// ToByte converts the config flags to a byte
+func (c Configuration) ToByte() byte {
+	out := byte(0)
+	if c.ThreeKeys {
+		out = out | b1
+	}
+	if c.CMACOnUnmodifiedAPDU {
+		out = out | b2
+	}
+	if c.ExplicitInitiation {
+		out = out | b3
+	}
+	if c.ICVMACOverAID {
+		out = out | b4
+	}
+	if c.ICVEncryption {
+		out = out | b5
+	}
+	if c.RMACSupported {
+		out = out | b6
+	}
+	if c.PseudoRandomCardChallenge {
+		out = out | b7
+	}
+	return out
+}
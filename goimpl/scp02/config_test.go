@@ -0,0 +1,54 @@
+package scp02
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseConfiguration(t *testing.T) {
+	type args struct {
+		in byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want Configuration
+	}{
+		{
+			name: "i=15",
+			args: args{in: 0x15},
+			want: Configuration{
+				ThreeKeys:          true,
+				ExplicitInitiation: true,
+				ICVEncryption:      true,
+			},
+		},
+		{
+			name: "i=55",
+			args: args{in: 0x55},
+			want: Configuration{
+				ThreeKeys:                 true,
+				ExplicitInitiation:        true,
+				ICVEncryption:             true,
+				PseudoRandomCardChallenge: true,
+			},
+		},
+		{
+			name: "i=1A",
+			args: args{in: 0x1A},
+			want: Configuration{
+				CMACOnUnmodifiedAPDU: true,
+				ICVMACOverAID:        true,
+				ICVEncryption:        true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseConfiguration(tt.args.in)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.args.in, got.ToByte())
+		})
+	}
+}
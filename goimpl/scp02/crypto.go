@@ -0,0 +1,60 @@
+package scp02
+
+import (
+	"crypto/des"
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+const blockSize = des.BlockSize
+
+// pad80 appends 0x80 and as many zeroes as required to reach a multiple of the block size, padding is always added even to aligned data
+func pad80(data []byte) []byte {
+	out := make([]byte, (len(data)/blockSize+1)*blockSize)
+	copy(out, data)
+	out[len(data)] = 0x80
+	return out
+}
+
+// fullTripleDESMAC is the full triple DES MAC (ISO 9797-1 MAC algorithm 1 with triple DES) over padded data, used for cryptograms
+func fullTripleDESMAC(key keystore.Key, icv, data []byte) ([]byte, error) {
+	if key == nil {
+		return nil, fmt.Errorf("nil MAC key")
+	}
+	enc, err := key.Encrypt(keystore.ModeCBC, icv, pad80(data))
+	if err != nil {
+		return nil, err
+	}
+	return enc[len(enc)-blockSize:], nil
+}
+
+// retailMAC is the single DES plus final triple DES MAC (ISO 9797-1 MAC algorithm 3) over padded data, used for C-MAC and R-MAC.
+// left is the single DES key equal to the first half of the triple DES key.
+func retailMAC(key, left keystore.Key, icv, data []byte) ([]byte, error) {
+	if key == nil || left == nil {
+		return nil, fmt.Errorf("nil MAC key")
+	}
+	padded := pad80(data)
+	chain := make([]byte, blockSize)
+	if icv != nil {
+		copy(chain, icv)
+	}
+	last := len(padded) - blockSize
+	if last > 0 {
+		enc, err := left.Encrypt(keystore.ModeCBC, chain, padded[:last])
+		if err != nil {
+			return nil, err
+		}
+		chain = enc[last-blockSize:]
+	}
+	return key.Encrypt(keystore.ModeCBC, chain, padded[last:])
+}
+
+// encryptICV encrypts an ICV with single DES in ECB mode under the first half of the C-MAC session key
+func encryptICV(left keystore.Key, icv []byte) ([]byte, error) {
+	if left == nil {
+		return nil, fmt.Errorf("nil ICV encryption key")
+	}
+	return left.Encrypt(keystore.ModeECB, nil, icv)
+}
@@ -0,0 +1,55 @@
+package scp02
+
+import (
+	"bytes"
+	"crypto/des"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_retailMAC(t *testing.T) {
+	keys, err := DeriveSessionKeys(mustKeys(defaultKey, defaultKey, defaultKey), Configuration{ThreeKeys: true}, [2]byte{0x00, 0x01})
+	assert.NoError(t, err)
+	// Recompute the session key in the clear and apply ISO 9797-1 MAC algorithm 3 as the final decrypt-encrypt of the single DES CBC-MAC
+	derivation := make([]byte, 16)
+	derivation[0], derivation[1], derivation[3] = 0x01, 0x01, 0x01
+	full, err := des.NewTripleDESCipher(append(append([]byte{}, defaultKey...), defaultKey[:8]...))
+	assert.NoError(t, err)
+	sessionKey := make([]byte, 16)
+	full.Encrypt(sessionKey[:8], derivation[:8])
+	for i := 0; i < 8; i++ {
+		derivation[8+i] ^= sessionKey[i]
+	}
+	full.Encrypt(sessionKey[8:], derivation[8:])
+	k1, _ := des.NewCipher(sessionKey[:8])
+	k2, _ := des.NewCipher(sessionKey[8:])
+	tests := []struct {
+		name string
+		icv  []byte
+		data []byte
+	}{
+		{name: "one block", data: []byte{0x84, 0x82, 0x01, 0x00}},
+		{name: "three blocks with ICV", icv: bytes.Repeat([]byte{0xA5}, 8), data: bytes.Repeat([]byte{0x5A}, 20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := make([]byte, 8)
+			if tt.icv != nil {
+				copy(chain, tt.icv)
+			}
+			padded := pad80(tt.data)
+			for i := 0; i < len(padded); i += 8 {
+				for j := 0; j < 8; j++ {
+					chain[j] ^= padded[i+j]
+				}
+				k1.Encrypt(chain, chain)
+			}
+			k2.Decrypt(chain, chain)
+			k1.Encrypt(chain, chain)
+			got, err := retailMAC(keys.CMAC, keys.cmacLeft, tt.icv, tt.data)
+			assert.NoError(t, err)
+			assert.Equal(t, chain, got)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package scp02
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+// InitializeUpdateResponse is the card's response to INITIALIZE UPDATE
+type InitializeUpdateResponse struct {
+	KeyDiversificationData [10]byte
+	KeyVersionNumber       byte
+	SCPIdentifier          byte
+	SequenceCounter        [2]byte
+	CardChallenge          [6]byte
+	CardCryptogram         [8]byte
+}
+
+// ParseInitializeUpdateResponse parses the 28 byte response data of INITIALIZE UPDATE
+func ParseInitializeUpdateResponse(data []byte) (res InitializeUpdateResponse, err error) {
+	if len(data) != 28 {
+		err = fmt.Errorf("INITIALIZE UPDATE response must be 28 bytes, got %d", len(data))
+		return
+	}
+	copy(res.KeyDiversificationData[:], data[0:10])
+	res.KeyVersionNumber = data[10]
+	res.SCPIdentifier = data[11]
+	copy(res.SequenceCounter[:], data[12:14])
+	copy(res.CardChallenge[:], data[14:20])
+	copy(res.CardCryptogram[:], data[20:28])
+	return
+}
+
+// ToBytes converts the response back to its 28 byte encoding
+func (r InitializeUpdateResponse) ToBytes() []byte {
+	out := make([]byte, 0, 28)
+	out = append(out, r.KeyDiversificationData[:]...)
+	out = append(out, r.KeyVersionNumber, r.SCPIdentifier)
+	out = append(out, r.SequenceCounter[:]...)
+	out = append(out, r.CardChallenge[:]...)
+	return append(out, r.CardCryptogram[:]...)
+}
+
+// CardCryptogram computes the card cryptogram over the host challenge, sequence counter and card challenge with the S-ENC session key
+func CardCryptogram(sessionENC keystore.Key, hostChallenge [8]byte, sequenceCounter [2]byte, cardChallenge [6]byte) ([]byte, error) {
+	data := make([]byte, 0, 16)
+	data = append(data, hostChallenge[:]...)
+	data = append(data, sequenceCounter[:]...)
+	data = append(data, cardChallenge[:]...)
+	return fullTripleDESMAC(sessionENC, nil, data)
+}
+
+// HostCryptogram computes the host cryptogram over the sequence counter, card challenge and host challenge with the S-ENC session key
+func HostCryptogram(sessionENC keystore.Key, hostChallenge [8]byte, sequenceCounter [2]byte, cardChallenge [6]byte) ([]byte, error) {
+	data := make([]byte, 0, 16)
+	data = append(data, sequenceCounter[:]...)
+	data = append(data, cardChallenge[:]...)
+	data = append(data, hostChallenge[:]...)
+	return fullTripleDESMAC(sessionENC, nil, data)
+}
@@ -0,0 +1,18 @@
+// Package scp02 implements the Secure Channel Protocol 02
+package scp02
+
+/*
+
+Mutual Authentication
+
+1. Application sends INITIALIZE UPDATE to the card with a random 8 byte 'host' challenge
+2. Card derives session keys from its static keys and sequence counter, then generates a 6 byte 'card' challenge
+3. Sequence counter + card challenge + card cryptogram + key information is transmitted to application
+4. Application derives the same session keys from the sequence counter and re-derives card cryptogram to confirm card identity
+5. Application does the same basic thing to create host cryptogram, to pass back to the card on EXTERNAL AUTHENTICATE with a C-MAC
+6. Card can now re-derive host cryptogram to confirm application identity
+
+Every command after that carries a C-MAC chained from the previous one, optionally with the data field enciphered (C-DECRYPTION)
+and optionally with an R-MAC appended to every response.
+
+*/ //
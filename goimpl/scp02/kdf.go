@@ -0,0 +1,130 @@
+package scp02
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+// DerivationConstant is a session key derivation constant
+type DerivationConstant uint16
+
+const (
+	// DerivationCMAC is the C-MAC session key derivation constant
+	DerivationCMAC DerivationConstant = 0x0101
+	// DerivationRMAC is the R-MAC session key derivation constant
+	DerivationRMAC DerivationConstant = 0x0102
+	// DerivationDEK is the DEK session key derivation constant
+	DerivationDEK DerivationConstant = 0x0181
+	// DerivationENC is the ENC session key derivation constant
+	DerivationENC DerivationConstant = 0x0182
+)
+
+// Keys is a set of two-key triple DES keys. With a single Secure Channel base key (see Configuration.ThreeKeys), only ENC is used.
+type Keys struct {
+	ENC keystore.Key
+	MAC keystore.Key
+	DEK keystore.Key
+}
+
+// NewKeys creates a set of static keys held in software from the raw key values, MAC and DEK may be nil when only ENC is used
+func NewKeys(enc, mac, dek []byte) (keys Keys, err error) {
+	if keys.ENC, err = keystore.NewSoftwareKey(keystore.AlgorithmTripleDES, enc); err != nil {
+		err = fmt.Errorf("ENC: %w", err)
+		return
+	}
+	if mac != nil {
+		if keys.MAC, err = keystore.NewSoftwareKey(keystore.AlgorithmTripleDES, mac); err != nil {
+			err = fmt.Errorf("MAC: %w", err)
+			return
+		}
+	}
+	if dek != nil {
+		if keys.DEK, err = keystore.NewSoftwareKey(keystore.AlgorithmTripleDES, dek); err != nil {
+			err = fmt.Errorf("DEK: %w", err)
+		}
+	}
+	return
+}
+
+// SessionKeys are the keys derived for a single secure channel session
+type SessionKeys struct {
+	ENC  keystore.Key
+	CMAC keystore.Key
+	RMAC keystore.Key
+	DEK  keystore.Key
+	// cmacLeft and rmacLeft are single DES keys equal to the first half of CMAC and RMAC, for the single DES stages of the retail MAC
+	cmacLeft keystore.Key
+	rmacLeft keystore.Key
+}
+
+// derivationData is the constant, counter and 12 bytes of zeroes
+func derivationData(constant DerivationConstant, sequenceCounter [2]byte) ([]byte, error) {
+	switch constant {
+	case DerivationCMAC, DerivationRMAC, DerivationDEK, DerivationENC:
+		// Supported value
+	default:
+		return nil, fmt.Errorf("invalid derivation constant: %04X", uint16(constant))
+	}
+	data := make([]byte, 16)
+	data[0] = byte(constant >> 8)
+	data[1] = byte(constant)
+	data[2] = sequenceCounter[0]
+	data[3] = sequenceCounter[1]
+	return data, nil
+}
+
+// deriveSessionKey encrypts the derivation data with the static key in triple DES CBC mode, using the leading length bytes as the key
+func deriveSessionKey(staticKey keystore.Key, constant DerivationConstant, sequenceCounter [2]byte, algorithm keystore.Algorithm, length int) (keystore.Key, error) {
+	if staticKey == nil {
+		return nil, fmt.Errorf("nil static key")
+	}
+	data, err := derivationData(constant, sequenceCounter)
+	if err != nil {
+		return nil, err
+	}
+	return staticKey.Derive(keystore.Derivation{
+		Method:    keystore.DeriveEncrypt,
+		Algorithm: algorithm,
+		Length:    length,
+		Mode:      keystore.ModeCBC,
+		Data:      data,
+	})
+}
+
+// DeriveSessionKey derives a session key from a static key and the sequence counter, by encrypting the constant, counter and 12 bytes of zeroes with triple DES in CBC mode
+func DeriveSessionKey(staticKey keystore.Key, constant DerivationConstant, sequenceCounter [2]byte) (keystore.Key, error) {
+	return deriveSessionKey(staticKey, constant, sequenceCounter, keystore.AlgorithmTripleDES, 16)
+}
+
+// DeriveSessionKeys derives the full set of session keys from the static keys and the sequence counter
+func DeriveSessionKeys(static Keys, config Configuration, sequenceCounter [2]byte) (keys SessionKeys, err error) {
+	encBase, macBase, dekBase := static.ENC, static.MAC, static.DEK
+	if !config.ThreeKeys {
+		macBase, dekBase = encBase, encBase
+	}
+	if keys.ENC, err = DeriveSessionKey(encBase, DerivationENC, sequenceCounter); err != nil {
+		err = fmt.Errorf("deriving S-ENC: %w", err)
+		return
+	}
+	if keys.CMAC, err = DeriveSessionKey(macBase, DerivationCMAC, sequenceCounter); err != nil {
+		err = fmt.Errorf("deriving C-MAC key: %w", err)
+		return
+	}
+	if keys.cmacLeft, err = deriveSessionKey(macBase, DerivationCMAC, sequenceCounter, keystore.AlgorithmDES, 8); err != nil {
+		err = fmt.Errorf("deriving C-MAC key: %w", err)
+		return
+	}
+	if keys.RMAC, err = DeriveSessionKey(macBase, DerivationRMAC, sequenceCounter); err != nil {
+		err = fmt.Errorf("deriving R-MAC key: %w", err)
+		return
+	}
+	if keys.rmacLeft, err = deriveSessionKey(macBase, DerivationRMAC, sequenceCounter, keystore.AlgorithmDES, 8); err != nil {
+		err = fmt.Errorf("deriving R-MAC key: %w", err)
+		return
+	}
+	if keys.DEK, err = DeriveSessionKey(dekBase, DerivationDEK, sequenceCounter); err != nil {
+		err = fmt.Errorf("deriving DEK: %w", err)
+	}
+	return
+}
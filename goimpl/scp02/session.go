@@ -0,0 +1,276 @@
+package scp02
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/llkennedy/globalplatform/goimpl/keydiv"
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+// SCPIdentifier is the SCP identifier returned by the card in the key information of INITIALIZE UPDATE
+const SCPIdentifier byte = 0x02
+
+// Options configures the opening of a Session
+type Options struct {
+	KeyVersionNumber byte                 // 0 selects the first available key set
+	SecurityLevel    gpapdu.SecurityLevel // Applied to every command after EXTERNAL AUTHENTICATE
+	Configuration    Configuration        // The "i" parameter supported by the card
+	LogicalChannel   uint8
+	AID              []byte    // AID of the selected application, only required when Configuration.ICVMACOverAID is set
+	Rand             io.Reader // Source of the host challenge, crypto/rand is used when nil
+	// Diversification derives the card's static keys from the given master keys and the key diversification data in the INITIALIZE UPDATE response, the keys are used as given when nil
+	Diversification keydiv.Method
+}
+
+// Session is an open SCP02 secure channel, which wraps every command in secure messaging before sending it on the underlying transport.
+// Session implements apdu.Transport, so everything which sends commands on a transport can send them on a secure channel instead.
+type Session struct {
+	mutex         sync.Mutex
+	transport     apdu.Transport
+	keys          SessionKeys
+	config        Configuration
+	securityLevel gpapdu.SecurityLevel
+	cmacChaining  []byte
+	rmacChaining  []byte
+}
+
+// Open opens a secure channel with INITIALIZE UPDATE and EXTERNAL AUTHENTICATE, authenticating the card and the host to each other
+func Open(transport apdu.Transport, static Keys, opts Options) (*Session, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("cannot open secure channel on nil transport")
+	}
+	if !opts.Configuration.ExplicitInitiation {
+		return nil, fmt.Errorf("implicit initiation is not supported")
+	}
+	if err := opts.SecurityLevel.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid security level: %w", err)
+	}
+	if opts.SecurityLevel.REncryption() {
+		return nil, fmt.Errorf("R-ENCRYPTION is not defined for SCP02")
+	}
+	if opts.SecurityLevel.RMAC() && !opts.Configuration.RMACSupported {
+		return nil, fmt.Errorf("R-MAC requested but not supported by configuration %02X", opts.Configuration.ToByte())
+	}
+	randR := opts.Rand
+	if randR == nil {
+		randR = rand.Reader
+	}
+	var hostChallenge [8]byte
+	if _, err := io.ReadFull(randR, hostChallenge[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate host challenge: %w", err)
+	}
+	class := gpapdu.Class{
+		IsGPCommand: true,
+		InterindustryClass: apdu.InterindustryClass{
+			LogicalChannelNumber: opts.LogicalChannel,
+			SecureMessaging:      apdu.CLASMNone,
+		},
+	}
+	res, err := gpapdu.SendOnTransport(transport, gpapdu.Command{
+		Class:              class,
+		Instruction:        gpapdu.InstructionInitializeUpdate,
+		P1:                 opts.KeyVersionNumber,
+		P2:                 0x00, // Required to always be 0x00
+		Data:               hostChallenge[:],
+		ExpectResponseData: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sending INITIALIZE UPDATE: %w", err)
+	}
+	if err = res.GetStatus().Error(); err != nil {
+		return nil, fmt.Errorf("INITIALIZE UPDATE: %w", err)
+	}
+	initRes, err := ParseInitializeUpdateResponse(res.Data)
+	if err != nil {
+		return nil, err
+	}
+	if initRes.SCPIdentifier != SCPIdentifier {
+		return nil, fmt.Errorf("card responded with SCP identifier %02X, expected %02X", initRes.SCPIdentifier, SCPIdentifier)
+	}
+	if opts.Diversification != nil {
+		diversified, err := keydiv.Diversify(opts.Diversification, keydiv.Keys(static), initRes.KeyDiversificationData)
+		if err != nil {
+			return nil, fmt.Errorf("diversifying static keys: %w", err)
+		}
+		static = Keys(diversified)
+	}
+	keys, err := DeriveSessionKeys(static, opts.Configuration, initRes.SequenceCounter)
+	if err != nil {
+		return nil, err
+	}
+	expectedCardCryptogram, err := CardCryptogram(keys.ENC, hostChallenge, initRes.SequenceCounter, initRes.CardChallenge)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(expectedCardCryptogram, initRes.CardCryptogram[:]) != 1 {
+		return nil, fmt.Errorf("card cryptogram did not match, card could not be authenticated")
+	}
+	hostCryptogram, err := HostCryptogram(keys.ENC, hostChallenge, initRes.SequenceCounter, initRes.CardChallenge)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{
+		transport: transport,
+		keys:      keys,
+		config:    opts.Configuration,
+		// EXTERNAL AUTHENTICATE always carries a C-MAC, regardless of the requested level
+		securityLevel: gpapdu.SecurityLevelCMAC,
+	}
+	if opts.Configuration.ICVMACOverAID {
+		if len(opts.AID) == 0 {
+			return nil, fmt.Errorf("configuration requires the AID of the selected application for the first ICV")
+		}
+		if s.cmacChaining, err = retailMAC(keys.CMAC, keys.cmacLeft, nil, opts.AID); err != nil {
+			return nil, err
+		}
+	}
+	externalAuth, err := gpapdu.Command{
+		Class:       class,
+		Instruction: apdu.InstructionExternalMutualAuthenticate,
+		P1:          byte(opts.SecurityLevel),
+		P2:          0x00,
+		Data:        hostCryptogram,
+	}.ToAPDU()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := s.Wrap(externalAuth)
+	if err != nil {
+		return nil, fmt.Errorf("wrapping EXTERNAL AUTHENTICATE: %w", err)
+	}
+	res, err = transport.Send(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("sending EXTERNAL AUTHENTICATE: %w", err)
+	}
+	if err = res.GetStatus().Error(); err != nil {
+		return nil, fmt.Errorf("EXTERNAL AUTHENTICATE: %w", err)
+	}
+	s.securityLevel = opts.SecurityLevel
+	if s.securityLevel.RMAC() {
+		s.rmacChaining = make([]byte, blockSize)
+	}
+	return s, nil
+}
+
+// SecurityLevel returns the security level of the session
+func (s *Session) SecurityLevel() gpapdu.SecurityLevel {
+	return s.securityLevel
+}
+
+// Send wraps the command, sends it on the underlying transport and unwraps the response
+func (s *Session) Send(cmd apdu.Command) (apdu.Response, error) {
+	if s == nil || s.transport == nil {
+		return apdu.Response{}, fmt.Errorf("invalid session, must be opened before use")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	wrapped, err := s.Wrap(cmd)
+	if err != nil {
+		return apdu.Response{}, fmt.Errorf("wrapping command: %w", err)
+	}
+	res, err := s.transport.Send(wrapped)
+	if err != nil {
+		return res, err
+	}
+	return s.Unwrap(cmd, res)
+}
+
+// Wrap applies C-MAC and C-DECRYPTION to a plaintext command according to the session's security level.
+// The C-MAC is always computed over the plaintext data field, which is then enciphered if required.
+// Wrap advances the MAC chaining value, so every wrapped command must be sent to the card in the same order.
+func (s *Session) Wrap(cmd apdu.Command) (apdu.Command, error) {
+	if !s.securityLevel.CMAC() {
+		return cmd, nil
+	}
+	original, err := gpapdu.ClassFromAPDUClass(cmd.Class)
+	if err != nil {
+		return apdu.Command{}, err
+	}
+	class := original.WithSecureMessaging()
+	if length := len(cmd.Data) + blockSize; length > 255 {
+		return apdu.Command{}, fmt.Errorf("command data too long for secure messaging: %d bytes", length)
+	}
+	icv := s.cmacChaining
+	if icv != nil && s.config.ICVEncryption {
+		if icv, err = encryptICV(s.keys.cmacLeft, icv); err != nil {
+			return apdu.Command{}, err
+		}
+	}
+	macInput := []byte{class.ToClassByte(), byte(cmd.Instruction), cmd.P1, cmd.P2, byte(len(cmd.Data) + blockSize)}
+	if s.config.CMACOnUnmodifiedAPDU {
+		macInput = []byte{original.ToClassByte(), byte(cmd.Instruction), cmd.P1, cmd.P2, byte(len(cmd.Data))}
+	}
+	macInput = append(macInput, cmd.Data...)
+	mac, err := retailMAC(s.keys.CMAC, s.keys.cmacLeft, icv, macInput)
+	if err != nil {
+		return apdu.Command{}, err
+	}
+	data := cmd.Data
+	if s.securityLevel.CDecryption() && len(data) > 0 {
+		if data, err = s.keys.ENC.Encrypt(keystore.ModeCBC, nil, pad80(data)); err != nil {
+			return apdu.Command{}, err
+		}
+		if length := len(data) + blockSize; length > 255 {
+			return apdu.Command{}, fmt.Errorf("enciphered command data too long for secure messaging: %d bytes", length)
+		}
+	}
+	s.cmacChaining = mac
+	wrapped := cmd
+	wrapped.Class = class
+	wrapped.Data = append(append([]byte{}, data...), mac...)
+	return wrapped, nil
+}
+
+// Unwrap verifies and strips the R-MAC from the response to a plaintext command, if the session's security level requires it
+func (s *Session) Unwrap(cmd apdu.Command, res apdu.Response) (apdu.Response, error) {
+	if !s.securityLevel.RMAC() {
+		return res, nil
+	}
+	status := res.GetStatus().Raw()
+	if len(res.Data) < blockSize {
+		if res.GetStatus().Error() != nil {
+			// Errors may be returned without an R-MAC
+			return res, nil
+		}
+		return apdu.Response{}, fmt.Errorf("response too short to contain R-MAC: %d bytes", len(res.Data))
+	}
+	data := res.Data[:len(res.Data)-blockSize]
+	receivedMAC := res.Data[len(res.Data)-blockSize:]
+	var classByte byte
+	if cmd.Class != nil {
+		classByte = cmd.Class.ToClassByte() &^ (b1 | b2 | b3)
+	}
+	macInput := []byte{classByte, byte(cmd.Instruction), cmd.P1, cmd.P2, byte(len(cmd.Data))}
+	macInput = append(macInput, cmd.Data...)
+	macInput = append(macInput, byte(len(data)))
+	macInput = append(macInput, data...)
+	macInput = append(macInput, status.SW1, status.SW2)
+	mac, err := retailMAC(s.keys.RMAC, s.keys.rmacLeft, s.rmacChaining, macInput)
+	if err != nil {
+		return apdu.Response{}, err
+	}
+	if subtle.ConstantTimeCompare(mac, receivedMAC) != 1 {
+		return apdu.Response{}, fmt.Errorf("R-MAC verification failed")
+	}
+	s.rmacChaining = mac
+	unwrapped := res
+	unwrapped.Data = nil
+	if len(data) > 0 {
+		unwrapped.Data = append([]byte{}, data...)
+	}
+	return unwrapped, nil
+}
+
+// EncryptSensitiveData encrypts block-aligned sensitive data such as secret key components with the session DEK, in triple DES ECB mode
+func (s *Session) EncryptSensitiveData(data []byte) ([]byte, error) {
+	if s == nil {
+		return nil, fmt.Errorf("invalid session, must be opened before use")
+	}
+	return s.keys.DEK.Encrypt(keystore.ModeECB, nil, data)
+}
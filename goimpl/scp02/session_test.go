@@ -0,0 +1,223 @@
+package scp02
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/stretchr/testify/assert"
+)
+
+var defaultKey = []byte{0x40, 0x41, 0x42, 0x43, 0x44, 0x45, 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F}
+
+func mustKeys(enc, mac, dek []byte) Keys {
+	keys, err := NewKeys(enc, mac, dek)
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
+
+// scriptedCard answers INITIALIZE UPDATE with a fixed response and every other command with fixed response data, recording the commands it receives
+type scriptedCard struct {
+	initializeUpdate []byte
+	responseData     []byte
+	commands         [][]byte
+}
+
+func (c *scriptedCard) Send(cmd apdu.Command) (apdu.Response, error) {
+	c.commands = append(c.commands, cmd.ToBytes())
+	ok := apdu.RawStatus{SW1: 0x90}.Identify()
+	switch cmd.Instruction {
+	case gpapdu.InstructionInitializeUpdate:
+		return apdu.Response{Data: c.initializeUpdate, Status: ok}, nil
+	case apdu.InstructionExternalMutualAuthenticate:
+		return apdu.Response{Status: ok}, nil
+	}
+	return apdu.Response{Data: c.responseData, Status: ok}, nil
+}
+
+func mustHex(in string) []byte {
+	out, err := hex.DecodeString(in)
+	if err != nil {
+		panic(err)
+	}
+	return out
+}
+
+// The expected commands were computed independently from GP Card Specification 2.2 Appendix E, with the default 404142...4F keys
+func TestOpen(t *testing.T) {
+	firstHostChallenge := mustHex("1112131415161718")
+	firstInitializeUpdate := mustHex("00000000000000000000" + "2002" + "002A" + "010203040506" + "CF4A149D57B9B400")
+	secondHostChallenge := mustHex("2122232425262728")
+	secondInitializeUpdate := mustHex("00000000000000000000" + "2002" + "0107" + "A1A2A3A4A5A6" + "6BB94E8787E8081A")
+	type args struct {
+		static Keys
+		opts   Options
+	}
+	tests := []struct {
+		name             string
+		args             args
+		hostChallenge    []byte
+		initializeUpdate []byte
+		responseData     []byte
+		wantCommands     [][]byte
+		wantResponse     []byte
+		assertion        assert.ErrorAssertionFunc
+	}{
+		{
+			name: "i=15 C-MAC on modified APDU with C-DECRYPTION",
+			args: args{
+				static: mustKeys(defaultKey, defaultKey, defaultKey),
+				opts: Options{
+					SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption,
+					Configuration: ParseConfiguration(0x15),
+				},
+			},
+			hostChallenge:    firstHostChallenge,
+			initializeUpdate: firstInitializeUpdate,
+			responseData:     []byte{0x00},
+			wantCommands: [][]byte{
+				mustHex("8050000008" + "1112131415161718" + "00"),
+				mustHex("8482030010" + "5E5CE1F261E7F29A" + "5D9830B8F4CB0207"),
+				mustHex("84E2000010" + "CC20F61115401CE7" + "CD8D2087FF66348D"),
+			},
+			wantResponse: []byte{0x00},
+			assertion:    assert.NoError,
+		},
+		{
+			name: "i=55 C-MAC on modified APDU with C-DECRYPTION",
+			args: args{
+				static: mustKeys(defaultKey, defaultKey, defaultKey),
+				opts: Options{
+					SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption,
+					Configuration: ParseConfiguration(0x55),
+				},
+			},
+			hostChallenge:    secondHostChallenge,
+			initializeUpdate: secondInitializeUpdate,
+			wantCommands: [][]byte{
+				mustHex("8050000008" + "2122232425262728" + "00"),
+				mustHex("8482030010" + "39ECEBB5ADAB667A" + "139DE83F8B5FA628"),
+				mustHex("84E2000010" + "C400331FEE9340D7" + "2CE41391AB5192E9"),
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "i=75 C-MAC on modified APDU with C-DECRYPTION and R-MAC",
+			args: args{
+				static: mustKeys(defaultKey, defaultKey, defaultKey),
+				opts: Options{
+					SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption | gpapdu.SecurityLevelRMAC,
+					Configuration: ParseConfiguration(0x75),
+				},
+			},
+			hostChallenge:    secondHostChallenge,
+			initializeUpdate: secondInitializeUpdate,
+			responseData:     mustHex("FC5A90F8326B89EC"),
+			wantCommands: [][]byte{
+				mustHex("8050000008" + "2122232425262728" + "00"),
+				mustHex("8482130010" + "39ECEBB5ADAB667A" + "CDD04B74C1BA505B"),
+				mustHex("84E2000010" + "C400331FEE9340D7" + "F26E7724FD69A1BB"),
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "i=17 C-MAC on unmodified APDU",
+			args: args{
+				static: mustKeys(defaultKey, defaultKey, defaultKey),
+				opts: Options{
+					SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption,
+					Configuration: ParseConfiguration(0x17),
+				},
+			},
+			hostChallenge:    firstHostChallenge,
+			initializeUpdate: firstInitializeUpdate,
+			wantCommands: [][]byte{
+				mustHex("8050000008" + "1112131415161718" + "00"),
+				mustHex("8482030010" + "5E5CE1F261E7F29A" + "6D724746E221B207"),
+				mustHex("84E2000010" + "CC20F61115401CE7" + "FB536B99A457B3D1"),
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name: "i=75 bad R-MAC",
+			args: args{
+				static: mustKeys(defaultKey, defaultKey, defaultKey),
+				opts: Options{
+					SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption | gpapdu.SecurityLevelRMAC,
+					Configuration: ParseConfiguration(0x75),
+				},
+			},
+			hostChallenge:    secondHostChallenge,
+			initializeUpdate: secondInitializeUpdate,
+			responseData:     mustHex("FC5A90F8326B89ED"),
+			assertion:        assert.Error,
+		},
+		{
+			name: "wrong keys",
+			args: args{
+				static: mustKeys(bytes.Repeat([]byte{0x11}, 16), defaultKey, defaultKey),
+				opts: Options{
+					SecurityLevel: gpapdu.SecurityLevelCMAC,
+					Configuration: ParseConfiguration(0x15),
+				},
+			},
+			hostChallenge:    firstHostChallenge,
+			initializeUpdate: firstInitializeUpdate,
+			wantCommands: [][]byte{
+				mustHex("8050000008" + "1112131415161718" + "00"),
+			},
+			assertion: assert.Error,
+		},
+		{
+			name: "implicit initiation",
+			args: args{
+				static: mustKeys(defaultKey, defaultKey, defaultKey),
+				opts: Options{
+					Configuration: ParseConfiguration(0x11),
+				},
+			},
+			hostChallenge: firstHostChallenge,
+			assertion:     assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &scriptedCard{initializeUpdate: tt.initializeUpdate, responseData: tt.responseData}
+			tt.args.opts.Rand = bytes.NewReader(tt.hostChallenge)
+			err := func() error {
+				s, err := Open(card, tt.args.static, tt.args.opts)
+				if err != nil {
+					return err
+				}
+				res, err := s.Send(apdu.Command{
+					Class:       gpapdu.Class{IsGPCommand: true},
+					Instruction: gpapdu.InstructionStoreData,
+					Data:        []byte{0x01, 0x02, 0x03, 0x04, 0x05},
+				})
+				if err != nil {
+					return err
+				}
+				assert.Equal(t, tt.wantResponse, res.Data)
+				return nil
+			}()
+			tt.assertion(t, err)
+			if tt.wantCommands != nil {
+				assert.Equal(t, tt.wantCommands, card.commands)
+			}
+		})
+	}
+}
+
+func TestSession_Wrap(t *testing.T) {
+	keys, err := DeriveSessionKeys(mustKeys(defaultKey, defaultKey, defaultKey), ParseConfiguration(0x15), [2]byte{0x00, 0x01})
+	assert.NoError(t, err)
+	s := &Session{keys: keys, config: ParseConfiguration(0x15), securityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption}
+	_, err = s.Wrap(apdu.Command{Class: gpapdu.Class{IsGPCommand: true}, Instruction: gpapdu.InstructionStoreData, Data: make([]byte, 248)})
+	assert.EqualError(t, err, "command data too long for secure messaging: 256 bytes")
+	_, err = s.Wrap(apdu.Command{Class: gpapdu.Class{IsGPCommand: true}, Instruction: gpapdu.InstructionStoreData, Data: make([]byte, 240)})
+	assert.EqualError(t, err, "enciphered command data too long for secure messaging: 256 bytes")
+}
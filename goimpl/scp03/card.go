@@ -0,0 +1,284 @@
+package scp03
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+)
+
+// CardOptions configures the card side of SCP03
+type CardOptions struct {
+	KeyVersionNumber       byte
+	KeyDiversificationData [10]byte
+	Configuration          Configuration
+	// AID of the security domain, used with SequenceCounter to generate pseudo-random card challenges
+	AID []byte
+	// SequenceCounter is the 3 byte counter used for the next pseudo-random card challenge, it is incremented on every INITIALIZE UPDATE
+	SequenceCounter uint32
+	// Application receives every command after secure messaging has been removed, every such command is rejected with 6D00 when nil
+	Application apdu.Transport
+	Rand        io.Reader // Source of random card challenges, crypto/rand is used when nil
+}
+
+// Card is the card side of SCP03 for an emulated card.
+// It answers INITIALIZE UPDATE and EXTERNAL AUTHENTICATE itself, and removes secure messaging from every other command before passing it to the application, then applies secure messaging to the application's response.
+// Card implements apdu.Transport, so a Session can be opened directly on it.
+type Card struct {
+	mutex             sync.Mutex
+	static            Keys
+	opts              CardOptions
+	keys              SessionKeys
+	hostChallenge     []byte
+	cardChallenge     []byte
+	initialized       bool // INITIALIZE UPDATE has been answered, EXTERNAL AUTHENTICATE is expected next
+	authenticated     bool
+	securityLevel     gpapdu.SecurityLevel
+	macLength         int
+	macChaining       []byte
+	encryptionCounter uint64
+}
+
+// NewCard creates the card side of SCP03 with the static keys
+func NewCard(static Keys, opts CardOptions) (*Card, error) {
+	if err := static.validate(); err != nil {
+		return nil, err
+	}
+	if opts.Configuration.PseudoRandomChallenge && len(opts.AID) == 0 {
+		return nil, fmt.Errorf("pseudo-random card challenges require the AID")
+	}
+	if opts.SequenceCounter > 0xFFFFFF {
+		return nil, fmt.Errorf("sequence counter %X does not fit in 3 bytes", opts.SequenceCounter)
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.Reader
+	}
+	c := &Card{
+		static: static,
+		opts:   opts,
+	}
+	c.macLength = 16
+	if opts.Configuration.LegacyS8Mode {
+		c.macLength = 8
+	}
+	return c, nil
+}
+
+// Authenticated returns whether a secure channel is currently open
+func (c *Card) Authenticated() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.authenticated
+}
+
+// SecurityLevel returns the security level of the open secure channel
+func (c *Card) SecurityLevel() gpapdu.SecurityLevel {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.securityLevel
+}
+
+// SequenceCounter returns the sequence counter which will be used for the next pseudo-random card challenge
+func (c *Card) SequenceCounter() uint32 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.opts.SequenceCounter
+}
+
+// Send processes a single command as the card would, secure channel failures are reported in the status bytes rather than as errors
+func (c *Card) Send(cmd apdu.Command) (apdu.Response, error) {
+	if c == nil {
+		return apdu.Response{}, fmt.Errorf("invalid card, must be created with NewCard")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	switch cmd.Instruction {
+	case gpapdu.InstructionInitializeUpdate:
+		return c.initializeUpdate(cmd), nil
+	case apdu.InstructionExternalMutualAuthenticate:
+		if c.initialized {
+			return c.externalAuthenticate(cmd), nil
+		}
+	}
+	// Anything other than EXTERNAL AUTHENTICATE aborts a session which is being opened
+	c.initialized = false
+	unwrapped, ok := c.unwrap(cmd)
+	if !ok {
+		c.reset()
+		return statusResponse(0x69, 0x82), nil
+	}
+	if c.opts.Application == nil {
+		return c.wrap(statusResponse(0x6D, 0x00))
+	}
+	res, err := c.opts.Application.Send(unwrapped)
+	if err != nil {
+		return res, err
+	}
+	return c.wrap(res)
+}
+
+// reset closes any open secure channel
+func (c *Card) reset() {
+	c.initialized = false
+	c.authenticated = false
+	c.securityLevel = gpapdu.SecurityLevelNone
+	c.keys = SessionKeys{}
+	c.macChaining = nil
+	c.encryptionCounter = 0
+}
+
+func (c *Card) initializeUpdate(cmd apdu.Command) apdu.Response {
+	c.reset()
+	if cmd.P1 != 0 && cmd.P1 != c.opts.KeyVersionNumber {
+		return statusResponse(0x6A, 0x88)
+	}
+	if cmd.P2 != 0 {
+		return statusResponse(0x6A, 0x86)
+	}
+	s8mode := c.opts.Configuration.LegacyS8Mode
+	if len(cmd.Data) != int(cryptogramOutputLength(s8mode))/8 {
+		return statusResponse(0x67, 0x00)
+	}
+	var sequenceCounter []byte
+	var err error
+	if c.opts.Configuration.PseudoRandomChallenge {
+		sequenceCounter = []byte{byte(c.opts.SequenceCounter >> 16), byte(c.opts.SequenceCounter >> 8), byte(c.opts.SequenceCounter)}
+		if c.opts.SequenceCounter == 0xFFFFFF {
+			// The counter is exhausted, no further challenges can be generated
+			return statusResponse(0x69, 0x85)
+		}
+		c.opts.SequenceCounter++
+		context := append(append([]byte{}, sequenceCounter...), c.opts.AID...)
+		c.cardChallenge, err = deriveData(c.static.ENC, DDCCardChallengeGeneration, cryptogramOutputLength(s8mode), context)
+	} else {
+		c.cardChallenge = make([]byte, len(cmd.Data))
+		_, err = io.ReadFull(c.opts.Rand, c.cardChallenge)
+	}
+	if err != nil {
+		return statusResponse(0x6F, 0x00)
+	}
+	c.hostChallenge = append([]byte{}, cmd.Data...)
+	if c.keys, err = DeriveSessionKeys(c.static, c.hostChallenge, c.cardChallenge); err != nil {
+		return statusResponse(0x6F, 0x00)
+	}
+	cryptogram, err := CardCryptogram(c.keys.MAC, c.hostChallenge, c.cardChallenge, s8mode)
+	if err != nil {
+		return statusResponse(0x6F, 0x00)
+	}
+	c.initialized = true
+	return apdu.Response{
+		Data: InitializeUpdateResponse{
+			KeyDiversificationData: c.opts.KeyDiversificationData,
+			KeyVersionNumber:       c.opts.KeyVersionNumber,
+			SCPIdentifier:          SCPIdentifier,
+			Parameter:              c.opts.Configuration.ToByte(),
+			CardChallenge:          c.cardChallenge,
+			CardCryptogram:         cryptogram,
+			SequenceCounter:        sequenceCounter,
+		}.ToBytes(),
+		Status: apdu.RawStatus{SW1: 0x90, SW2: 0x00}.Identify(),
+	}
+}
+
+func (c *Card) externalAuthenticate(cmd apdu.Command) apdu.Response {
+	c.initialized = false
+	level := gpapdu.SecurityLevel(cmd.P1)
+	if err := level.Validate(); err != nil || cmd.P2 != 0 {
+		c.reset()
+		return statusResponse(0x6A, 0x86)
+	}
+	config := c.opts.Configuration
+	if (level.RMAC() && config.NoRMACEncryption) || (level.REncryption() && config.NoREncryption) {
+		c.reset()
+		return statusResponse(0x6A, 0x86)
+	}
+	// EXTERNAL AUTHENTICATE always carries a C-MAC from the all-zero chaining value
+	c.securityLevel = gpapdu.SecurityLevelCMAC
+	c.macChaining = make([]byte, 16)
+	unwrapped, ok := c.unwrap(cmd)
+	if !ok {
+		c.reset()
+		return statusResponse(0x69, 0x82)
+	}
+	expected, err := HostCryptogram(c.keys.MAC, c.hostChallenge, c.cardChallenge, config.LegacyS8Mode)
+	if err != nil || subtle.ConstantTimeCompare(expected, unwrapped.Data) != 1 {
+		c.reset()
+		return statusResponse(0x63, 0x00)
+	}
+	c.authenticated = true
+	c.securityLevel = level
+	return statusResponse(0x90, 0x00)
+}
+
+// unwrap verifies the C-MAC and deciphers the data of a command according to the security level, advancing the MAC chaining value and encryption counter.
+// Commands without a secure messaging indication are only accepted when no secure channel is open.
+func (c *Card) unwrap(cmd apdu.Command) (apdu.Command, bool) {
+	class, err := gpapdu.ClassFromAPDUClass(cmd.Class)
+	if err != nil {
+		return apdu.Command{}, false
+	}
+	hasSM := class.SecureMessaging != apdu.CLASMNone
+	if !c.securityLevel.CMAC() {
+		return cmd, !hasSM
+	}
+	if !hasSM || len(cmd.Data) < c.macLength {
+		return apdu.Command{}, false
+	}
+	data := cmd.Data[:len(cmd.Data)-c.macLength]
+	header := [4]byte{class.ToClassByte(), byte(cmd.Instruction), cmd.P1, cmd.P2}
+	mac, err := commandMAC(c.keys.MAC, c.macChaining, header, data, c.macLength)
+	if err != nil || subtle.ConstantTimeCompare(mac[:c.macLength], cmd.Data[len(data):]) != 1 {
+		return apdu.Command{}, false
+	}
+	c.macChaining = mac
+	if c.securityLevel.CDecryption() {
+		c.encryptionCounter++
+		if len(data) > 0 {
+			if data, err = decryptData(c.keys.ENC, c.encryptionCounter, false, data); err != nil {
+				return apdu.Command{}, false
+			}
+		}
+	}
+	class.SecureMessaging = apdu.CLASMNone
+	unwrapped := cmd
+	unwrapped.Class = class
+	unwrapped.Data = nil
+	if len(data) > 0 {
+		unwrapped.Data = append([]byte{}, data...)
+	}
+	return unwrapped, true
+}
+
+// wrap applies R-ENCRYPTION and R-MAC to a response according to the security level, errors without data are returned unchanged
+func (c *Card) wrap(res apdu.Response) (apdu.Response, error) {
+	if !c.securityLevel.RMAC() {
+		return res, nil
+	}
+	status := res.GetStatus()
+	if len(res.Data) == 0 && status.Error() != nil {
+		return res, nil
+	}
+	data := res.Data
+	var err error
+	if c.securityLevel.REncryption() && len(data) > 0 {
+		if data, err = encryptData(c.keys.ENC, c.encryptionCounter, true, data); err != nil {
+			return apdu.Response{}, fmt.Errorf("enciphering response: %w", err)
+		}
+	}
+	mac, err := responseMAC(c.keys.RMAC, c.macChaining, data, status.Raw().SW1, status.Raw().SW2)
+	if err != nil {
+		return apdu.Response{}, err
+	}
+	wrapped := res
+	wrapped.Data = append(append([]byte{}, data...), mac[:c.macLength]...)
+	return wrapped, nil
+}
+
+// statusResponse creates a response with no data
+func statusResponse(sw1, sw2 byte) apdu.Response {
+	return apdu.Response{Status: apdu.RawStatus{SW1: sw1, SW2: sw2}.Identify()}
+}
@@ -0,0 +1,228 @@
+package scp03
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/llkennedy/globalplatform/goimpl/keydiv"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustKeys(enc, mac, dek []byte) Keys {
+	keys, err := NewKeys(enc, mac, dek)
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
+
+// echoApplication records the commands it receives and echoes their data back
+type echoApplication struct {
+	last     apdu.Command
+	commands []apdu.Command
+}
+
+func (a *echoApplication) Send(cmd apdu.Command) (apdu.Response, error) {
+	a.last = cmd
+	a.commands = append(a.commands, cmd)
+	return apdu.Response{Data: cmd.Data, Status: apdu.RawStatus{SW1: 0x90}.Identify()}, nil
+}
+
+func TestCard(t *testing.T) {
+	keys := mustKeys(bytes.Repeat([]byte{0x40}, 16), bytes.Repeat([]byte{0x41}, 16), bytes.Repeat([]byte{0x42}, 16))
+	allLevels := gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption | gpapdu.SecurityLevelRMAC | gpapdu.SecurityLevelREncryption
+	tests := []struct {
+		name      string
+		hostKeys  Keys
+		cardOpts  CardOptions
+		hostOpts  Options
+		wantSeq   []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "S16 full security",
+			hostKeys:  keys,
+			cardOpts:  CardOptions{KeyVersionNumber: 0x30},
+			hostOpts:  Options{SecurityLevel: allLevels},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "S8 C-MAC only",
+			hostKeys:  keys,
+			cardOpts:  CardOptions{KeyVersionNumber: 0x30, Configuration: Configuration{LegacyS8Mode: true, NoRMACEncryption: true, NoREncryption: true}},
+			hostOpts:  Options{KeyVersionNumber: 0x30, SecurityLevel: gpapdu.SecurityLevelCMAC, S8Mode: true},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "pseudo-random challenge",
+			hostKeys:  keys,
+			cardOpts:  CardOptions{Configuration: Configuration{PseudoRandomChallenge: true}, AID: []byte{0xA0, 0x00, 0x00, 0x01, 0x51, 0x00, 0x00, 0x00}, SequenceCounter: 0x0102},
+			hostOpts:  Options{SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelRMAC},
+			wantSeq:   []byte{0x00, 0x01, 0x02},
+			assertion: assert.NoError,
+		},
+		{
+			name:      "wrong keys",
+			hostKeys:  Keys{ENC: keys.MAC, MAC: keys.ENC, DEK: keys.DEK},
+			cardOpts:  CardOptions{},
+			hostOpts:  Options{SecurityLevel: gpapdu.SecurityLevelCMAC},
+			assertion: assert.Error,
+		},
+		{
+			name:      "unknown key version",
+			hostKeys:  keys,
+			cardOpts:  CardOptions{KeyVersionNumber: 0x30},
+			hostOpts:  Options{KeyVersionNumber: 0x31, SecurityLevel: gpapdu.SecurityLevelCMAC},
+			assertion: assert.Error,
+		},
+		{
+			name:      "R-ENCRYPTION not supported",
+			hostKeys:  keys,
+			cardOpts:  CardOptions{Configuration: Configuration{NoREncryption: true}},
+			hostOpts:  Options{SecurityLevel: allLevels},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := &echoApplication{}
+			tt.cardOpts.Application = app
+			card, err := NewCard(keys, tt.cardOpts)
+			assert.NoError(t, err)
+			recorder := &recordingTransport{transport: card}
+			s, err := Open(recorder, tt.hostKeys, tt.hostOpts)
+			tt.assertion(t, err)
+			if err != nil {
+				assert.False(t, card.Authenticated())
+				return
+			}
+			assert.True(t, card.Authenticated())
+			assert.Equal(t, tt.hostOpts.SecurityLevel, card.SecurityLevel())
+			initRes, err := ParseInitializeUpdateResponse(recorder.responses[0].Data)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSeq, initRes.SequenceCounter)
+			for i := 0; i < 3; i++ {
+				data := bytes.Repeat([]byte{byte(i)}, 20+i)
+				res, err := s.Send(apdu.Command{Class: gpapdu.Class{IsGPCommand: true}, Instruction: 0xE2, P1: 0x90, Data: data})
+				assert.NoError(t, err)
+				assert.NoError(t, res.GetStatus().Error())
+				assert.Equal(t, data, res.Data)
+				assert.Equal(t, data, app.last.Data)
+				assert.Equal(t, byte(0x80), app.last.Class.ToClassByte())
+				wire := recorder.commands[len(recorder.commands)-1].Data
+				assert.Equal(t, tt.hostOpts.SecurityLevel.CDecryption(), !bytes.Contains(wire, data))
+			}
+		})
+	}
+}
+
+func TestCard_rejectsTamperedCommand(t *testing.T) {
+	keys := mustKeys(bytes.Repeat([]byte{0x40}, 32), bytes.Repeat([]byte{0x41}, 32), bytes.Repeat([]byte{0x42}, 32))
+	card, err := NewCard(keys, CardOptions{Application: &echoApplication{}})
+	assert.NoError(t, err)
+	tamper := &recordingTransport{transport: card}
+	s, err := Open(tamper, keys, Options{SecurityLevel: gpapdu.SecurityLevelCMAC})
+	assert.NoError(t, err)
+	tamper.modify = func(cmd apdu.Command) apdu.Command {
+		cmd.P2 ^= 0x01
+		return cmd
+	}
+	res, err := s.Send(apdu.Command{Class: gpapdu.Class{IsGPCommand: true}, Instruction: 0xF2, P1: 0x80, P2: 0x02})
+	assert.NoError(t, err)
+	assert.Equal(t, apdu.RawStatus{SW1: 0x69, SW2: 0x82}, res.GetStatus().Raw())
+	assert.False(t, card.Authenticated())
+	// The channel is closed, so unprotected commands are now passed to the application
+	tamper.modify = nil
+	res, err = card.Send(apdu.Command{Class: gpapdu.Class{IsGPCommand: true}, Instruction: 0xF2, P1: 0x80, P2: 0x02})
+	assert.NoError(t, err)
+	assert.NoError(t, res.GetStatus().Error())
+}
+
+func TestCard_chainedCommand(t *testing.T) {
+	keys := mustKeys(bytes.Repeat([]byte{0x40}, 16), bytes.Repeat([]byte{0x41}, 16), bytes.Repeat([]byte{0x42}, 16))
+	app := &echoApplication{}
+	card, err := NewCard(keys, CardOptions{Application: app})
+	assert.NoError(t, err)
+	s, err := Open(card, keys, Options{SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption | gpapdu.SecurityLevelRMAC})
+	assert.NoError(t, err)
+	data := bytes.Repeat([]byte{0x5A}, 500)
+	assert.NoError(t, gpapdu.NewClient(s).PutData(gpapdu.PutDataCommand{Tag: 0x0070, Data: data}))
+	assert.True(t, card.Authenticated())
+	var received []byte
+	for i, cmd := range app.commands {
+		assert.LessOrEqual(t, len(cmd.Data), gpapdu.ChainBlockSize)
+		assert.Equal(t, i < len(app.commands)-1, cmd.Class.ToClassByte()&0x10 != 0)
+		received = append(received, cmd.Data...)
+	}
+	assert.Len(t, app.commands, 3)
+	assert.Equal(t, data, received)
+}
+
+// putKeyApplication answers PUT KEY with the key version number and the key check values sent with each key
+type putKeyApplication struct {
+	commands []apdu.Command
+}
+
+func (a *putKeyApplication) Send(cmd apdu.Command) (apdu.Response, error) {
+	a.commands = append(a.commands, cmd)
+	out := []byte{cmd.Data[0]}
+	for data := cmd.Data[1:]; len(data) > 0; {
+		data = data[2+int(data[1]):]
+		out = append(out, data[1:1+int(data[0])]...)
+		data = data[1+int(data[0]):]
+	}
+	return apdu.Response{Data: out, Status: apdu.RawStatus{SW1: 0x90}.Identify()}, nil
+}
+
+func TestCard_putKey(t *testing.T) {
+	keys := mustKeys(bytes.Repeat([]byte{0x40}, 16), bytes.Repeat([]byte{0x41}, 16), bytes.Repeat([]byte{0x42}, 16))
+	app := &putKeyApplication{}
+	card, err := NewCard(keys, CardOptions{Application: app})
+	assert.NoError(t, err)
+	s, err := Open(card, keys, Options{SecurityLevel: gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption | gpapdu.SecurityLevelRMAC})
+	assert.NoError(t, err)
+	newKeys := make([]gpapdu.PutKeyKey, 12)
+	for i := range newKeys {
+		newKeys[i] = gpapdu.PutKeyKey{Components: []gpapdu.KeyComponent{{Type: gpapdu.AES, Value: bytes.Repeat([]byte{byte(i)}, 16)}}}
+	}
+	assert.NoError(t, gpapdu.NewClient(s).PutKey(gpapdu.PutKeyCommand{KeyVersionNumber: 0x31, KeyIdentifier: 0x01, Keys: newKeys}, s))
+	assert.True(t, card.Authenticated())
+	if assert.Len(t, app.commands, 2) {
+		assert.Equal(t, byte(0x80), app.commands[0].P1)
+		assert.Equal(t, byte(0x00), app.commands[1].P1)
+	}
+}
+
+// recordingTransport records traffic and optionally modifies commands in flight
+type recordingTransport struct {
+	transport apdu.Transport
+	modify    func(apdu.Command) apdu.Command
+	commands  []apdu.Command
+	responses []apdu.Response
+}
+
+func (r *recordingTransport) Send(cmd apdu.Command) (apdu.Response, error) {
+	if r.modify != nil {
+		cmd = r.modify(cmd)
+	}
+	res, err := r.transport.Send(cmd)
+	r.commands = append(r.commands, cmd)
+	r.responses = append(r.responses, res)
+	return res, err
+}
+
+func TestOpen_diversification(t *testing.T) {
+	master := mustKeys(bytes.Repeat([]byte{0x40}, 16), bytes.Repeat([]byte{0x41}, 16), bytes.Repeat([]byte{0x42}, 16))
+	kdd := [10]byte{0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19}
+	diversified, err := keydiv.Diversify(keydiv.KDF3{}, keydiv.Keys(master), kdd)
+	assert.NoError(t, err)
+	card, err := NewCard(Keys(diversified), CardOptions{KeyDiversificationData: kdd})
+	assert.NoError(t, err)
+	_, err = Open(card, master, Options{SecurityLevel: gpapdu.SecurityLevelCMAC})
+	assert.Error(t, err)
+	_, err = Open(card, master, Options{SecurityLevel: gpapdu.SecurityLevelCMAC, Diversification: keydiv.KDF3{}})
+	assert.NoError(t, err)
+	assert.True(t, card.Authenticated())
+}
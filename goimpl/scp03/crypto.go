@@ -0,0 +1,28 @@
+package scp03
+
+import (
+	"crypto/aes"
+	"fmt"
+)
+
+// pad80 appends 0x80 and as many zeroes as required to reach a multiple of the AES block size, padding is always added even to aligned data
+func pad80(data []byte) []byte {
+	out := make([]byte, (len(data)/aes.BlockSize+1)*aes.BlockSize)
+	copy(out, data)
+	out[len(data)] = 0x80
+	return out
+}
+
+// unpad80 strips padding added by pad80
+func unpad80(data []byte) ([]byte, error) {
+	for i := len(data) - 1; i >= 0; i-- {
+		switch data[i] {
+		case 0x00:
+			continue
+		case 0x80:
+			return data[:i], nil
+		}
+		break
+	}
+	return nil, fmt.Errorf("invalid padding")
+}
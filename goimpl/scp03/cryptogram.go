@@ -0,0 +1,73 @@
+package scp03
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+// SCPIdentifier is the SCP identifier returned by the card in the key information of INITIALIZE UPDATE
+const SCPIdentifier byte = 0x03
+
+// InitializeUpdateResponse is the card's response to INITIALIZE UPDATE
+type InitializeUpdateResponse struct {
+	KeyDiversificationData [10]byte
+	KeyVersionNumber       byte
+	SCPIdentifier          byte
+	Parameter              byte   // The "i" parameter, see ParseConfiguration
+	CardChallenge          []byte // 8 bytes in S8 mode, 16 bytes in S16 mode
+	CardCryptogram         []byte // 8 bytes in S8 mode, 16 bytes in S16 mode
+	SequenceCounter        []byte // 3 bytes, only present when the card challenge is pseudo-random
+}
+
+// ParseInitializeUpdateResponse parses the response data of INITIALIZE UPDATE, in either S8 or S16 mode
+func ParseInitializeUpdateResponse(data []byte) (res InitializeUpdateResponse, err error) {
+	var challengeLength int
+	switch len(data) {
+	case 29, 32:
+		challengeLength = 8
+	case 45, 48:
+		challengeLength = 16
+	default:
+		err = fmt.Errorf("invalid INITIALIZE UPDATE response length %d", len(data))
+		return
+	}
+	copy(res.KeyDiversificationData[:], data[0:10])
+	res.KeyVersionNumber = data[10]
+	res.SCPIdentifier = data[11]
+	res.Parameter = data[12]
+	if ParseConfiguration(res.Parameter).LegacyS8Mode != (challengeLength == 8) {
+		err = fmt.Errorf("response length %d does not match the S8/S16 mode of parameter %02X", len(data), res.Parameter)
+		return
+	}
+	offset := 13
+	res.CardChallenge = append([]byte{}, data[offset:offset+challengeLength]...)
+	offset += challengeLength
+	res.CardCryptogram = append([]byte{}, data[offset:offset+challengeLength]...)
+	offset += challengeLength
+	if len(data) > offset {
+		res.SequenceCounter = append([]byte{}, data[offset:]...)
+	}
+	return
+}
+
+// ToBytes converts the response back to its encoding
+func (r InitializeUpdateResponse) ToBytes() []byte {
+	out := append([]byte{}, r.KeyDiversificationData[:]...)
+	out = append(out, r.KeyVersionNumber, r.SCPIdentifier, r.Parameter)
+	out = append(out, r.CardChallenge...)
+	out = append(out, r.CardCryptogram...)
+	return append(out, r.SequenceCounter...)
+}
+
+// CardCryptogram computes the card cryptogram with the S-MAC session key
+func CardCryptogram(sessionMAC keystore.Key, hostChallenge, cardChallenge []byte, s8mode bool) ([]byte, error) {
+	context := append(append([]byte{}, hostChallenge...), cardChallenge...)
+	return deriveData(sessionMAC, DDCCardCryptogram, cryptogramOutputLength(s8mode), context)
+}
+
+// HostCryptogram computes the host cryptogram with the S-MAC session key
+func HostCryptogram(sessionMAC keystore.Key, hostChallenge, cardChallenge []byte, s8mode bool) ([]byte, error) {
+	context := append(append([]byte{}, hostChallenge...), cardChallenge...)
+	return deriveData(sessionMAC, DDCHostCryptogram, cryptogramOutputLength(s8mode), context)
+}
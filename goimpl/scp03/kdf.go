@@ -41,8 +41,9 @@ const (
 	KDFOutput256 = 0x0100
 )
 
-// Derive derives data from a base key and input data
-func (k *KDF) Derive(key []byte, rawKDF sp800108.KDF, label [11]byte, ddc DataDerivationConstant, length KDFOutputLength, context []byte /*FIXME: "further specified"*/) ([]byte, error) {
+// Derive derives data from a base key and input data.
+// The SP800-108 label is the 11 byte SCP03 label followed by the data derivation constant, and the context is usually host challenge || card challenge.
+func (k *KDF) Derive(key []byte, rawKDF sp800108.KDF, label [11]byte, ddc DataDerivationConstant, length KDFOutputLength, context []byte) ([]byte, error) {
 	if key == nil || rawKDF == nil {
 		return nil, fmt.Errorf("KDF: nil parameters")
 	}
@@ -66,6 +67,7 @@ func (k *KDF) Derive(key []byte, rawKDF sp800108.KDF, label [11]byte, ddc DataDe
 		return nil, fmt.Errorf("KDF: Invalid output length: %d", length)
 	}
 	prf := &sp800108.PRFCMAC{}
-	ordering := []sp800108.InputStringOrdering{sp800108.InputOrderLabel, sp800108.InputOrderEmptySeparator, sp800108.InputOrderL, sp800108.InputOrderCounter, sp800108.InputOrderCounter}
-	return rawKDF.Derive(prf, sp800108.CounterLength8, key, label[:], context, lengthData, ordering)
+	fullLabel := append(label[:], byte(ddc))
+	ordering := []sp800108.InputStringOrdering{sp800108.InputOrderLabel, sp800108.InputOrderEmptySeparator, sp800108.InputOrderL, sp800108.InputOrderCounter, sp800108.InputOrderContext}
+	return rawKDF.Derive(prf, sp800108.CounterLength8, key, fullLabel, context, lengthData, ordering)
 }
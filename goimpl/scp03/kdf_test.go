@@ -0,0 +1,130 @@
+package scp03
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+
+	"github.com/aead/cmac"
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+	"github.com/llkennedy/globalplatform/goimpl/nist/sp800108"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKDF_Derive(t *testing.T) {
+	key := bytes.Repeat([]byte{0x40}, 16)
+	context := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	type args struct {
+		key    []byte
+		rawKDF sp800108.KDF
+		ddc    DataDerivationConstant
+		length KDFOutputLength
+	}
+	tests := []struct {
+		name      string
+		args      args
+		want      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name: "S-MAC",
+			args: args{
+				key:    key,
+				rawKDF: &sp800108.CounterKBKDF{},
+				ddc:    DDCSMAC,
+				length: KDFOutput128,
+			},
+			want:      blindCMAC(key, append([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x06, 0x00, 0x00, 0x80, 0x01}, context...)),
+			assertion: assert.NoError,
+		},
+		{
+			name: "card cryptogram S8",
+			args: args{
+				key:    key,
+				rawKDF: &sp800108.CounterKBKDF{},
+				ddc:    DDCCardCryptogram,
+				length: KDFOutput64,
+			},
+			want:      blindCMAC(key, append([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x00, 0x00, 0x40, 0x01}, context...))[:8],
+			assertion: assert.NoError,
+		},
+		{
+			name: "AES-256 output",
+			args: args{
+				key:    key,
+				rawKDF: &sp800108.CounterKBKDF{},
+				ddc:    DDCSENC,
+				length: KDFOutput256,
+			},
+			want: append(
+				blindCMAC(key, append([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x04, 0x00, 0x01, 0x00, 0x01}, context...)),
+				blindCMAC(key, append([]byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x04, 0x00, 0x01, 0x00, 0x02}, context...))...,
+			),
+			assertion: assert.NoError,
+		},
+		{
+			name: "invalid constant",
+			args: args{
+				key:    key,
+				rawKDF: &sp800108.CounterKBKDF{},
+				ddc:    0x05,
+				length: KDFOutput128,
+			},
+			assertion: assert.Error,
+		},
+		{
+			name: "nil KDF",
+			args: args{
+				key:    key,
+				ddc:    DDCSENC,
+				length: KDFOutput128,
+			},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &KDF{}
+			got, err := k.Derive(tt.args.key, tt.args.rawKDF, [11]byte{}, tt.args.ddc, tt.args.length, context)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func blindCMAC(key, data []byte) []byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err)
+	}
+	mac, err := cmac.Sum(data, block, aes.BlockSize)
+	if err != nil {
+		panic(err)
+	}
+	return mac
+}
+
+func Test_deriveKey(t *testing.T) {
+	context := []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	for _, length := range []int{16, 24, 32} {
+		raw := bytes.Repeat([]byte{0x40}, length)
+		key, err := keystore.NewSoftwareKey(keystore.AlgorithmAES, raw)
+		assert.NoError(t, err)
+		derived, err := deriveKey(key, DDCSENC, context)
+		assert.NoError(t, err)
+		assert.Equal(t, length, derived.Length())
+		expected, err := (&KDF{}).Derive(raw, &sp800108.CounterKBKDF{}, [11]byte{}, DDCSENC, KDFOutputLength(length*8), context)
+		assert.NoError(t, err)
+		// The derived key must behave exactly like the raw KDF output
+		block, err := aes.NewCipher(expected)
+		assert.NoError(t, err)
+		want := make([]byte, aes.BlockSize)
+		block.Encrypt(want, context)
+		got, err := derived.Encrypt(keystore.ModeECB, nil, context)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+		data, err := deriveData(key, DDCSENC, KDFOutputLength(length*8), context)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, data)
+	}
+}
@@ -0,0 +1,137 @@
+package scp03
+
+import (
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+// Keys is a set of static AES keys
+type Keys struct {
+	ENC keystore.Key
+	MAC keystore.Key
+	DEK keystore.Key
+}
+
+// NewKeys creates a set of static keys held in software from the raw AES key values
+func NewKeys(enc, mac, dek []byte) (keys Keys, err error) {
+	if keys.ENC, err = keystore.NewSoftwareKey(keystore.AlgorithmAES, enc); err != nil {
+		err = fmt.Errorf("ENC: %w", err)
+		return
+	}
+	if keys.MAC, err = keystore.NewSoftwareKey(keystore.AlgorithmAES, mac); err != nil {
+		err = fmt.Errorf("MAC: %w", err)
+		return
+	}
+	if keys.DEK, err = keystore.NewSoftwareKey(keystore.AlgorithmAES, dek); err != nil {
+		err = fmt.Errorf("DEK: %w", err)
+	}
+	return
+}
+
+// validate checks every key is present and is an AES key
+func (k Keys) validate() error {
+	for i, key := range []keystore.Key{k.ENC, k.MAC, k.DEK} {
+		name := []string{"ENC", "MAC", "DEK"}[i]
+		if key == nil {
+			return fmt.Errorf("missing static %s key", name)
+		}
+		if key.Algorithm() != keystore.AlgorithmAES {
+			return fmt.Errorf("static %s key must be AES, not %s", name, key.Algorithm())
+		}
+	}
+	return nil
+}
+
+// SessionKeys are the keys used for secure messaging in a single session. DEK is the static DEK, SCP03 does not derive a session DEK.
+type SessionKeys struct {
+	ENC  keystore.Key
+	MAC  keystore.Key
+	RMAC keystore.Key
+	DEK  keystore.Key
+}
+
+// NewSessionKeys creates session keys held in software from raw AES key values, as agreed by SCP11
+func NewSessionKeys(enc, mac, rmac, dek []byte) (keys SessionKeys, err error) {
+	for _, k := range []struct {
+		dst   *keystore.Key
+		value []byte
+		name  string
+	}{{&keys.ENC, enc, "S-ENC"}, {&keys.MAC, mac, "S-MAC"}, {&keys.RMAC, rmac, "S-RMAC"}, {&keys.DEK, dek, "DEK"}} {
+		if *k.dst, err = keystore.NewSoftwareKey(keystore.AlgorithmAES, k.value); err != nil {
+			err = fmt.Errorf("%s: %w", k.name, err)
+			return
+		}
+	}
+	return
+}
+
+// cryptogramOutputLength gets the KDF output length for cryptograms and challenges in S8 or S16 mode
+func cryptogramOutputLength(s8mode bool) KDFOutputLength {
+	if s8mode {
+		return KDFOutput64
+	}
+	return KDFOutput128
+}
+
+// kdfPrefix is the KDF input before the counter: the all-zero label, the data derivation constant, the separator and L
+func kdfPrefix(ddc DataDerivationConstant, length KDFOutputLength) []byte {
+	return append(make([]byte, 11), byte(ddc), 0x00, byte(length>>8), byte(length))
+}
+
+// deriveKey runs the SCP03 KDF to derive a new AES key of the same length as the base key, without exposing either
+func deriveKey(key keystore.Key, ddc DataDerivationConstant, context []byte) (keystore.Key, error) {
+	if key == nil {
+		return nil, fmt.Errorf("nil key")
+	}
+	if key.Algorithm() != keystore.AlgorithmAES {
+		return nil, fmt.Errorf("KDF requires an AES key, not %s", key.Algorithm())
+	}
+	return key.Derive(keystore.Derivation{
+		Method:    keystore.DeriveCounterCMAC,
+		Algorithm: keystore.AlgorithmAES,
+		Length:    key.Length(),
+		Prefix:    kdfPrefix(ddc, KDFOutputLength(key.Length()*8)),
+		Suffix:    context,
+	})
+}
+
+// deriveData runs the SCP03 KDF to produce a cryptogram or card challenge, using the CMAC of the key directly as the PRF
+func deriveData(key keystore.Key, ddc DataDerivationConstant, length KDFOutputLength, context []byte) ([]byte, error) {
+	if key == nil {
+		return nil, fmt.Errorf("nil key")
+	}
+	prefix := kdfPrefix(ddc, length)
+	var out []byte
+	for counter := byte(1); len(out)*8 < int(length); counter++ {
+		input := append(append(append([]byte{}, prefix...), counter), context...)
+		block, err := key.CMAC(input)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, block...)
+	}
+	return out[:length/8], nil
+}
+
+// DeriveSessionKeys derives session keys from the static keys, using host challenge || card challenge as the context
+func DeriveSessionKeys(static Keys, hostChallenge, cardChallenge []byte) (keys SessionKeys, err error) {
+	if err = static.validate(); err != nil {
+		return
+	}
+	context := append(append([]byte{}, hostChallenge...), cardChallenge...)
+	if keys.ENC, err = deriveKey(static.ENC, DDCSENC, context); err != nil {
+		err = fmt.Errorf("deriving S-ENC: %w", err)
+		return
+	}
+	if keys.MAC, err = deriveKey(static.MAC, DDCSMAC, context); err != nil {
+		err = fmt.Errorf("deriving S-MAC: %w", err)
+		return
+	}
+	if keys.RMAC, err = deriveKey(static.MAC, DDCSRMAC, context); err != nil {
+		err = fmt.Errorf("deriving S-RMAC: %w", err)
+		return
+	}
+	keys.DEK = static.DEK
+	return
+}
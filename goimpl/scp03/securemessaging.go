@@ -0,0 +1,65 @@
+package scp03
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+// commandMAC computes the full 16 byte C-MAC over the MAC chaining value, the command header with Lc and the (possibly enciphered) data field.
+// The first macLength bytes are appended to the command, all 16 bytes become the next MAC chaining value.
+func commandMAC(key keystore.Key, chaining []byte, header [4]byte, data []byte, macLength int) ([]byte, error) {
+	lc := len(data) + macLength
+	if lc > 255 {
+		return nil, fmt.Errorf("command data too long for secure messaging: %d bytes", len(data))
+	}
+	input := make([]byte, 0, len(chaining)+5+len(data))
+	input = append(input, chaining...)
+	input = append(input, header[:]...)
+	input = append(input, byte(lc))
+	input = append(input, data...)
+	return key.CMAC(input)
+}
+
+// responseMAC computes the full 16 byte R-MAC over the MAC chaining value of the command, the (possibly enciphered) response data and the status bytes
+func responseMAC(key keystore.Key, chaining, data []byte, sw1, sw2 byte) ([]byte, error) {
+	input := make([]byte, 0, len(chaining)+len(data)+2)
+	input = append(input, chaining...)
+	input = append(input, data...)
+	input = append(input, sw1, sw2)
+	return key.CMAC(input)
+}
+
+// encryptionICV computes the ICV for C-DECRYPTION or R-ENCRYPTION from the encryption counter, responses set the first byte of the counter block to 0x80
+func encryptionICV(key keystore.Key, counter uint64, response bool) ([]byte, error) {
+	block := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(block[8:], counter)
+	if response {
+		block[0] = 0x80
+	}
+	return key.Encrypt(keystore.ModeECB, nil, block)
+}
+
+// encryptData pads and enciphers a data field
+func encryptData(key keystore.Key, counter uint64, response bool, data []byte) ([]byte, error) {
+	icv, err := encryptionICV(key, counter, response)
+	if err != nil {
+		return nil, err
+	}
+	return key.Encrypt(keystore.ModeCBC, icv, pad80(data))
+}
+
+// decryptData deciphers and unpads a data field
+func decryptData(key keystore.Key, counter uint64, response bool, data []byte) ([]byte, error) {
+	icv, err := encryptionICV(key, counter, response)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := key.Decrypt(keystore.ModeCBC, icv, data)
+	if err != nil {
+		return nil, err
+	}
+	return unpad80(plain)
+}
@@ -0,0 +1,274 @@
+package scp03
+
+import (
+	"crypto/aes"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/llkennedy/globalplatform/goimpl/keydiv"
+	"github.com/llkennedy/globalplatform/goimpl/keystore"
+)
+
+// Options configures the opening of a Session
+type Options struct {
+	KeyVersionNumber byte                 // 0 selects the first available key set
+	SecurityLevel    gpapdu.SecurityLevel // Applied to every command after EXTERNAL AUTHENTICATE
+	S8Mode           bool                 // Whether to use 8 byte challenges, cryptograms and MACs instead of 16
+	LogicalChannel   uint8
+	Rand             io.Reader // Source of the host challenge, crypto/rand is used when nil
+	// Diversification derives the card's static keys from the given master keys and the key diversification data in the INITIALIZE UPDATE response, the keys are used as given when nil
+	Diversification keydiv.Method
+}
+
+// Session is an open SCP03 secure channel, which wraps every command in secure messaging before sending it on the underlying transport.
+// Session implements apdu.Transport, so everything which sends commands on a transport can send them on a secure channel instead.
+// The secure messaging is shared with SCP11, which only differs in how the session keys are established.
+type Session struct {
+	mutex             sync.Mutex
+	transport         apdu.Transport
+	keys              SessionKeys
+	securityLevel     gpapdu.SecurityLevel
+	macLength         int
+	macChaining       []byte
+	encryptionCounter uint64
+}
+
+// NewSession creates a Session from already established session keys and MAC chaining value, the all-zero MAC chaining value is used when nil
+func NewSession(transport apdu.Transport, keys SessionKeys, securityLevel gpapdu.SecurityLevel, s8mode bool, macChaining []byte) (*Session, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("cannot create secure channel on nil transport")
+	}
+	if err := securityLevel.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid security level: %w", err)
+	}
+	if macChaining == nil {
+		macChaining = make([]byte, aes.BlockSize)
+	}
+	if len(macChaining) != aes.BlockSize {
+		return nil, fmt.Errorf("MAC chaining value must be %d bytes, got %d", aes.BlockSize, len(macChaining))
+	}
+	s := &Session{
+		transport:     transport,
+		keys:          keys,
+		securityLevel: securityLevel,
+		macLength:     16,
+		macChaining:   append([]byte{}, macChaining...),
+	}
+	if s8mode {
+		s.macLength = 8
+	}
+	return s, nil
+}
+
+// Open opens a secure channel with INITIALIZE UPDATE and EXTERNAL AUTHENTICATE, authenticating the card and the host to each other
+func Open(transport apdu.Transport, static Keys, opts Options) (*Session, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("cannot open secure channel on nil transport")
+	}
+	if err := opts.SecurityLevel.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid security level: %w", err)
+	}
+	randR := opts.Rand
+	if randR == nil {
+		randR = rand.Reader
+	}
+	hostChallenge := make([]byte, 16)
+	if opts.S8Mode {
+		hostChallenge = make([]byte, 8)
+	}
+	if _, err := io.ReadFull(randR, hostChallenge); err != nil {
+		return nil, fmt.Errorf("failed to generate host challenge: %w", err)
+	}
+	class := gpapdu.Class{
+		IsGPCommand: true,
+		InterindustryClass: apdu.InterindustryClass{
+			LogicalChannelNumber: opts.LogicalChannel,
+			SecureMessaging:      apdu.CLASMNone,
+		},
+	}
+	res, err := gpapdu.SendOnTransport(transport, gpapdu.Command{
+		Class:              class,
+		Instruction:        gpapdu.InstructionInitializeUpdate,
+		P1:                 opts.KeyVersionNumber,
+		P2:                 0x00, // Required to always be 0x00
+		Data:               hostChallenge,
+		ExpectResponseData: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sending INITIALIZE UPDATE: %w", err)
+	}
+	if err = res.GetStatus().Error(); err != nil {
+		return nil, fmt.Errorf("INITIALIZE UPDATE: %w", err)
+	}
+	initRes, err := ParseInitializeUpdateResponse(res.Data)
+	if err != nil {
+		return nil, err
+	}
+	if initRes.SCPIdentifier != SCPIdentifier {
+		return nil, fmt.Errorf("card responded with SCP identifier %02X, expected %02X", initRes.SCPIdentifier, SCPIdentifier)
+	}
+	if opts.Diversification != nil {
+		diversified, err := keydiv.Diversify(opts.Diversification, keydiv.Keys(static), initRes.KeyDiversificationData)
+		if err != nil {
+			return nil, fmt.Errorf("diversifying static keys: %w", err)
+		}
+		static = Keys(diversified)
+	}
+	config := ParseConfiguration(initRes.Parameter)
+	if config.LegacyS8Mode != opts.S8Mode {
+		return nil, fmt.Errorf("card responded with parameter %02X which does not match the requested S8 mode %v", initRes.Parameter, opts.S8Mode)
+	}
+	if opts.SecurityLevel.RMAC() && config.NoRMACEncryption {
+		return nil, fmt.Errorf("R-MAC requested but not supported by parameter %02X", initRes.Parameter)
+	}
+	if opts.SecurityLevel.REncryption() && config.NoREncryption {
+		return nil, fmt.Errorf("R-ENCRYPTION requested but not supported by parameter %02X", initRes.Parameter)
+	}
+	keys, err := DeriveSessionKeys(static, hostChallenge, initRes.CardChallenge)
+	if err != nil {
+		return nil, err
+	}
+	expectedCardCryptogram, err := CardCryptogram(keys.MAC, hostChallenge, initRes.CardChallenge, opts.S8Mode)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(expectedCardCryptogram, initRes.CardCryptogram) != 1 {
+		return nil, fmt.Errorf("card cryptogram did not match, card could not be authenticated")
+	}
+	hostCryptogram, err := HostCryptogram(keys.MAC, hostChallenge, initRes.CardChallenge, opts.S8Mode)
+	if err != nil {
+		return nil, err
+	}
+	// EXTERNAL AUTHENTICATE always carries a C-MAC, regardless of the requested level
+	s, err := NewSession(transport, keys, gpapdu.SecurityLevelCMAC, opts.S8Mode, nil)
+	if err != nil {
+		return nil, err
+	}
+	externalAuth, err := gpapdu.Command{
+		Class:       class,
+		Instruction: apdu.InstructionExternalMutualAuthenticate,
+		P1:          byte(opts.SecurityLevel),
+		P2:          0x00,
+		Data:        hostCryptogram,
+	}.ToAPDU()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, err := s.Wrap(externalAuth)
+	if err != nil {
+		return nil, fmt.Errorf("wrapping EXTERNAL AUTHENTICATE: %w", err)
+	}
+	res, err = transport.Send(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("sending EXTERNAL AUTHENTICATE: %w", err)
+	}
+	if err = res.GetStatus().Error(); err != nil {
+		return nil, fmt.Errorf("EXTERNAL AUTHENTICATE: %w", err)
+	}
+	s.securityLevel = opts.SecurityLevel
+	return s, nil
+}
+
+// SecurityLevel returns the security level of the session
+func (s *Session) SecurityLevel() gpapdu.SecurityLevel {
+	return s.securityLevel
+}
+
+// Send wraps the command, sends it on the underlying transport and unwraps the response
+func (s *Session) Send(cmd apdu.Command) (apdu.Response, error) {
+	if s == nil || s.transport == nil {
+		return apdu.Response{}, fmt.Errorf("invalid session, must be opened before use")
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	wrapped, err := s.Wrap(cmd)
+	if err != nil {
+		return apdu.Response{}, fmt.Errorf("wrapping command: %w", err)
+	}
+	res, err := s.transport.Send(wrapped)
+	if err != nil {
+		return res, err
+	}
+	return s.Unwrap(res)
+}
+
+// Wrap applies C-DECRYPTION and C-MAC to a plaintext command according to the session's security level.
+// Wrap advances the MAC chaining value and encryption counter, so every wrapped command must be sent to the card in the same order and its response unwrapped before wrapping the next.
+func (s *Session) Wrap(cmd apdu.Command) (apdu.Command, error) {
+	if !s.securityLevel.CMAC() {
+		return cmd, nil
+	}
+	class, err := gpapdu.ClassFromAPDUClass(cmd.Class)
+	if err != nil {
+		return apdu.Command{}, err
+	}
+	class = class.WithSecureMessaging()
+	data := cmd.Data
+	if s.securityLevel.CDecryption() {
+		// The counter is incremented for every command, even those with no data to encipher
+		s.encryptionCounter++
+		if len(data) > 0 {
+			if data, err = encryptData(s.keys.ENC, s.encryptionCounter, false, data); err != nil {
+				return apdu.Command{}, err
+			}
+		}
+	}
+	header := [4]byte{class.ToClassByte(), byte(cmd.Instruction), cmd.P1, cmd.P2}
+	mac, err := commandMAC(s.keys.MAC, s.macChaining, header, data, s.macLength)
+	if err != nil {
+		return apdu.Command{}, err
+	}
+	s.macChaining = mac
+	wrapped := cmd
+	wrapped.Class = class
+	wrapped.Data = append(append([]byte{}, data...), mac[:s.macLength]...)
+	return wrapped, nil
+}
+
+// Unwrap verifies and strips the R-MAC and deciphers the response data of the last wrapped command, according to the session's security level
+func (s *Session) Unwrap(res apdu.Response) (apdu.Response, error) {
+	if !s.securityLevel.RMAC() {
+		return res, nil
+	}
+	status := res.GetStatus()
+	if len(res.Data) == 0 && status.Error() != nil {
+		// Errors are returned without an R-MAC
+		return res, nil
+	}
+	if len(res.Data) < s.macLength {
+		return apdu.Response{}, fmt.Errorf("response too short to contain R-MAC: %d bytes", len(res.Data))
+	}
+	data := res.Data[:len(res.Data)-s.macLength]
+	receivedMAC := res.Data[len(res.Data)-s.macLength:]
+	mac, err := responseMAC(s.keys.RMAC, s.macChaining, data, status.Raw().SW1, status.Raw().SW2)
+	if err != nil {
+		return apdu.Response{}, err
+	}
+	if subtle.ConstantTimeCompare(mac[:s.macLength], receivedMAC) != 1 {
+		return apdu.Response{}, fmt.Errorf("R-MAC verification failed")
+	}
+	if s.securityLevel.REncryption() && len(data) > 0 {
+		if data, err = decryptData(s.keys.ENC, s.encryptionCounter, true, data); err != nil {
+			return apdu.Response{}, fmt.Errorf("deciphering response: %w", err)
+		}
+	}
+	unwrapped := res
+	unwrapped.Data = nil
+	if len(data) > 0 {
+		unwrapped.Data = append([]byte{}, data...)
+	}
+	return unwrapped, nil
+}
+
+// EncryptSensitiveData encrypts block-aligned sensitive data such as secret key components with the DEK, in AES CBC mode with a zero ICV
+func (s *Session) EncryptSensitiveData(data []byte) ([]byte, error) {
+	if s == nil {
+		return nil, fmt.Errorf("invalid session, must be opened before use")
+	}
+	return s.keys.DEK.Encrypt(keystore.ModeCBC, nil, data)
+}
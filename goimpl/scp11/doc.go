@@ -0,0 +1,17 @@
+// Package scp11 implements the Secure Channel Protocol 11 (variants a, b and c), which establishes SCP03 session keys with ECDH instead of static symmetric keys
+package scp11
+
+/*
+
+Key Agreement
+
+1. Application retrieves CERT.SD from the card (GET DATA BF21) and validates it to obtain PK.SD.ECKA
+2. For SCP11a and SCP11c, application uploads the CERT.OCE chain with PERFORM SECURITY OPERATION
+3. Application generates an ephemeral key pair and sends ePK.OCE.ECKA to the card with MUTUAL AUTHENTICATE (SCP11a, SCP11c) or INTERNAL AUTHENTICATE (SCP11b)
+4. Card generates its own ephemeral key pair, returns ePK.SD.ECKA and a receipt
+5. Both sides compute ShSee = ECDH(eSK.OCE, ePK.SD) and ShSes = ECDH(SK.OCE, PK.SD) (eSK.OCE in SCP11b), and derive the session keys with the X9.63 KDF
+6. Application verifies the receipt, which authenticates the card and proves both sides hold the same keys
+
+Secure messaging then follows SCP03, with 8 byte MACs and the receipt as the initial MAC chaining value.
+
+*/ //
@@ -0,0 +1,81 @@
+package scp11
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+)
+
+// X963KDF is the ANSI X9.63 key derivation function, parameterised by its hash function
+type X963KDF struct {
+	hashGen func() hash.Hash
+}
+
+// NewX963KDF creates a new X9.63 KDF
+func NewX963KDF(hashGen func() hash.Hash) *X963KDF {
+	return &X963KDF{
+		hashGen: hashGen,
+	}
+}
+
+// Derive derives length bytes of keying material from the shared secret Z and the shared info
+func (k *X963KDF) Derive(z, sharedInfo []byte, length int) ([]byte, error) {
+	if k == nil || k.hashGen == nil {
+		return nil, fmt.Errorf("invalid KDF")
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("invalid output length %d", length)
+	}
+	out := make([]byte, 0, length)
+	counter := make([]byte, 4)
+	for i := uint32(1); len(out) < length; i++ {
+		if i == 0 {
+			return nil, fmt.Errorf("counter overflow")
+		}
+		binary.BigEndian.PutUint32(counter, i)
+		h := k.hashGen()
+		h.Write(z)
+		h.Write(counter)
+		h.Write(sharedInfo)
+		out = h.Sum(out)
+	}
+	return out[:length], nil
+}
+
+// SessionKeys are all the keys derived by the SCP11 key agreement
+type SessionKeys struct {
+	Receipt []byte
+	ENC     []byte
+	MAC     []byte
+	RMAC    []byte
+	DEK     []byte
+}
+
+// deriveSessionKeys derives the receipt key and session keys from the concatenated shared secrets ShSee || ShSes
+func deriveSessionKeys(kdf *X963KDF, z []byte, keyUsage, keyType, keyLength byte) (keys SessionKeys, err error) {
+	length := int(keyLength)
+	data, err := kdf.Derive(z, []byte{keyUsage, keyType, keyLength}, 5*length)
+	if err != nil {
+		return
+	}
+	keys.Receipt = data[0:length]
+	keys.ENC = data[length : 2*length]
+	keys.MAC = data[2*length : 3*length]
+	keys.RMAC = data[3*length : 4*length]
+	keys.DEK = data[4*length : 5*length]
+	return
+}
+
+// curveParameters gets the session key length and KDF hash matching the strength of the curve
+func curveParameters(curveName string) (keyLength byte, hashGen func() hash.Hash, err error) {
+	switch curveName {
+	case "P-256":
+		return 16, sha256.New, nil
+	case "P-384":
+		return 24, sha512.New384, nil
+	default:
+		return 0, nil, fmt.Errorf("unsupported curve %s, must be P-256 or P-384", curveName)
+	}
+}
@@ -0,0 +1,247 @@
+package scp11
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+	"io"
+
+	"github.com/aead/cmac"
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/llkennedy/globalplatform/goimpl/scp03"
+)
+
+const (
+	tagControlReferenceTemplateKeyAgreement = 0xA6
+	tagSCPIdentifierAndParameters           = 0x90
+	tagKeyUsageQualifier                    = 0x95
+	tagKeyType                              = 0x80
+	tagKeyLength                            = 0x81
+	tagEphemeralPublicKey                   = 0x5F49
+	tagReceipt                              = 0x86
+	// keyUsageSecureMessaging is C-MAC, R-MAC, C-DECRYPTION and R-ENCRYPTION
+	keyUsageSecureMessaging = 0x3C
+	keyTypeAES              = 0x88
+)
+
+// securityLevel is the security level of every SCP11 session, as requested by keyUsageSecureMessaging
+const securityLevel = gpapdu.SecurityLevelCMAC | gpapdu.SecurityLevelCDecryption | gpapdu.SecurityLevelRMAC | gpapdu.SecurityLevelREncryption
+
+// Options configures the opening of an SCP11 secure channel
+type Options struct {
+	Variant          Variant
+	KeyVersionNumber byte             // KVN of the card's ECKA key
+	KeyIdentifier    byte             // KID of the card's ECKA key
+	CardPublicKey    *ecdsa.PublicKey // PK.SD.ECKA, taken from a validated CERT.SD
+	// OCEPrivateKey is SK.OCE.ECKA, only used by SCP11a and SCP11c
+	OCEPrivateKey *ecdsa.PrivateKey
+	// OCECertificates is the encoded CERT.OCE chain ending with the certificate for OCEPrivateKey, only used by SCP11a and SCP11c
+	OCECertificates [][]byte
+	// OCEKeyVersionNumber and OCEKeyIdentifier reference the key on the card which verifies the first certificate in OCECertificates
+	OCEKeyVersionNumber, OCEKeyIdentifier byte
+	LogicalChannel                        uint8
+	Rand                                  io.Reader // Source of the ephemeral key, crypto/rand is used when nil
+}
+
+// Open performs the SCP11 key agreement and returns an SCP03 secure messaging session using the agreed keys
+func Open(transport apdu.Transport, opts Options) (*scp03.Session, error) {
+	if transport == nil {
+		return nil, fmt.Errorf("cannot open secure channel on nil transport")
+	}
+	switch opts.Variant {
+	case SCP11a, SCP11b, SCP11c:
+	default:
+		return nil, fmt.Errorf("invalid SCP11 variant %02X", byte(opts.Variant))
+	}
+	if opts.CardPublicKey == nil || opts.CardPublicKey.Curve == nil {
+		return nil, fmt.Errorf("card public key is required")
+	}
+	curve := opts.CardPublicKey.Curve
+	keyLength, hashGen, err := curveParameters(curve.Params().Name)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Variant.mutual() {
+		if opts.OCEPrivateKey == nil {
+			return nil, fmt.Errorf("%s requires the OCE private key", opts.Variant)
+		}
+		if opts.OCEPrivateKey.Curve.Params().Name != curve.Params().Name {
+			return nil, fmt.Errorf("OCE key is on curve %s but card key is on curve %s", opts.OCEPrivateKey.Curve.Params().Name, curve.Params().Name)
+		}
+		if len(opts.OCECertificates) == 0 {
+			return nil, fmt.Errorf("%s requires the OCE certificate chain", opts.Variant)
+		}
+	}
+	randR := opts.Rand
+	if randR == nil {
+		randR = rand.Reader
+	}
+	class := gpapdu.Class{
+		IsGPCommand: true,
+		InterindustryClass: apdu.InterindustryClass{
+			LogicalChannelNumber: opts.LogicalChannel,
+			SecureMessaging:      apdu.CLASMNone,
+		},
+	}
+	if opts.Variant.mutual() {
+		if err = uploadCertificates(transport, class, opts); err != nil {
+			return nil, err
+		}
+	}
+	ephemeral, err := ecdsa.GenerateKey(curve, randR)
+	if err != nil {
+		return nil, fmt.Errorf("generating ephemeral key: %w", err)
+	}
+	crt := bytes.NewBuffer(nil)
+	crt.Write(encodeTLV(tagSCPIdentifierAndParameters, []byte{SCPIdentifier, byte(opts.Variant)}))
+	crt.Write(encodeTLV(tagKeyUsageQualifier, []byte{keyUsageSecureMessaging}))
+	crt.Write(encodeTLV(tagKeyType, []byte{keyTypeAES}))
+	crt.Write(encodeTLV(tagKeyLength, []byte{keyLength}))
+	data := encodeTLV(tagControlReferenceTemplateKeyAgreement, crt.Bytes())
+	data = append(data, encodeTLV(tagEphemeralPublicKey, elliptic.Marshal(curve, ephemeral.X, ephemeral.Y))...)
+	res, err := gpapdu.SendOnTransport(transport, gpapdu.Command{
+		Class:              class,
+		Instruction:        opts.Variant.instruction(),
+		P1:                 opts.KeyVersionNumber,
+		P2:                 opts.KeyIdentifier,
+		Data:               data,
+		ExpectResponseData: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sending authenticate command: %w", err)
+	}
+	if err = res.GetStatus().Error(); err != nil {
+		return nil, fmt.Errorf("authenticate command: %w", err)
+	}
+	cardEphemeral, cardEphemeralTLV, receipt, err := parseAuthenticateResponse(curve, res.Data)
+	if err != nil {
+		return nil, err
+	}
+	shSee, err := ecdh(ephemeral, cardEphemeral)
+	if err != nil {
+		return nil, fmt.Errorf("computing ShSee: %w", err)
+	}
+	staticOCE := ephemeral
+	if opts.Variant.mutual() {
+		staticOCE = opts.OCEPrivateKey
+	}
+	shSes, err := ecdh(staticOCE, opts.CardPublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("computing ShSes: %w", err)
+	}
+	keys, err := deriveSessionKeys(NewX963KDF(hashGen), append(shSee, shSes...), keyUsageSecureMessaging, keyTypeAES, keyLength)
+	if err != nil {
+		return nil, fmt.Errorf("deriving session keys: %w", err)
+	}
+	keyAgreementData := append(append([]byte{}, data...), cardEphemeralTLV...)
+	if err = VerifyReceipt(keys.Receipt, keyAgreementData, receipt); err != nil {
+		return nil, err
+	}
+	sessionKeys, err := scp03.NewSessionKeys(keys.ENC, keys.MAC, keys.RMAC, keys.DEK)
+	if err != nil {
+		return nil, err
+	}
+	return scp03.NewSession(transport, sessionKeys, securityLevel, true, receipt)
+}
+
+// VerifyReceipt checks the receipt is the AES-CMAC of the key agreement data under the receipt key
+func VerifyReceipt(receiptKey, keyAgreementData, receipt []byte) error {
+	block, err := aes.NewCipher(receiptKey)
+	if err != nil {
+		return err
+	}
+	expected, err := cmac.Sum(keyAgreementData, block, aes.BlockSize)
+	if err != nil {
+		return err
+	}
+	if subtle.ConstantTimeCompare(expected, receipt) != 1 {
+		return fmt.Errorf("receipt verification failed, card could not be authenticated")
+	}
+	return nil
+}
+
+// uploadCertificates sends the OCE certificate chain with PERFORM SECURITY OPERATION, b8 of P2 marks that more certificates follow
+func uploadCertificates(transport apdu.Transport, class gpapdu.Class, opts Options) error {
+	for i, cert := range opts.OCECertificates {
+		cmd := gpapdu.Command{
+			Class:       class,
+			Instruction: apdu.InstructionPerformSecurityOperation,
+			P1:          opts.OCEKeyVersionNumber,
+			P2:          opts.OCEKeyIdentifier,
+			Data:        cert,
+		}
+		if i < len(opts.OCECertificates)-1 {
+			cmd.P2 = cmd.P2 | 0x80
+		}
+		for _, link := range cmd.Chain() {
+			res, err := gpapdu.SendOnTransport(transport, link)
+			if err != nil {
+				return fmt.Errorf("sending PERFORM SECURITY OPERATION: %w", err)
+			}
+			if err = res.GetStatus().Error(); err != nil {
+				return fmt.Errorf("PERFORM SECURITY OPERATION for certificate %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// parseAuthenticateResponse extracts ePK.SD.ECKA, its full encoded TLV and the receipt
+func parseAuthenticateResponse(curve elliptic.Curve, data []byte) (pub *ecdsa.PublicKey, pubTLV, receipt []byte, err error) {
+	r := bertlv.NewBytesReader(data)
+	offset := 0
+	for offset < len(data) {
+		n, obj, readErr := r.Read()
+		if readErr != nil {
+			err = fmt.Errorf("reading authenticate response: %w", readErr)
+			return
+		}
+		switch obj.Tag {
+		case bertlv.TagFromUintForced(tagEphemeralPublicKey):
+			x, y := elliptic.Unmarshal(curve, obj.Value)
+			if x == nil {
+				err = fmt.Errorf("card ephemeral public key is not a valid uncompressed point on %s", curve.Params().Name)
+				return
+			}
+			pub = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+			pubTLV = data[offset : offset+n]
+		case bertlv.TagFromUintForced(tagReceipt):
+			receipt = obj.Value
+		}
+		offset += n
+	}
+	if pub == nil || receipt == nil {
+		err = fmt.Errorf("authenticate response must contain an ephemeral public key and a receipt")
+	}
+	return
+}
+
+// ecdh computes the shared secret, the X coordinate of the product of the private scalar and public point
+func ecdh(priv *ecdsa.PrivateKey, pub *ecdsa.PublicKey) ([]byte, error) {
+	if priv == nil || pub == nil {
+		return nil, fmt.Errorf("nil key")
+	}
+	if !priv.Curve.IsOnCurve(pub.X, pub.Y) {
+		return nil, fmt.Errorf("public key is not on curve %s", priv.Curve.Params().Name)
+	}
+	x, _ := priv.Curve.ScalarMult(pub.X, pub.Y, priv.D.Bytes())
+	out := make([]byte, (priv.Curve.Params().BitSize+7)/8)
+	return x.FillBytes(out), nil
+}
+
+// encodeTLV encodes a single BER-TLV object
+func encodeTLV(tag uint64, value []byte) []byte {
+	buf := bytes.NewBuffer(nil)
+	w, _ := bertlv.NewWriter(buf)
+	w.Write(bertlv.Object{
+		Tag:   bertlv.TagFromUintForced(tag),
+		Value: value,
+	})
+	return buf.Bytes()
+}
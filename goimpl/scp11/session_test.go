@@ -0,0 +1,209 @@
+package scp11
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/aead/cmac"
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+	"github.com/llkennedy/globalplatform/goimpl/bertlv"
+	"github.com/llkennedy/globalplatform/goimpl/gpapdu"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestX963KDF_Derive(t *testing.T) {
+	z := bytes.Repeat([]byte{0xAB}, 32)
+	info := []byte{0x3C, 0x88, 0x10}
+	first := sha256.Sum256(append(append(append([]byte{}, z...), 0, 0, 0, 1), info...))
+	second := sha256.Sum256(append(append(append([]byte{}, z...), 0, 0, 0, 2), info...))
+	tests := []struct {
+		name      string
+		k         *X963KDF
+		length    int
+		want      []byte
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:      "one block",
+			k:         NewX963KDF(sha256.New),
+			length:    16,
+			want:      first[:16],
+			assertion: assert.NoError,
+		},
+		{
+			name:      "two blocks",
+			k:         NewX963KDF(sha256.New),
+			length:    48,
+			want:      append(first[:], second[:16]...),
+			assertion: assert.NoError,
+		},
+		{
+			name:      "nil hash",
+			k:         &X963KDF{},
+			length:    16,
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.k.Derive(z, info, tt.length)
+			tt.assertion(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	cardKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	oceKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	tests := []struct {
+		name      string
+		cardKey   *ecdsa.PrivateKey
+		opts      Options
+		wantPSO   [][2]byte // P1 and P2 of each PERFORM SECURITY OPERATION command
+		assertion assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "SCP11b",
+			cardKey: cardKey,
+			opts: Options{
+				Variant:          SCP11b,
+				KeyVersionNumber: 0x01,
+				KeyIdentifier:    0x13,
+				CardPublicKey:    &cardKey.PublicKey,
+			},
+			assertion: assert.NoError,
+		},
+		{
+			name:    "SCP11a",
+			cardKey: cardKey,
+			opts: Options{
+				Variant:             SCP11a,
+				KeyVersionNumber:    0x01,
+				KeyIdentifier:       0x11,
+				CardPublicKey:       &cardKey.PublicKey,
+				OCEPrivateKey:       oceKey,
+				OCECertificates:     [][]byte{bytes.Repeat([]byte{1}, 300), {2}},
+				OCEKeyVersionNumber: 0x03,
+				OCEKeyIdentifier:    0x10,
+			},
+			// The first certificate is split in two by command chaining
+			wantPSO:   [][2]byte{{0x03, 0x90}, {0x03, 0x90}, {0x03, 0x10}},
+			assertion: assert.NoError,
+		},
+		{
+			name:    "SCP11c P-384",
+			cardKey: p384Key,
+			opts: Options{
+				Variant:          SCP11c,
+				KeyVersionNumber: 0x01,
+				KeyIdentifier:    0x15,
+				CardPublicKey:    &p384Key.PublicKey,
+				OCEPrivateKey:    p384Key,
+				OCECertificates:  [][]byte{{1}},
+			},
+			wantPSO:   [][2]byte{{0x00, 0x00}},
+			assertion: assert.NoError,
+		},
+		{
+			name:    "wrong card key",
+			cardKey: cardKey,
+			opts: Options{
+				Variant:       SCP11b,
+				CardPublicKey: &oceKey.PublicKey,
+			},
+			assertion: assert.Error,
+		},
+		{
+			name:    "SCP11a without certificates",
+			cardKey: cardKey,
+			opts: Options{
+				Variant:       SCP11a,
+				CardPublicKey: &cardKey.PublicKey,
+				OCEPrivateKey: oceKey,
+			},
+			assertion: assert.Error,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card := &fakeCard{t: t, static: tt.cardKey, oce: tt.opts.OCEPrivateKey}
+			s, err := Open(card, tt.opts)
+			tt.assertion(t, err)
+			if err != nil {
+				return
+			}
+			var gotPSO [][2]byte
+			for _, cmd := range card.pso {
+				gotPSO = append(gotPSO, [2]byte{cmd.P1, cmd.P2})
+			}
+			assert.Equal(t, tt.wantPSO, gotPSO)
+			assert.Equal(t, securityLevel, s.SecurityLevel())
+			res, err := s.Send(apdu.Command{Class: gpapdu.Class{IsGPCommand: true}, Instruction: 0xF2, P1: 0x80, P2: 0x02})
+			assert.NoError(t, err)
+			assert.NoError(t, res.GetStatus().Error())
+		})
+	}
+}
+
+// fakeCard is the card side of SCP11, just enough to check the key agreement and first C-MAC
+type fakeCard struct {
+	t        *testing.T
+	static   *ecdsa.PrivateKey
+	oce      *ecdsa.PrivateKey
+	keys     SessionKeys
+	chaining []byte
+	pso      []apdu.Command
+}
+
+func (c *fakeCard) Send(cmd apdu.Command) (apdu.Response, error) {
+	ok := apdu.RawStatus{SW1: 0x90}.Identify()
+	switch cmd.Instruction {
+	case apdu.InstructionPerformSecurityOperation:
+		c.pso = append(c.pso, cmd)
+		return apdu.Response{Status: ok}, nil
+	case apdu.InstructionInternalAuthenticate, apdu.InstructionExternalMutualAuthenticate:
+		curve := c.static.Curve
+		keyLength, hashGen, err := curveParameters(curve.Params().Name)
+		assert.NoError(c.t, err)
+		r := bertlv.NewBytesReader(cmd.Data)
+		_, _, err = r.Read()
+		assert.NoError(c.t, err)
+		_, epkOCE, err := r.Read()
+		assert.NoError(c.t, err)
+		x, y := elliptic.Unmarshal(curve, epkOCE.Value)
+		ephemeral, _ := ecdsa.GenerateKey(curve, rand.Reader)
+		shSee, err := ecdh(ephemeral, &ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+		assert.NoError(c.t, err)
+		var shSes []byte
+		if cmd.Instruction == apdu.InstructionInternalAuthenticate {
+			shSes, err = ecdh(c.static, &ecdsa.PublicKey{Curve: curve, X: x, Y: y})
+		} else {
+			shSes, err = ecdh(c.static, &c.oce.PublicKey)
+		}
+		assert.NoError(c.t, err)
+		c.keys, err = deriveSessionKeys(NewX963KDF(hashGen), append(shSee, shSes...), keyUsageSecureMessaging, keyTypeAES, keyLength)
+		assert.NoError(c.t, err)
+		epkSD := encodeTLV(tagEphemeralPublicKey, elliptic.Marshal(curve, ephemeral.X, ephemeral.Y))
+		block, _ := aes.NewCipher(c.keys.Receipt)
+		receipt, _ := cmac.Sum(append(append([]byte{}, cmd.Data...), epkSD...), block, aes.BlockSize)
+		c.chaining = receipt
+		return apdu.Response{Data: append(epkSD, encodeTLV(tagReceipt, receipt)...), Status: ok}, nil
+	default:
+		// C-MAC over the chaining value, modified header and empty data field
+		block, _ := aes.NewCipher(c.keys.MAC)
+		mac, _ := cmac.Sum(append(append([]byte{}, c.chaining...), cmd.Class.ToClassByte(), byte(cmd.Instruction), cmd.P1, cmd.P2, 8), block, aes.BlockSize)
+		if !bytes.Equal(mac[:8], cmd.Data) {
+			return apdu.Response{Status: apdu.RawStatus{SW1: 0x69, SW2: 0x82}.Identify()}, nil
+		}
+		block, _ = aes.NewCipher(c.keys.RMAC)
+		rmac, _ := cmac.Sum(append(mac, 0x90, 0x00), block, aes.BlockSize)
+		return apdu.Response{Data: rmac[:8], Status: ok}, nil
+	}
+}
@@ -0,0 +1,48 @@
+package scp11
+
+import (
+	"github.com/llkennedy/globalplatform/goimpl/apdu"
+)
+
+// SCPIdentifier is the SCP identifier of SCP11
+const SCPIdentifier byte = 0x11
+
+// Variant is an SCP11 variant, its value is the "i" parameter sent to the card
+type Variant byte
+
+const (
+	// SCP11b authenticates the card to the off-card entity only
+	SCP11b Variant = 0x00
+	// SCP11a mutually authenticates the card and the off-card entity
+	SCP11a Variant = 0x01
+	// SCP11c mutually authenticates the card and the off-card entity, allowing precomputed scripts to be replayed to many cards
+	SCP11c Variant = 0x03
+)
+
+// String returns the name of the variant
+func (v Variant) String() string {
+	switch v {
+	case SCP11a:
+		return "SCP11a"
+	case SCP11b:
+		return "SCP11b"
+	case SCP11c:
+		return "SCP11c"
+	default:
+		return "unknown SCP11 variant"
+	}
+}
+
+// mutual indicates whether the variant authenticates the off-card entity with a static key and certificate
+func (v Variant) mutual() bool {
+	return v == SCP11a || v == SCP11c
+}
+
+// instruction is the authentication instruction used by the variant
+func (v Variant) instruction() apdu.Instruction {
+	if v.mutual() {
+		// MUTUAL AUTHENTICATE shares its INS with EXTERNAL AUTHENTICATE
+		return apdu.InstructionExternalMutualAuthenticate
+	}
+	return apdu.InstructionInternalAuthenticate
+}